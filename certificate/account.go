@@ -47,7 +47,7 @@ func (a *Account) GetRegistration() *registration.Resource {
 	return a.Registration
 }
 
-func getLegoClientAndAccount(email string, privateKey string, devMode bool) (*lego.Client, *Account) {
+func getLegoClientAndAccount(email string, privateKey string, devMode bool) (*lego.Client, *Account, error) {
 	account := &Account{
 		Email: email,
 		key:   decodeEccKey(privateKey),
@@ -65,10 +65,10 @@ func getLegoClientAndAccount(email string, privateKey string, devMode bool) (*le
 
 	client, err := lego.NewClient(config)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
-	return client, account
+	return client, account, nil
 }
 
 // GetAcmeClient Incoming an email ,a privatekey and a Boolean value that controls the opening of the test environment
@@ -76,30 +76,31 @@ func getLegoClientAndAccount(email string, privateKey string, devMode bool) (*le
 // After initializing the configuration, It will try to obtain an account based on the private key,
 // if it fails, it will create an account based on the private key.
 // This account will be used during the running of the program
-func GetAcmeClient(email string, privateKey string, devMode bool) *lego.Client {
+func GetAcmeClient(email string, privateKey string, devMode bool) (*lego.Client, error) {
 	// Create a user. New accounts need an email and private key to start.
-	client, account := getLegoClientAndAccount(email, privateKey, devMode)
+	client, account, err := getLegoClientAndAccount(email, privateKey, devMode)
+	if err != nil {
+		return nil, err
+	}
 
 	// try to obtain an account based on the private key
-	var err error
 	account.Registration, err = client.Registration.ResolveAccountByKey()
 	if err != nil {
 		acmeError, ok := err.(*acme.ProblemDetails)
 		if !ok {
-			panic(err)
+			return nil, err
 		}
 
 		if acmeError.Type != "urn:ietf:params:acme:error:accountDoesNotExist" {
-			panic(acmeError)
+			return nil, acmeError
 		}
 
 		// Failed to get account, so create an account based on the private key.
-		var err error
 		account.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
 
-	return client
+	return client, nil
 }
@@ -36,8 +36,16 @@ func TestGetClient(t *testing.T) {
 	eccKey := util.ReadStringFromPath("acme_account.key")
 	println(eccKey)
 
-	client := GetAcmeClient("acme2@casbin.org", eccKey, false)
-	pem, key := ObtainCertificateAli(client, "casbin.com", accessKeyId, accessKeySecret)
+	client, err := GetAcmeClient("acme2@casbin.org", eccKey, false)
+	if err != nil {
+		panic(err)
+	}
+
+	credentials := map[string]string{"accessKey": accessKeyId, "secretKey": accessKeySecret}
+	pem, key, err := ObtainCertificateByDns(client, "Aliyun", "casbin.com", credentials)
+	if err != nil {
+		panic(err)
+	}
 	println(pem)
 	println()
 	println(key)
@@ -16,131 +16,74 @@ package certificate
 
 import (
 	"fmt"
-	"time"
+	"strings"
 
+	"github.com/casbin/caswaf/conf"
 	"github.com/casbin/lego/v4/certificate"
 	"github.com/casbin/lego/v4/challenge/dns01"
 	"github.com/casbin/lego/v4/cmd"
 	"github.com/casbin/lego/v4/lego"
-	"github.com/casbin/lego/v4/providers/dns/alidns"
-	"github.com/casbin/lego/v4/providers/dns/godaddy"
 )
 
-type AliConf struct {
-	Domains       []string // The domain names for which you want to apply for a certificate
-	AccessKey     string   // Aliyun account's AccessKey, if this is not empty, Secret is required.
-	Secret        string
-	RAMRole       string // Use Ramrole to control aliyun account
-	SecurityToken string // Optional
-	Path          string // The path to store cert file
-	Timeout       int    // Maximum waiting time for certificate application, in minutes
-}
-
-type GodaddyConf struct {
-	Domains   []string // The domain names for which you want to apply for a certificate
-	APIKey    string   // GoDaddy account's API Key
-	APISecret string
-	Path      string // The path to store cert file
-	Timeout   int    // Maximum waiting time for certificate application, in minutes
+// getDnsNameservers returns the recursive nameservers configured by dnsNameservers in app.conf
+func getDnsNameservers() []string {
+	nameservers := []string{}
+	for _, nameserver := range strings.Split(conf.GetConfigString("dnsNameservers"), ",") {
+		nameserver = strings.TrimSpace(nameserver)
+		if nameserver != "" {
+			nameservers = append(nameservers, nameserver)
+		}
+	}
+	return nameservers
 }
 
-// getCert Verify domain ownership, then obtain a certificate, and finally store it locally.
-// Need to pass in an AliConf struct, some parameters are required, other parameters can be left blank
-func getAliCert(client *lego.Client, conf AliConf) (string, string) {
-	if conf.Timeout <= 0 {
-		conf.Timeout = 3
+// setDnsProvider sets the DNS-01 challenge provider configured by the credentials to the client. The client
+// checks the propagation by the nameservers of dnsNameservers in app.conf, or the ones of the provider.
+// The go-acme/lego providers find the zone of the domain by the system nameservers, because the dns01
+// package of go-acme/lego only keeps them in a package global, which is left untouched
+func setDnsProvider(client *lego.Client, providerName string, credentials map[string]string) error {
+	provider, ok := dnsProviders[providerName]
+	if !ok {
+		return fmt.Errorf("unknown DNS provider: %s", providerName)
 	}
 
-	config := alidns.NewDefaultConfig()
-	config.PropagationTimeout = time.Duration(conf.Timeout) * time.Minute
-	config.APIKey = conf.AccessKey
-	config.SecretKey = conf.Secret
-	config.RAMRole = conf.RAMRole
-	config.SecurityToken = conf.SecurityToken
-
-	dnsProvider, err := alidns.NewDNSProvider(config)
+	dnsProvider, err := NewDnsProvider(providerName, credentials)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// Choose a local DNS service provider to increase the authentication speed
-	servers := []string{"223.5.5.5:53"}
-	err = client.Challenge.SetDNS01Provider(dnsProvider, dns01.CondOption(len(servers) > 0, dns01.AddRecursiveNameservers(dns01.ParseNameservers(servers))), dns01.DisableCompletePropagationRequirement())
-	if err != nil {
-		panic(err)
+	nameservers := getDnsNameservers()
+	if len(nameservers) == 0 {
+		nameservers = provider.nameservers
 	}
 
-	// Obtain the certificate
-	request := certificate.ObtainRequest{
-		Domains: conf.Domains,
-		Bundle:  true,
-	}
-	cert, err := client.Certificate.Obtain(request)
-	if err != nil {
-		panic(err)
+	options := []dns01.ChallengeOption{dns01.DisableCompletePropagationRequirement()}
+	if len(nameservers) > 0 {
+		// Choose a local DNS service provider to increase the authentication speed
+		options = append(options, dns01.AddRecursiveNameservers(dns01.ParseNameservers(nameservers)))
 	}
-
-	return string(cert.Certificate), string(cert.PrivateKey)
+	return client.Challenge.SetDNS01Provider(dnsProvider, options...)
 }
 
-func getGoDaddyCert(client *lego.Client, conf GodaddyConf) (string, string) {
-	if conf.Timeout <= 0 {
-		conf.Timeout = 3
-	}
-
-	config := godaddy.NewDefaultConfig()
-	config.PropagationTimeout = time.Duration(conf.Timeout) * time.Minute
-	config.PollingInterval = time.Duration(conf.Timeout) * time.Minute / 9
-	config.APIKey = conf.APIKey
-	config.APISecret = conf.APISecret
-
-	dnsProvider, err := godaddy.NewDNSProvider(config)
-	if err != nil {
-		panic(err)
-	}
-
-	// Choose a local DNS service provider to increase the authentication speed
-	servers := []string{"223.5.5.5:53"}
-	err = client.Challenge.SetDNS01Provider(dnsProvider, dns01.CondOption(len(servers) > 0, dns01.AddRecursiveNameservers(dns01.ParseNameservers(servers))), dns01.DisableCompletePropagationRequirement())
+// ObtainCertificateByDns obtains a wildcard certificate of the domain via the DNS-01 challenge of
+// the DNS provider configured by the credentials
+func ObtainCertificateByDns(client *lego.Client, providerName string, domain string, credentials map[string]string) (string, string, error) {
+	err := setDnsProvider(client, providerName, credentials)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	// Obtain the certificate
 	request := certificate.ObtainRequest{
-		Domains: conf.Domains,
+		Domains: []string{fmt.Sprintf("*.%s", domain), domain},
 		Bundle:  true,
 	}
 	cert, err := client.Certificate.Obtain(request)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
-	return string(cert.Certificate), string(cert.PrivateKey)
-}
-
-func ObtainCertificateAli(client *lego.Client, domain string, accessKey string, accessSecret string) (string, string) {
-	conf := AliConf{
-		Domains:       []string{fmt.Sprintf("*.%s", domain), domain},
-		AccessKey:     accessKey,
-		Secret:        accessSecret,
-		RAMRole:       "",
-		SecurityToken: "",
-		Path:          "",
-		Timeout:       3,
-	}
-	return getAliCert(client, conf)
-}
-
-func ObtainCertificateGoDaddy(client *lego.Client, domain string, accessKey string, accessSecret string) (string, string) {
-	conf := GodaddyConf{
-		Domains:   []string{fmt.Sprintf("*.%s", domain), domain},
-		APIKey:    accessKey,
-		APISecret: accessSecret,
-		Path:      "",
-		Timeout:   3,
-	}
-	return getGoDaddyCert(client, conf)
+	return string(cert.Certificate), string(cert.PrivateKey), nil
 }
 
 func SaveCert(path, filename string, cert *certificate.Resource) {
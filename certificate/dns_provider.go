@@ -0,0 +1,191 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certificate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awscredentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsroute53 "github.com/aws/aws-sdk-go/service/route53"
+	"github.com/casbin/lego/v4/challenge"
+	"github.com/casbin/lego/v4/providers/dns/alidns"
+	"github.com/casbin/lego/v4/providers/dns/godaddy"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+)
+
+// dnsPropagationTimeout is the maximum waiting time for the TXT record to be propagated
+const dnsPropagationTimeout = 3 * time.Minute
+
+// DnsProviderField is a credential of a DNS provider, which is set in the credentials map of a cert
+type DnsProviderField struct {
+	Name       string `json:"name"`
+	IsSecret   bool   `json:"isSecret"`
+	IsRequired bool   `json:"isRequired"`
+}
+
+type dnsProvider struct {
+	fields []DnsProviderField
+	// nameservers are the recursive nameservers to check the propagation, the system ones are used if empty
+	nameservers []string
+	new         func(credentials map[string]string) (challenge.Provider, error)
+}
+
+// dnsProviders are the supported DNS providers by name. The set is curated instead of all the providers of
+// lego, because each provider needs its credential fields to be listed, so that the secrets are masked. The
+// Aliyun and GoDaddy providers are from casbin/lego, and the others from go-acme/lego, which casbin/lego lacks
+var dnsProviders = map[string]*dnsProvider{
+	"Aliyun": {
+		fields: []DnsProviderField{
+			{Name: "accessKey", IsRequired: true},
+			{Name: "secretKey", IsSecret: true, IsRequired: true},
+			{Name: "ramRole"},
+			{Name: "securityToken", IsSecret: true},
+		},
+		nameservers: []string{"223.5.5.5:53"},
+		new: func(credentials map[string]string) (challenge.Provider, error) {
+			config := alidns.NewDefaultConfig()
+			config.PropagationTimeout = dnsPropagationTimeout
+			config.APIKey = credentials["accessKey"]
+			config.SecretKey = credentials["secretKey"]
+			config.RAMRole = credentials["ramRole"]
+			config.SecurityToken = credentials["securityToken"]
+			return alidns.NewDNSProvider(config)
+		},
+	},
+	"GoDaddy": {
+		fields: []DnsProviderField{
+			{Name: "apiKey", IsRequired: true},
+			{Name: "apiSecret", IsSecret: true, IsRequired: true},
+		},
+		nameservers: []string{"223.5.5.5:53"},
+		new: func(credentials map[string]string) (challenge.Provider, error) {
+			config := godaddy.NewDefaultConfig()
+			config.PropagationTimeout = dnsPropagationTimeout
+			config.PollingInterval = dnsPropagationTimeout / 9
+			config.APIKey = credentials["apiKey"]
+			config.APISecret = credentials["apiSecret"]
+			return godaddy.NewDNSProvider(config)
+		},
+	},
+	"Cloudflare": {
+		// either the API token, or the email with the global API key is required
+		fields: []DnsProviderField{
+			{Name: "apiToken", IsSecret: true},
+			{Name: "zoneToken", IsSecret: true},
+			{Name: "email"},
+			{Name: "apiKey", IsSecret: true},
+		},
+		new: func(credentials map[string]string) (challenge.Provider, error) {
+			config := cloudflare.NewDefaultConfig()
+			config.PropagationTimeout = dnsPropagationTimeout
+			config.AuthToken = credentials["apiToken"]
+			config.ZoneToken = credentials["zoneToken"]
+			config.AuthEmail = credentials["email"]
+			config.AuthKey = credentials["apiKey"]
+			return cloudflare.NewDNSProviderConfig(config)
+		},
+	},
+	"Route53": {
+		// the default AWS credential chain is used if the access key is empty, e.g., the IAM role of the instance
+		fields: []DnsProviderField{
+			{Name: "accessKeyId"},
+			{Name: "secretAccessKey", IsSecret: true},
+			{Name: "region"},
+			{Name: "hostedZoneId"},
+		},
+		new: func(credentials map[string]string) (challenge.Provider, error) {
+			config := route53.NewDefaultConfig()
+			config.PropagationTimeout = dnsPropagationTimeout
+			config.HostedZoneID = credentials["hostedZoneId"]
+
+			// Route 53 is a global service, the region only selects the endpoint of the API
+			region := credentials["region"]
+			if region == "" {
+				region = "us-east-1"
+			}
+			awsConfig := aws.NewConfig().WithRegion(region).WithMaxRetries(config.MaxRetries)
+			if credentials["accessKeyId"] != "" {
+				awsConfig = awsConfig.WithCredentials(awscredentials.NewStaticCredentials(credentials["accessKeyId"], credentials["secretAccessKey"], ""))
+			}
+			sess, err := session.NewSession(awsConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			config.Client = awsroute53.New(sess)
+			return route53.NewDNSProviderConfig(config)
+		},
+	},
+	"RFC2136": {
+		fields: []DnsProviderField{
+			{Name: "nameserver", IsRequired: true},
+			{Name: "tsigAlgorithm"},
+			{Name: "tsigKey"},
+			{Name: "tsigSecret", IsSecret: true},
+		},
+		new: func(credentials map[string]string) (challenge.Provider, error) {
+			config := rfc2136.NewDefaultConfig()
+			config.PropagationTimeout = dnsPropagationTimeout
+			config.Nameserver = credentials["nameserver"]
+			if credentials["tsigAlgorithm"] != "" {
+				config.TSIGAlgorithm = credentials["tsigAlgorithm"]
+			}
+			config.TSIGKey = credentials["tsigKey"]
+			config.TSIGSecret = credentials["tsigSecret"]
+			return rfc2136.NewDNSProviderConfig(config)
+		},
+	},
+}
+
+// GetDnsProviderNames returns the names of the supported DNS providers in alphabetical order
+func GetDnsProviderNames() []string {
+	res := []string{}
+	for name := range dnsProviders {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// GetDnsProviderFields returns the credentials of the DNS provider, or nil if the provider is unknown
+func GetDnsProviderFields(providerName string) []DnsProviderField {
+	provider, ok := dnsProviders[providerName]
+	if !ok {
+		return nil
+	}
+	return provider.fields
+}
+
+// NewDnsProvider returns the DNS-01 challenge provider configured by the credentials
+func NewDnsProvider(providerName string, credentials map[string]string) (challenge.Provider, error) {
+	provider, ok := dnsProviders[providerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS provider: %s", providerName)
+	}
+
+	for _, field := range provider.fields {
+		if field.IsRequired && credentials[field.Name] == "" {
+			return nil, fmt.Errorf("the credential: %s of DNS provider: %s should not be empty", field.Name, providerName)
+		}
+	}
+
+	return provider.new(credentials)
+}
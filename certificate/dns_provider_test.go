@@ -0,0 +1,63 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certificate
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGetDnsProviderNames(t *testing.T) {
+	names := GetDnsProviderNames()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("GetDnsProviderNames() = %v, should be sorted", names)
+	}
+
+	for _, name := range []string{"Aliyun", "Cloudflare", "GoDaddy", "RFC2136", "Route53"} {
+		if GetDnsProviderFields(name) == nil {
+			t.Errorf("GetDnsProviderFields(%s) = nil", name)
+		}
+	}
+	if GetDnsProviderFields("Unknown") != nil {
+		t.Errorf("GetDnsProviderFields(Unknown) should be nil")
+	}
+}
+
+func TestNewDnsProvider(t *testing.T) {
+	tests := []struct {
+		providerName string
+		credentials  map[string]string
+		wantErr      bool
+	}{
+		{"Unknown", map[string]string{}, true},
+		{"GoDaddy", map[string]string{"apiKey": "key"}, true},
+		{"GoDaddy", map[string]string{"apiKey": "key", "apiSecret": "secret"}, false},
+		{"Cloudflare", map[string]string{}, true},
+		{"Cloudflare", map[string]string{"apiToken": "token"}, false},
+		{"RFC2136", map[string]string{}, true},
+		{"RFC2136", map[string]string{"nameserver": "127.0.0.1:53", "tsigKey": "key.", "tsigSecret": "c2VjcmV0"}, false},
+		{"Route53", map[string]string{"accessKeyId": "id", "secretAccessKey": "secret"}, false},
+	}
+
+	for _, tt := range tests {
+		provider, err := NewDnsProvider(tt.providerName, tt.credentials)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewDnsProvider(%s, %v) error = %v, wantErr %v", tt.providerName, tt.credentials, err, tt.wantErr)
+		}
+		if err == nil && provider == nil {
+			t.Errorf("NewDnsProvider(%s, %v) = nil", tt.providerName, tt.credentials)
+		}
+	}
+}
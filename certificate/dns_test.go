@@ -0,0 +1,103 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/lego/v4/challenge"
+	"github.com/casbin/lego/v4/lego"
+)
+
+// newTestLegoClient returns a casbin/lego client of a fake ACME server, which only serves the directory
+func newTestLegoClient(t *testing.T) *lego.Client {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order","revokeCert":"%[1]s/revoke","keyChange":"%[1]s/key"}`, server.URL)
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := lego.NewConfig(&Account{Email: "admin@example.com", key: key})
+	config.CADirURL = server.URL
+	client, err := lego.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSetDnsProvider(t *testing.T) {
+	client := newTestLegoClient(t)
+
+	// the providers from go-acme/lego are providers of casbin/lego, including their propagation timeout
+	provider, err := NewDnsProvider("Cloudflare", map[string]string{"apiToken": "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	providerTimeout, ok := provider.(challenge.ProviderTimeout)
+	if !ok {
+		t.Fatalf("the Cloudflare provider should implement challenge.ProviderTimeout of casbin/lego")
+	}
+	if timeout, _ := providerTimeout.Timeout(); timeout != dnsPropagationTimeout {
+		t.Errorf("Timeout() = %v, want %v", timeout, dnsPropagationTimeout)
+	}
+
+	err = setDnsProvider(client, "Unknown", map[string]string{})
+	if err == nil {
+		t.Errorf("setDnsProvider() should return an error for an unknown provider")
+	}
+
+	tests := []struct {
+		providerName string
+		credentials  map[string]string
+	}{
+		{"Cloudflare", map[string]string{"apiToken": "token"}},
+		{"Route53", map[string]string{"accessKeyId": "id", "secretAccessKey": "secret"}},
+		{"RFC2136", map[string]string{"nameserver": "127.0.0.1:53"}},
+		{"GoDaddy", map[string]string{"apiKey": "key", "apiSecret": "secret"}},
+	}
+
+	for _, tt := range tests {
+		err = setDnsProvider(client, tt.providerName, tt.credentials)
+		if err != nil {
+			t.Errorf("setDnsProvider(%s) error = %v", tt.providerName, err)
+		}
+	}
+}
+
+func TestGetDnsNameservers(t *testing.T) {
+	t.Setenv("dnsNameservers", " 127.0.0.1:53, ,8.8.8.8 ")
+	nameservers := getDnsNameservers()
+	if len(nameservers) != 2 || nameservers[0] != "127.0.0.1:53" || nameservers[1] != "8.8.8.8" {
+		t.Errorf("getDnsNameservers() = %v, want [127.0.0.1:53 8.8.8.8]", nameservers)
+	}
+
+	t.Setenv("dnsNameservers", "")
+	if nameservers = getDnsNameservers(); len(nameservers) != 0 {
+		t.Errorf("getDnsNameservers() = %v, want empty", nameservers)
+	}
+}
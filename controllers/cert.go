@@ -16,8 +16,10 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/beego/beego/utils/pagination"
+	"github.com/casbin/caswaf/certificate"
 	"github.com/casbin/caswaf/object"
 	"github.com/casbin/caswaf/util"
 )
@@ -109,6 +111,18 @@ func (c *ApiController) UpdateCert() {
 		return
 	}
 
+	oldCert, err := object.GetCert(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	err = checkCert(&cert, oldCert)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	c.Data["json"] = wrapActionResponse(object.UpdateCert(id, &cert))
 	c.ServeJSON()
 }
@@ -125,6 +139,12 @@ func (c *ApiController) AddCert() {
 		return
 	}
 
+	err = checkCert(&cert, nil)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	c.Data["json"] = wrapActionResponse(object.AddCert(&cert))
 	c.ServeJSON()
 }
@@ -172,3 +192,56 @@ func (c *ApiController) UpdateCertDomainExpire() {
 	c.Data["json"] = wrapActionResponse(object.UpdateCert(id, cert))
 	c.ServeJSON()
 }
+
+// GetDnsProviders returns the DNS providers of the DNS-01 challenge with their credentials
+func (c *ApiController) GetDnsProviders() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	res := []map[string]interface{}{}
+	for _, name := range certificate.GetDnsProviderNames() {
+		res = append(res, map[string]interface{}{
+			"name":   name,
+			"fields": certificate.GetDnsProviderFields(name),
+		})
+	}
+
+	c.ResponseOk(res)
+}
+
+// checkCert checks the cert to add or update, the old cert is nil for a cert to add
+func checkCert(cert *object.Cert, oldCert *object.Cert) error {
+	if cert.Provider != "" && certificate.GetDnsProviderFields(cert.Provider) == nil {
+		return fmt.Errorf("unknown DNS provider: %s", cert.Provider)
+	}
+
+	err := object.CheckAcmeConfig(&object.AcmeConfig{
+		DirectoryUrl: cert.AcmeDirectoryUrl,
+		EabKeyId:     cert.AcmeEabKeyId,
+		EabHmacKey:   cert.AcmeEabHmacKey,
+		CaBundle:     cert.AcmeCaBundle,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cert.Type == "Client CA" {
+		_, err := object.ParseCertChain(cert.Certificate)
+		if err != nil {
+			return err
+		}
+		if cert.PrivateKey != "" {
+			return fmt.Errorf("the private key of the client CA cert should be empty")
+		}
+		return nil
+	}
+
+	// the certificate is empty until it is issued by ACME, and an unchanged one is not checked again, so
+	// the other fields of an expired cert can still be fixed
+	if cert.Certificate == "" || oldCert != nil && cert.Certificate == oldCert.Certificate && cert.PrivateKey == oldCert.PrivateKey &&
+		cert.EccCertificate == oldCert.EccCertificate && cert.EccPrivateKey == oldCert.EccPrivateKey {
+		return nil
+	}
+	return object.ValidateCert(cert)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func (c *ApiController) GetCertJobs() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	if owner == "admin" {
+		owner = ""
+	}
+
+	jobs, err := object.GetCertJobs(owner)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(jobs)
+}
+
+// RenewCert queues the job renewing the cert, or retries its pending job immediately
+func (c *ApiController) RenewCert() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	id := c.Input().Get("id")
+	cert, err := object.GetCert(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if cert == nil {
+		c.ResponseError(fmt.Sprintf("the cert: %s does not exist", id))
+		return
+	}
+
+	job, err := object.AddCertRenewJob(cert)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(job)
+}
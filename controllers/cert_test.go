@@ -0,0 +1,40 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestCheckCertUnchanged(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	// an unchanged certificate is not checked again, e.g., when the DNS credentials of an expired cert are fixed
+	oldCert := &object.Cert{Owner: "admin", Name: "example.com", Certificate: "expired", PrivateKey: "key"}
+	cert := &object.Cert{Owner: "admin", Name: "example.com", Certificate: "expired", PrivateKey: "key", Provider: "Cloudflare"}
+	if err := checkCert(cert, oldCert); err != nil {
+		t.Errorf("checkCert() error = %v for an unchanged certificate", err)
+	}
+
+	cert.Certificate = "changed"
+	if err := checkCert(cert, oldCert); err == nil {
+		t.Errorf("checkCert() should check a changed certificate")
+	}
+	if err := checkCert(cert, nil); err == nil {
+		t.Errorf("checkCert() should check the certificate of a cert to add")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+)
+
+func (c *ApiController) GetCredentials() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	owner := c.Input().Get("owner")
+	site := c.Input().Get("site")
+	credentials, err := object.GetCredentials(owner, site)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(object.GetMaskedCredentials(credentials))
+}
+
+func (c *ApiController) GetCredential() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	id := c.Input().Get("id")
+	credential, err := object.GetCredential(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(object.GetMaskedCredential(credential))
+}
+
+// AddCredential adds a Basic Auth user with the password in the secret, or generates an API key,
+// which is returned in data2 and cannot be retrieved again
+func (c *ApiController) AddCredential() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	currentTime := util.GetCurrentTime()
+	credential := object.Credential{
+		CreatedTime: currentTime,
+		UpdatedTime: currentTime,
+	}
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	err = checkCredential(&credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	key := ""
+	if credential.Type == "API Key" {
+		key = fmt.Sprintf("cwk_%s", util.GetRandomHexString(24))
+		credential.Secret = util.GetSha256Hash(key)
+		credential.KeyPrefix = key[:12]
+	} else {
+		if credential.Secret == "" {
+			c.ResponseError("the password should not be empty")
+			return
+		}
+		credential.Secret, err = util.GetPasswordHash(credential.Secret)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	affected, err := object.AddCredential(&credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if !affected {
+		c.ResponseOk("Unaffected")
+		return
+	}
+
+	c.ResponseOk("Affected", key)
+}
+
+func (c *ApiController) UpdateCredential() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	var credential object.Credential
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	err = checkCredential(&credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	// the secret of a type is unusable by the other type, e.g., a bcrypt hash cannot be an API key
+	id := c.Input().Get("id")
+	oldCredential, err := object.GetCredential(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if oldCredential != nil && oldCredential.Type != credential.Type {
+		c.ResponseError(fmt.Sprintf("the type of credential: %s cannot be changed from %s to %s, please add a new credential instead", id, oldCredential.Type, credential.Type))
+		return
+	}
+
+	// an API key cannot be changed, while a Basic Auth password is changed if a new one is set
+	if credential.Type == "API Key" {
+		credential.Secret = ""
+	} else if credential.Secret != "" && credential.Secret != "***" {
+		credential.Secret, err = util.GetPasswordHash(credential.Secret)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+	}
+
+	c.Data["json"] = wrapActionResponse(object.UpdateCredential(id, &credential))
+	c.ServeJSON()
+}
+
+func (c *ApiController) DeleteCredential() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	var credential object.Credential
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &credential)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.Data["json"] = wrapActionResponse(object.DeleteCredential(&credential))
+	c.ServeJSON()
+}
+
+func checkCredential(credential *object.Credential) error {
+	site, err := object.GetSite(credential.GetSiteId())
+	if err != nil {
+		return err
+	}
+	if site == nil {
+		return fmt.Errorf("the site: %s does not exist", credential.GetSiteId())
+	}
+
+	switch credential.Type {
+	case "Basic Auth":
+		if credential.Username == "" {
+			return fmt.Errorf("the username should not be empty")
+		}
+	case "API Key":
+	default:
+		return fmt.Errorf("unknown credential type: %s", credential.Type)
+	}
+
+	if credential.RateLimit < 0 {
+		return fmt.Errorf("the rate limit should not be negative: %d", credential.RateLimit)
+	}
+	return nil
+}
@@ -17,11 +17,14 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"strings"
 
 	"github.com/beego/beego/utils/pagination"
 	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/rule"
 	"github.com/casbin/caswaf/util"
 	"github.com/hsluoyz/modsecurity-go/seclang/parser"
 )
@@ -98,7 +101,17 @@ func (c *ApiController) AddRule() {
 		c.ResponseError(err.Error())
 		return
 	}
-	err = checkExpressions(rule.Expressions, rule.Type)
+	err = checkExpressions(rule.Expressions, rule.Type, rule.GetId())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	err = checkRulePhase(&rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	err = checkRuleClearance(&rule)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
@@ -119,7 +132,17 @@ func (c *ApiController) UpdateRule() {
 		return
 	}
 
-	err = checkExpressions(rule.Expressions, rule.Type)
+	err = checkExpressions(rule.Expressions, rule.Type, rule.GetId())
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	err = checkRulePhase(&rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	err = checkRuleClearance(&rule)
 	if err != nil {
 		c.ResponseError(err.Error())
 		return
@@ -146,7 +169,186 @@ func (c *ApiController) DeleteRule() {
 	c.ServeJSON()
 }
 
-func checkExpressions(expressions []*object.Expression, ruleType string) error {
+type TestRulesRequest struct {
+	Site     string            `json:"site"`
+	Rules    []string          `json:"rules"`
+	Method   string            `json:"method"`
+	Url      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Body     string            `json:"body"`
+	ClientIp string            `json:"clientIp"`
+}
+
+func (c *ApiController) TestRules() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	var testRequest TestRulesRequest
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &testRequest)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	ruleIds := testRequest.Rules
+	var site *object.Site
+	if testRequest.Site != "" {
+		site, err = object.GetSite(testRequest.Site)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		if site == nil {
+			c.ResponseError(fmt.Sprintf("The site: %s does not exist", testRequest.Site))
+			return
+		}
+		ruleIds = site.Rules
+	}
+
+	req, err := newTestRequest(&testRequest)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	c.ResponseOk(rule.TraceRules(ruleIds, site, req))
+}
+
+func newTestRequest(testRequest *TestRulesRequest) (*http.Request, error) {
+	method := testRequest.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(method, testRequest.Url, strings.NewReader(testRequest.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range testRequest.Headers {
+		req.Header.Set(key, value)
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	req.RequestURI = req.URL.RequestURI()
+
+	if testRequest.ClientIp != "" {
+		if net.ParseIP(testRequest.ClientIp) == nil {
+			return nil, fmt.Errorf("invalid client IP: %s", testRequest.ClientIp)
+		}
+		req.RemoteAddr = net.JoinHostPort(testRequest.ClientIp, "0")
+	}
+	return req, nil
+}
+
+type CrsExclusionRequest struct {
+	Rule      string `json:"rule"`
+	Record    string `json:"record"`
+	WafRuleId int    `json:"wafRuleId"`
+	Path      string `json:"path"`
+	Target    string `json:"target"`
+}
+
+// AddCrsExclusion adds exclusions to a CRS rule. If a record is given, the path defaults to the path of
+// the record, and if no CRS rule ID is given either, every CRS match of the record is excluded.
+func (c *ApiController) AddCrsExclusion() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	var exclusionRequest CrsExclusionRequest
+	err := json.Unmarshal(c.Ctx.Input.RequestBody, &exclusionRequest)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	crsRule, err := object.GetRule(exclusionRequest.Rule)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+	if crsRule == nil || crsRule.Type != "CRS" {
+		c.ResponseError(fmt.Sprintf("The CRS rule: %s does not exist", exclusionRequest.Rule))
+		return
+	}
+
+	path := exclusionRequest.Path
+	exclusions := []*object.Expression{}
+	if exclusionRequest.Record != "" {
+		record, err := object.GetRecord("admin", exclusionRequest.Record)
+		if err != nil {
+			c.ResponseError(err.Error())
+			return
+		}
+		if record == nil {
+			c.ResponseError(fmt.Sprintf("The record: %s does not exist", exclusionRequest.Record))
+			return
+		}
+
+		if path == "" {
+			path = strings.SplitN(record.Path, "?", 2)[0]
+		}
+		if exclusionRequest.WafRuleId == 0 {
+			for _, wafMatch := range record.WafMatches {
+				if wafMatch.Rule == crsRule.GetId() {
+					exclusions = append(exclusions, rule.NewCrsExclusion(wafMatch.WafRuleId, path, wafMatch.Target))
+				}
+			}
+			if len(exclusions) == 0 {
+				c.ResponseError(fmt.Sprintf("The record: %d has no match of the CRS rule: %s", record.Id, crsRule.GetId()))
+				return
+			}
+		}
+	}
+	if exclusionRequest.WafRuleId != 0 {
+		exclusions = append(exclusions, rule.NewCrsExclusion(exclusionRequest.WafRuleId, path, exclusionRequest.Target))
+	}
+	if len(exclusions) == 0 {
+		c.ResponseError("The CRS rule ID should not be empty")
+		return
+	}
+
+	for _, exclusion := range exclusions {
+		if !containsExpression(crsRule.Expressions, exclusion) {
+			crsRule.Expressions = append(crsRule.Expressions, exclusion)
+		}
+	}
+	err = rule.CheckCrsRule(crsRule.Expressions)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	crsRule.UpdatedTime = util.GetCurrentTime()
+	c.Data["json"] = wrapActionResponse(object.UpdateRule(crsRule.GetId(), crsRule))
+	c.ServeJSON()
+}
+
+func (c *ApiController) GetCrsGroups() {
+	if c.RequireSignedIn() {
+		return
+	}
+
+	c.ResponseOk(rule.GetCrsGroups())
+}
+
+func containsExpression(expressions []*object.Expression, expression *object.Expression) bool {
+	for _, item := range expressions {
+		if item.Name == expression.Name && item.Operator == expression.Operator && item.Value == expression.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func checkExpressions(expressions []*object.Expression, ruleType string, ruleId string) error {
+	err := object.CheckRuleOperators(ruleType, expressions)
+	if err != nil {
+		return err
+	}
+
 	values := make([]string, len(expressions))
 	for i, expression := range expressions {
 		values[i] = expression.Value
@@ -159,7 +361,39 @@ func checkExpressions(expressions []*object.Expression, ruleType string) error {
 	case "IP Rate Limiting":
 		return checkIpRateRule(expressions)
 	case "Compound":
-		return checkCompoundRules(values)
+		return rule.CheckCompoundRule(ruleId, expressions)
+	case "CRS":
+		return rule.CheckCrsRule(expressions)
+	case "Header", "Cookie", "Query":
+		return checkRequestRule(expressions, ruleType)
+	case "Content-Length":
+		return checkContentLengthRule(expressions)
+	}
+	return nil
+}
+
+// checkRulePhase checks that only the rules of admin have a phase, which makes them global rules
+// evaluated before or after the site rules for every site
+func checkRulePhase(rule *object.Rule) error {
+	switch rule.Phase {
+	case "":
+		return nil
+	case "Before", "After":
+		if rule.Owner != "admin" {
+			return fmt.Errorf("only the rules of admin can have a phase, rule: %s", rule.GetId())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown rule phase: %s", rule.Phase)
+	}
+}
+
+func checkRuleClearance(rule *object.Rule) error {
+	if rule.ClearanceTtl < 0 {
+		return fmt.Errorf("the clearance TTL should not be negative: %d", rule.ClearanceTtl)
+	}
+	if rule.ClearanceBinding != "" && rule.ClearanceBinding != "IP" && rule.ClearanceBinding != "User-Agent" {
+		return fmt.Errorf("unknown clearance binding: %s", rule.ClearanceBinding)
 	}
 	return nil
 }
@@ -203,10 +437,24 @@ func checkIpRateRule(expressions []*object.Expression) error {
 	return nil
 }
 
-func checkCompoundRules(rules []string) error {
-	_, err := object.GetRulesByRuleIds(rules)
-	if err != nil {
-		return err
+func checkRequestRule(expressions []*object.Expression, ruleType string) error {
+	for _, expression := range expressions {
+		if strings.TrimSpace(expression.Name) == "" {
+			return fmt.Errorf("the %s name of expression should not be empty", strings.ToLower(ruleType))
+		}
+	}
+	return nil
+}
+
+func checkContentLengthRule(expressions []*object.Expression) error {
+	for _, expression := range expressions {
+		if expression.Operator == "exists" || expression.Operator == "does not exist" {
+			continue
+		}
+		_, err := util.ParseIntWithError(expression.Value)
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length value: %s", expression.Value)
+		}
 	}
 	return nil
 }
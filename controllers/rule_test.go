@@ -0,0 +1,73 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/casbin/caswaf/util"
+)
+
+func TestNewTestRequest(t *testing.T) {
+	req, err := newTestRequest(&TestRulesRequest{
+		Url:      "http://example.com/api/search?q=select",
+		Headers:  map[string]string{"Host": "www.example.org", "User-Agent": "curl/8.0"},
+		Body:     "hello",
+		ClientIp: "1.2.3.4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != http.MethodGet {
+		t.Errorf("Method = %s, want GET by default", req.Method)
+	}
+	if req.Host != "www.example.org" {
+		t.Errorf("Host = %s, want the Host header", req.Host)
+	}
+	if req.RequestURI != "/api/search?q=select" {
+		t.Errorf("RequestURI = %s, want /api/search?q=select", req.RequestURI)
+	}
+	if req.UserAgent() != "curl/8.0" {
+		t.Errorf("User-Agent = %s, want curl/8.0", req.UserAgent())
+	}
+	if clientIp := util.GetClientIp(req); clientIp != "1.2.3.4" {
+		t.Errorf("client IP = %s, want 1.2.3.4", clientIp)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil || string(body) != "hello" {
+		t.Errorf("Body = %q, %v, want hello", body, err)
+	}
+
+	req, err = newTestRequest(&TestRulesRequest{Method: http.MethodPost, Url: "http://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || req.RemoteAddr != "" {
+		t.Errorf("Method = %s, RemoteAddr = %s, want POST without client IP", req.Method, req.RemoteAddr)
+	}
+
+	for _, testRequest := range []*TestRulesRequest{
+		{Url: "http://example.com/", ClientIp: "not-an-ip"},
+		{Url: "http://example.com/%zz"},
+		{Method: "BAD METHOD", Url: "http://example.com/"},
+	} {
+		if _, err = newTestRequest(testRequest); err == nil {
+			t.Errorf("newTestRequest(%+v) error = nil, want an error", testRequest)
+		}
+	}
+}
@@ -16,8 +16,11 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/beego/beego/utils/pagination"
+	"github.com/casbin/caswaf/notifier"
 	"github.com/casbin/caswaf/object"
 	"github.com/casbin/caswaf/util"
 )
@@ -110,6 +113,18 @@ func (c *ApiController) UpdateSite() {
 		return
 	}
 
+	oldSite, err := object.GetSite(id)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
+	err = checkSite(&site, oldSite)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	c.Data["json"] = wrapActionResponse(object.UpdateSite(id, &site))
 	c.ServeJSON()
 }
@@ -126,6 +141,12 @@ func (c *ApiController) AddSite() {
 		return
 	}
 
+	err = checkSite(&site, nil)
+	if err != nil {
+		c.ResponseError(err.Error())
+		return
+	}
+
 	c.Data["json"] = wrapActionResponse(object.AddSite(&site))
 	c.ServeJSON()
 }
@@ -145,3 +166,77 @@ func (c *ApiController) DeleteSite() {
 	c.Data["json"] = wrapActionResponse(object.DeleteSite(&site))
 	c.ServeJSON()
 }
+
+// checkSite checks the site to add or update, the old site is nil for a site to add
+func checkSite(site *object.Site, oldSite *object.Site) error {
+	switch site.AuthMode {
+	case "":
+	case "Basic Auth", "API Key":
+		if site.CasdoorApplication != "" {
+			return fmt.Errorf("the auth mode: %s cannot be used with the Casdoor application: %s", site.AuthMode, site.CasdoorApplication)
+		}
+	default:
+		return fmt.Errorf("unknown auth mode: %s", site.AuthMode)
+	}
+
+	switch site.ClientAuthMode {
+	case "", "Off":
+	case "Optional", "Required":
+		if object.GetClientCaCert(site.ClientCaCert) == nil {
+			return fmt.Errorf("the client CA cert: %s is not found", site.ClientCaCert)
+		}
+	default:
+		return fmt.Errorf("unknown client auth mode: %s", site.ClientAuthMode)
+	}
+
+	switch site.CertChallenge {
+	case "", "HTTP-01", "TLS-ALPN-01":
+	default:
+		return fmt.Errorf("unknown cert challenge: %s", site.CertChallenge)
+	}
+
+	// the alert providers saved before they are checked are kept as they are, and skipped by the notifier
+	oldProviders := map[string]bool{}
+	if oldSite != nil {
+		for _, provider := range oldSite.AlertProviders {
+			oldProviders[provider] = true
+			oldProviders[notifier.MaskTarget(provider)] = true
+		}
+	}
+	providers := map[string]bool{}
+	for _, provider := range site.AlertProviders {
+		providers[provider] = true
+		if oldProviders[provider] {
+			continue
+		}
+
+		err := notifier.CheckTarget(provider)
+		if err != nil {
+			return err
+		}
+	}
+	for provider := range site.AlertSecrets {
+		if !providers[provider] {
+			return fmt.Errorf("the alert secret is set for an unknown alert provider: %s", provider)
+		}
+	}
+
+	if !object.IsValidCertKeyType(site.CertKeyType) {
+		return fmt.Errorf("unknown cert key type: %s", site.CertKeyType)
+	}
+
+	err := object.CheckAccessPolicy(site.AccessPolicy)
+	if err != nil {
+		return err
+	}
+
+	for _, header := range site.IdentityHeaders {
+		if !object.IsIdentityHeader(header) {
+			return fmt.Errorf("unknown identity header: %s", header)
+		}
+		if strings.EqualFold(header, object.IdentityJwtHeader) && site.IdentityJwtSecret == "" {
+			return fmt.Errorf("the identity JWT secret should not be empty when the X-Auth-Jwt header is enabled")
+		}
+	}
+	return nil
+}
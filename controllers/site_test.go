@@ -0,0 +1,47 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestCheckSiteAlertProviders(t *testing.T) {
+	oldSite := &object.Site{AlertProviders: []string{"provider_email", "Email/provider_email"}}
+
+	tests := []struct {
+		providers []string
+		secrets   map[string]string
+		oldSite   *object.Site
+		wantErr   bool
+	}{
+		{providers: []string{"Email/provider_email", "Webhook/https://example.com/hook"}},
+		{providers: []string{"provider_email"}, wantErr: true},
+		// the legacy providers are kept when the site is updated
+		{providers: []string{"provider_email", "Email/provider_email"}, oldSite: oldSite},
+		{providers: []string{"provider_email", "Webhook/example.com"}, oldSite: oldSite, wantErr: true},
+		{providers: []string{"Feishu/https://example.com/hook"}, secrets: map[string]string{"Feishu/https://example.com/hook": "secret"}},
+		{providers: []string{"Feishu/https://example.com/hook"}, secrets: map[string]string{"DingTalk/https://example.com/hook": "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		site := &object.Site{AlertProviders: tt.providers, AlertSecrets: tt.secrets}
+		if err := checkSite(site, tt.oldSite); (err != nil) != tt.wantErr {
+			t.Errorf("checkSite() providers = %v, error = %v, wantErr %v", tt.providers, err, tt.wantErr)
+		}
+	}
+}
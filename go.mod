@@ -5,15 +5,18 @@ go 1.16
 require (
 	github.com/Masterminds/squirrel v1.5.4
 	github.com/aliyun/alibaba-cloud-sdk-go v1.61.1183
+	github.com/aws/aws-sdk-go v1.39.0
 	github.com/beego/beego v1.12.12
+	github.com/casbin/casbin/v2 v2.77.2
 	github.com/casbin/lego/v4 v4.5.4
 	github.com/casdoor/casdoor-go-sdk v0.52.0
 	github.com/corazawaf/coraza/v3 v3.1.0
 	github.com/denisenkom/go-mssqldb v0.10.0
+	github.com/go-acme/lego/v4 v4.5.3
 	github.com/go-git/go-git/v5 v5.9.0
 	github.com/go-mysql-org/go-mysql v1.7.0
 	github.com/go-sql-driver/mysql v1.6.0
-	github.com/google/uuid v1.6.0
+	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/hsluoyz/modsecurity-go v0.0.7
 	github.com/lib/pq v1.10.2
 	github.com/likexian/whois v1.15.1
@@ -21,7 +24,9 @@ require (
 	github.com/siddontang/go-log v0.0.0-20190221022429-1e957dd83bed
 	github.com/xorm-io/core v0.7.4
 	github.com/xorm-io/xorm v1.1.6
+	golang.org/x/crypto v0.19.0
 	golang.org/x/net v0.21.0
+	golang.org/x/oauth2 v0.13.0
 	golang.org/x/text v0.14.0
 	golang.org/x/time v0.3.0
 	modernc.org/sqlite v1.18.1
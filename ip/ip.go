@@ -17,6 +17,7 @@ package ip
 import (
 	"fmt"
 
+	"github.com/casbin/caswaf/conf"
 	"github.com/casbin/caswaf/util"
 )
 
@@ -25,6 +26,24 @@ func InitIpDb() {
 	if err != nil {
 		panic(err)
 	}
+
+	// the IPv6 location database is not shipped, see "IPv6 location database" in README.md to obtain it.
+	// It is optional at the default path, but a configured path should exist
+	ipv6DbPath := conf.GetConfigString("ipv6DbPath")
+	if ipv6DbPath == "" {
+		ipv6DbPath = "ip/ipv6db.txt"
+		if !util.FileExist(ipv6DbPath) {
+			fmt.Printf("InitIpDb(): IPv6 location database not found: %s, IPv6 lookups are disabled\n", ipv6DbPath)
+			return
+		}
+	} else if !util.FileExist(ipv6DbPath) {
+		panic(fmt.Errorf("InitIpDb(): the IPv6 location database of ipv6DbPath is not found: %s", ipv6DbPath))
+	}
+
+	err = Init6(ipv6DbPath)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func IsAbroadIp(ip string) bool {
@@ -47,12 +47,6 @@ func InitWithData(data []byte) {
 	return
 }
 
-// Find locationInfo by ip string
-// It will return err when ipstr is not a valid format
-func Find(ipstr string) (*LocationInfo, error) {
-	return std.Find(ipstr)
-}
-
 // Find locationInfo by uint32
 func FindByUint(ip uint32) *LocationInfo {
 	return std.FindByUint(ip)
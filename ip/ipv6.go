@@ -0,0 +1,230 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+)
+
+var std6 *Ipv6Locator
+
+// Init6 default IPv6 locator with dataFile
+func Init6(dataFile string) (err error) {
+	if std6 != nil {
+		return
+	}
+	std6, err = NewIpv6Locator(dataFile)
+	return
+}
+
+// New IPv6 locator with dataFile
+//
+// The data file is a text file with one network per line:
+// "<cidr>\t<country>\t<region>\t<city>[\t<isp>]", blank lines and lines
+// starting with "#" are ignored.
+func NewIpv6Locator(dataFile string) (*Ipv6Locator, error) {
+	data, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewIpv6LocatorWithData(data)
+}
+
+// New IPv6 locator with data
+func NewIpv6LocatorWithData(data []byte) (*Ipv6Locator, error) {
+	loc := &Ipv6Locator{}
+	err := loc.init(data)
+	if err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+type ipv6Range struct {
+	start net.IP
+	end   net.IP
+	info  *LocationInfo
+}
+
+// Ipv6Locator finds the location of an IPv6 address, the nested networks of the data file are
+// flattened into disjoint ranges when loaded, so a lookup is a binary search
+type Ipv6Locator struct {
+	ranges []*ipv6Range
+}
+
+// Find locationInfo by ip string
+// It will return err when ipstr is not a valid IPv6 address
+func (loc *Ipv6Locator) Find(ipstr string) (*LocationInfo, error) {
+	ip := net.ParseIP(ipstr)
+	if ip == nil || ip.To4() != nil {
+		return nil, ErrInvalidIp
+	}
+
+	// find the last range whose start is not greater than ip, the ranges are disjoint
+	idx := sort.Search(len(loc.ranges), func(i int) bool {
+		return bytes.Compare(loc.ranges[i].start, ip) > 0
+	}) - 1
+	if idx >= 0 && bytes.Compare(ip, loc.ranges[idx].end) <= 0 {
+		return loc.ranges[idx].info, nil
+	}
+
+	return &LocationInfo{Country: Null, Region: Null, City: Null, Isp: Null}, nil
+}
+
+func (loc *Ipv6Locator) init(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 && len(fields) != 5 {
+			return fmt.Errorf("invalid ipv6 db line %d: %s", lineNo, line)
+		}
+
+		_, ipNet, err := net.ParseCIDR(fields[0])
+		if err != nil || ipNet.IP.To4() != nil {
+			return fmt.Errorf("invalid ipv6 network at line %d: %s", lineNo, fields[0])
+		}
+
+		start := ipNet.IP.To16()
+		end := make(net.IP, net.IPv6len)
+		for i := range start {
+			end[i] = start[i] | ^ipNet.Mask[i]
+		}
+
+		loc.ranges = append(loc.ranges, &ipv6Range{
+			start: start,
+			end:   end,
+			info:  newIpv6LocationInfo(fields[1:]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// the outer network comes before the networks nested in it
+	sort.SliceStable(loc.ranges, func(i, j int) bool {
+		res := bytes.Compare(loc.ranges[i].start, loc.ranges[j].start)
+		if res == 0 {
+			return bytes.Compare(loc.ranges[i].end, loc.ranges[j].end) > 0
+		}
+		return res < 0
+	})
+	loc.ranges = flattenIpv6Ranges(loc.ranges)
+	return nil
+}
+
+// flattenIpv6Ranges returns the disjoint ranges of the sorted networks, where the most specific network
+// containing an address wins, and the later one of the same networks wins as well
+func flattenIpv6Ranges(ranges []*ipv6Range) []*ipv6Range {
+	res := []*ipv6Range{}
+	stack := []*ipv6Range{}
+	// pos is the first address not flattened yet, it is nil after the last address
+	var pos net.IP
+
+	emit := func(end net.IP, info *LocationInfo) {
+		if pos == nil || bytes.Compare(pos, end) > 0 {
+			return
+		}
+		res = append(res, &ipv6Range{start: pos, end: end, info: info})
+		pos = getNextIp(end)
+	}
+
+	// closeTo closes the open networks ending before ip, or all of them if ip is nil
+	closeTo := func(ip net.IP) {
+		for len(stack) != 0 {
+			top := stack[len(stack)-1]
+			if ip != nil && bytes.Compare(top.end, ip) >= 0 {
+				return
+			}
+			emit(top.end, top.info)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for _, r := range ranges {
+		closeTo(r.start)
+		if len(stack) != 0 {
+			// the network is nested in the top one, whose addresses before it are flattened
+			emit(getPrevIp(r.start), stack[len(stack)-1].info)
+		}
+		pos = r.start
+		stack = append(stack, r)
+	}
+	closeTo(nil)
+	return res
+}
+
+// getNextIp returns the address after ip, or nil if ip is the last address
+func getNextIp(ip net.IP) net.IP {
+	res := make(net.IP, len(ip))
+	copy(res, ip)
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i]++
+		if res[i] != 0 {
+			return res
+		}
+	}
+	return nil
+}
+
+// getPrevIp returns the address before ip, which is not the first address
+func getPrevIp(ip net.IP) net.IP {
+	res := make(net.IP, len(ip))
+	copy(res, ip)
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i]--
+		if res[i] != 0xff {
+			return res
+		}
+	}
+	return res
+}
+
+func newIpv6LocationInfo(fields []string) *LocationInfo {
+	info := &LocationInfo{
+		Country: fields[0],
+		Region:  fields[1],
+		City:    fields[2],
+	}
+	if len(fields) == 4 {
+		info.Isp = fields[3]
+	}
+
+	if len(info.Country) == 0 {
+		info.Country = Null
+	}
+	if len(info.Region) == 0 {
+		info.Region = Null
+	}
+	if len(info.City) == 0 {
+		info.City = Null
+	}
+	if len(info.Isp) == 0 {
+		info.Isp = Null
+	}
+	return info
+}
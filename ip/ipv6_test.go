@@ -0,0 +1,142 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testIpv6Data = `# network	country	region	city	isp
+2001:db8::/32	中国	北京	北京	教育网
+2400:cb00::/32	美国	加利福尼亚	旧金山
+2001:db8:1::/48	中国	上海	上海	电信
+`
+
+func TestIpv6Locator_Find(t *testing.T) {
+	loc, err := NewIpv6LocatorWithData([]byte(testIpv6Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip      string
+		country string
+		city    string
+		isp     string
+		wantErr bool
+	}{
+		{ip: "2001:db8::1", country: "中国", city: "北京", isp: "教育网"},
+		{ip: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", country: "中国", city: "北京", isp: "教育网"},
+		{ip: "2400:cb00:2048:1::c629:d7a2", country: "美国", city: "旧金山", isp: Null},
+		{ip: "2001:db8:1::1", country: "中国", city: "上海", isp: "电信"},
+		{ip: "2001:db9::1", country: Null, city: Null, isp: Null},
+		{ip: "::1", country: Null, city: Null, isp: Null},
+		{ip: "1.2.3.4", wantErr: true},
+		{ip: "not-an-ip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info, err := loc.Find(tt.ip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if info.Country != tt.country || info.City != tt.city || info.Isp != tt.isp {
+				t.Errorf("Find() = %+v, want country = %s, city = %s, isp = %s", info, tt.country, tt.city, tt.isp)
+			}
+		})
+	}
+}
+
+func TestIpv6Locator_Flatten(t *testing.T) {
+	// the default network covers all the addresses, and the nested networks are more specific
+	data := "::/0\t其他\t\t\n" + testIpv6Data + "2001:db8:1:2::/64\t中国\t上海\t浦东\n"
+	loc, err := NewIpv6LocatorWithData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, r := range loc.ranges {
+		if i != 0 && bytes.Compare(loc.ranges[i-1].end, r.start) >= 0 {
+			t.Fatalf("the ranges %d and %d overlap", i-1, i)
+		}
+	}
+
+	tests := []struct {
+		ip   string
+		city string
+	}{
+		{"::", Null},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Null},
+		{"2001:db8::1", "北京"},
+		{"2001:db8:1::1", "上海"},
+		{"2001:db8:1:2::1", "浦东"},
+		{"2001:db8:1:3::1", "上海"},
+		{"2001:db8:2::1", "北京"},
+		{"2001:db9::1", Null},
+	}
+	for _, tt := range tests {
+		info, err := loc.Find(tt.ip)
+		if err != nil || info.City != tt.city {
+			t.Errorf("Find(%s) = %+v, %v, want city = %s", tt.ip, info, err, tt.city)
+		}
+		if tt.city == Null && info.Country != "其他" {
+			t.Errorf("Find(%s) = %+v, want the default network", tt.ip, info)
+		}
+	}
+}
+
+func TestNewIpv6LocatorWithData_Invalid(t *testing.T) {
+	invalidData := []string{
+		"2001:db8::/32\t中国",
+		"1.2.3.0/24\t中国\t北京\t北京",
+		"2001:db8::\t中国\t北京\t北京",
+	}
+
+	for _, data := range invalidData {
+		_, err := NewIpv6LocatorWithData([]byte(data))
+		if err == nil {
+			t.Errorf("NewIpv6LocatorWithData(%q) should return error", data)
+		}
+	}
+}
+
+func TestInitIpDbWithMissingIpv6Db(t *testing.T) {
+	// the IPv4 database is loaded from the path relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	t.Setenv("ipv6DbPath", "ip/missing_ipv6db.txt")
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "ip/missing_ipv6db.txt") {
+			t.Errorf("InitIpDb() panics with %v, want the error of the missing IPv6 database", r)
+		}
+	}()
+	InitIpDb()
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ip
+
+import (
+	"errors"
+	"net"
+)
+
+var ErrDbNotLoaded = errors.New("ip database not loaded")
+
+// IpLocator is a location backend for a single IP address family
+type IpLocator interface {
+	Find(ipstr string) (*LocationInfo, error)
+}
+
+// Find locationInfo by ip string, using the IPv4 or IPv6 backend depending on the address
+// It will return err when ipstr is not a valid format
+func Find(ipstr string) (*LocationInfo, error) {
+	locator, err := getLocator(ipstr)
+	if err != nil {
+		return nil, err
+	}
+	return locator.Find(ipstr)
+}
+
+func getLocator(ipstr string) (IpLocator, error) {
+	ip := net.ParseIP(ipstr)
+	if ip == nil {
+		return nil, ErrInvalidIp
+	}
+
+	if ip.To4() != nil {
+		if std == nil {
+			return nil, ErrDbNotLoaded
+		}
+		return std, nil
+	}
+
+	if std6 == nil {
+		return nil, ErrDbNotLoaded
+	}
+	return std6, nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/casbin/caswaf/object"
 	"github.com/casbin/caswaf/proxy"
 	"github.com/casbin/caswaf/routers"
+	"github.com/casbin/caswaf/rule"
 	"github.com/casbin/caswaf/run"
 	"github.com/casbin/caswaf/service"
 	"github.com/casbin/caswaf/util"
@@ -38,12 +39,19 @@ func main() {
 	casdoor.InitCasdoorConfig()
 	proxy.InitHttpClient()
 	ip.InitIpDb()
+	rule.InitCrs()
 	object.InitSiteMap()
 	object.InitRuleMap()
+	object.InitCredentialMap()
 	run.InitAppMap()
 	run.InitRdsClient()
 	run.InitSelfStart()
 	object.StartMonitorSitesLoop()
+	object.StartCertJobLoop()
+	object.StartCertAlertLoop()
+	object.StartNodeMonitorLoop()
+	rule.StartFlushRuleStatsLoop()
+	service.StartFlushCredentialUsageLoop()
 
 	beego.InsertFilter("*", beego.BeforeRouter, cors.Allow(&cors.Options{
 		AllowOrigins:     []string{"*"},
@@ -0,0 +1,316 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+// maxRespSize is the maximum size of the response read from an alert target
+const maxRespSize = 1 << 20
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// message is an event rendered for the channels, the recipient is the owner of the event looked up
+// once for all the Email and SMS targets
+type message struct {
+	event     *Event
+	title     string
+	content   string
+	secret    string
+	recipient func() (*casdoorsdk.User, error)
+}
+
+type channel struct {
+	isUrl bool
+	send  func(target string, msg *message) error
+}
+
+// channels are the alert provider types, an alert provider is "<type>/<target>", where the target is
+// the Casdoor provider name for Email and SMS, and the URL for the others
+var channels = map[string]*channel{
+	"Email":    {isUrl: false, send: sendEmail},
+	"SMS":      {isUrl: false, send: sendSms},
+	"Webhook":  {isUrl: true, send: sendWebhook},
+	"Slack":    {isUrl: true, send: sendSlack},
+	"Teams":    {isUrl: true, send: sendTeams},
+	"DingTalk": {isUrl: true, send: sendDingTalk},
+	"Feishu":   {isUrl: true, send: sendFeishu},
+	"HTTP":     {isUrl: true, send: sendHttp},
+}
+
+func parseTarget(target string) (string, string, error) {
+	tokens := strings.SplitN(target, "/", 2)
+	if len(tokens) != 2 || tokens[1] == "" {
+		return "", "", fmt.Errorf("the alert provider should be like \"<type>/<target>\": %s", target)
+	}
+	return tokens[0], tokens[1], nil
+}
+
+// CheckTarget checks the format and type of an alert provider
+func CheckTarget(target string) error {
+	channelType, value, err := parseTarget(target)
+	if err != nil {
+		return err
+	}
+
+	c, ok := channels[channelType]
+	if !ok {
+		return fmt.Errorf("unknown alert provider type: %s", channelType)
+	}
+
+	if c.isUrl && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+		return fmt.Errorf("the target of alert provider: %s should be an HTTP URL", target)
+	}
+	return nil
+}
+
+// redactUrl returns the scheme and host of the URL, as the URLs of Slack, Teams, DingTalk and Feishu
+// carry their tokens in the path or query
+func redactUrl(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return fmt.Sprintf("%s://%s/***", u.Scheme, u.Host)
+}
+
+// MaskTarget masks the URL of an alert provider to its host for the frontend, the hash of the URL tells
+// the masked providers of the same host apart and is restored by UnmaskTargets()
+func MaskTarget(target string) string {
+	channelType, value, err := parseTarget(target)
+	if err != nil {
+		return target
+	}
+	// the unknown types are masked too as their targets may be URLs
+	if c, ok := channels[channelType]; ok && !c.isUrl {
+		return target
+	}
+
+	hash := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("%s/%s%s", channelType, redactUrl(value), hex.EncodeToString(hash[:4]))
+}
+
+// UnmaskTargets returns the old alert providers by their masked ones
+func UnmaskTargets(oldTargets []string) map[string]string {
+	res := map[string]string{}
+	for _, target := range oldTargets {
+		res[MaskTarget(target)] = target
+	}
+	return res
+}
+
+// GetSignature returns the signature of a webhook body sent with the X-CasWAF-Timestamp header, which is
+// the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the alert secret of the webhook
+func GetSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendEmail(target string, msg *message) error {
+	user, err := msg.recipient()
+	if err != nil {
+		return err
+	}
+	return casdoorsdk.SendEmailByProvider(msg.title, msg.content, "CasWAF", target, user.Email)
+}
+
+func sendSms(target string, msg *message) error {
+	user, err := msg.recipient()
+	if err != nil {
+		return err
+	}
+	return casdoorsdk.SendSmsByProvider(msg.content, target, user.Phone)
+}
+
+func sendWebhook(target string, msg *message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":   msg.event.Type,
+		"owner":   msg.event.Owner,
+		"site":    msg.event.Site,
+		"subject": msg.event.Subject,
+		"title":   msg.title,
+		"content": msg.content,
+		"time":    msg.event.getTime(),
+		"data":    msg.event.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"X-CasWAF-Event":     msg.event.Type,
+		"X-CasWAF-Timestamp": timestamp,
+	}
+	if msg.secret != "" {
+		headers["X-CasWAF-Signature"] = GetSignature(msg.secret, timestamp, body)
+	}
+
+	_, err = postBody(target, "application/json", body, headers)
+	return err
+}
+
+func sendSlack(target string, msg *message) error {
+	return postJson(target, map[string]string{"text": fmt.Sprintf("*%s*\n%s", msg.title, msg.content)})
+}
+
+func sendTeams(target string, msg *message) error {
+	return postJson(target, map[string]string{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  msg.title,
+		"title":    msg.title,
+		"text":     msg.content,
+	})
+}
+
+// sendDingTalk posts to a DingTalk robot, whose signature is added to the URL if the secret is set,
+// see: https://open.dingtalk.com/document/robots/customize-robot-security-settings
+func sendDingTalk(target string, msg *message) error {
+	if msg.secret != "" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+
+		timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		mac := hmac.New(sha256.New, []byte(msg.secret))
+		mac.Write([]byte(timestamp + "\n" + msg.secret))
+
+		query := u.Query()
+		query.Set("timestamp", timestamp)
+		query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+
+	return postChatBot(target, map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": fmt.Sprintf("%s\n%s", msg.title, msg.content)},
+	})
+}
+
+// sendFeishu posts to a Feishu (Lark) bot, whose signature is added to the body if the secret is set,
+// see: https://open.feishu.cn/document/client-docs/bot-v3/add-custom-bot
+func sendFeishu(target string, msg *message) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": fmt.Sprintf("%s\n%s", msg.title, msg.content)},
+	}
+
+	if msg.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+msg.secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	return postChatBot(target, payload)
+}
+
+func sendHttp(target string, msg *message) error {
+	_, err := postBody(target, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%s\n%s", msg.title, msg.content)), nil)
+	return err
+}
+
+func postJson(target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = postBody(target, "application/json", body, nil)
+	return err
+}
+
+// postChatBot posts to DingTalk or Feishu, which return the errors with the status 200 and a non-zero code
+func postChatBot(target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	respBody, err := postBody(target, "application/json", body, nil)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(respBody, &resp) != nil {
+		return nil
+	}
+
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("the alert target: %s returns error: %d, %s", redactUrl(target), resp.ErrCode, resp.ErrMsg)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("the alert target: %s returns error: %d, %s", redactUrl(target), resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+func postBody(target string, contentType string, body []byte, headers map[string]string) ([]byte, error) {
+	// the errors of the request and client have the URL, so only the redacted one is returned
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("the alert target: %s is invalid", redactUrl(target))
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("the alert target: %s returns error: %v", redactUrl(target), err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxRespSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("the alert target: %s returns status: %s", redactUrl(target), resp.Status)
+	}
+	return respBody, nil
+}
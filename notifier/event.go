@@ -0,0 +1,56 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"strings"
+
+	"github.com/casbin/caswaf/util"
+)
+
+const (
+	EventHealthDown        = "Health Down"
+	EventHealthUp          = "Health Up"
+	EventCertExpiring      = "Certificate Expiring"
+	EventDomainExpiring    = "Domain Expiring"
+	EventCertRenewalFailed = "Certificate Renewal Failed"
+	EventAttackSpike       = "Attack Spike"
+	EventNodeOffline       = "Node Offline"
+	EventNodeOnline        = "Node Online"
+)
+
+// Event is what happened to a site, Subject is the domain, cert or node the event is about, and
+// Data holds the event-specific values which can be referenced by the templates as {{.Data.<key>}}
+type Event struct {
+	Type    string            `json:"type"`
+	Owner   string            `json:"owner"`
+	Site    string            `json:"site"`
+	Subject string            `json:"subject"`
+	Message string            `json:"message"`
+	Time    string            `json:"time"`
+	Data    map[string]string `json:"data"`
+}
+
+func (event *Event) getTime() string {
+	if event.Time == "" {
+		event.Time = util.GetCurrentTime()
+	}
+	return event.Time
+}
+
+// getDedupeKey identifies the same event of the same site and subject
+func (event *Event) getDedupeKey() string {
+	return strings.Join([]string{event.Type, event.Site, event.Subject}, "|")
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultDedupeMinutes = 30
+	defaultRateLimit     = 20
+)
+
+type targetLimiter struct {
+	rateLimit  int
+	limiter    *rate.Limiter
+	suppressed int
+}
+
+var (
+	dedupeMap    = map[string]time.Time{}
+	limiterMap   = map[string]*targetLimiter{}
+	throttleLock = &sync.Mutex{}
+)
+
+func getConfigInt(key string, defaultValue int) int {
+	s := conf.GetConfigString(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	res, err := strconv.Atoi(s)
+	if err != nil {
+		fmt.Printf("getConfigInt() error: invalid %s: %s\n", key, s)
+		return defaultValue
+	}
+	return res
+}
+
+// isDuplicate returns true if the same event has been notified within alertDedupeMinutes, e.g., a flapping
+// health check
+func isDuplicate(event *Event, now time.Time) bool {
+	window := time.Duration(getConfigInt("alertDedupeMinutes", defaultDedupeMinutes)) * time.Minute
+	if window <= 0 {
+		return false
+	}
+
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+
+	for key, t := range dedupeMap {
+		if now.Sub(t) >= window {
+			delete(dedupeMap, key)
+		}
+	}
+
+	_, ok := dedupeMap[event.getDedupeKey()]
+	return ok
+}
+
+// addDedupe records the event as notified, it is called once the event is sent to a target so that an
+// event failed to send to all the targets is retried by the next check
+func addDedupe(event *Event, now time.Time) {
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+
+	dedupeMap[event.getDedupeKey()] = now
+}
+
+// allowTarget applies the rate limit of alertRateLimit alerts per hour to the target, it returns the
+// number of the alerts suppressed since the last allowed one
+func allowTarget(target string, now time.Time) (bool, int) {
+	rateLimit := getConfigInt("alertRateLimit", defaultRateLimit)
+	if rateLimit <= 0 {
+		return true, 0
+	}
+
+	throttleLock.Lock()
+	defer throttleLock.Unlock()
+
+	l, ok := limiterMap[target]
+	if !ok || l.rateLimit != rateLimit {
+		l = &targetLimiter{
+			rateLimit: rateLimit,
+			limiter:   rate.NewLimiter(rate.Every(time.Hour/time.Duration(rateLimit)), rateLimit),
+		}
+		limiterMap[target] = l
+	}
+
+	if !l.limiter.AllowN(now, 1) {
+		l.suppressed++
+		return false, 0
+	}
+
+	suppressed := l.suppressed
+	l.suppressed = 0
+	return true, suppressed
+}
+
+func newRecipient(owner string) func() (*casdoorsdk.User, error) {
+	var user *casdoorsdk.User
+	return func() (*casdoorsdk.User, error) {
+		if user != nil {
+			return user, nil
+		}
+
+		var err error
+		user, err = casdoorsdk.GetUser(owner)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user: %s is not found", owner)
+		}
+		return user, nil
+	}
+}
+
+// Notify renders the event by the template of its type and sends it to the targets, which are the
+// alert providers like "Webhook/<url>", the secrets keyed by target sign the Webhook, DingTalk and
+// Feishu messages, as each robot of DingTalk and Feishu has its own secret.
+// A duplicate event within alertDedupeMinutes is dropped, and the alerts exceeding alertRateLimit
+// per hour of a target are suppressed and counted in the next alert of the target
+func Notify(event *Event, targets []string, secrets map[string]string) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	event.getTime()
+	if isDuplicate(event, now) {
+		return nil
+	}
+
+	title, content, err := render(event)
+	if err != nil {
+		return err
+	}
+
+	recipient := newRecipient(event.Owner)
+	isSent := false
+	errs := []string{}
+	for _, target := range targets {
+		// the invalid targets saved before the targets are checked are skipped as before
+		err = CheckTarget(target)
+		if err != nil {
+			fmt.Printf("Notify(): the alert provider: %s is skipped, it should be like \"<type>/<target>\" of a known type\n", MaskTarget(target))
+			continue
+		}
+
+		channelType, value, _ := parseTarget(target)
+		c := channels[channelType]
+
+		allowed, suppressed := allowTarget(target, now)
+		if !allowed {
+			fmt.Printf("Notify(): the alert: %s to %s is suppressed by the rate limit\n", event.Type, channelType)
+			continue
+		}
+
+		msg := &message{event: event, title: title, content: content, secret: secrets[target], recipient: recipient}
+		if suppressed != 0 {
+			msg.content = fmt.Sprintf("%s\n(%d more alerts were suppressed by the rate limit)", content, suppressed)
+		}
+
+		err = c.send(value, msg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", channelType, err))
+			continue
+		}
+		isSent = true
+	}
+
+	if isSent {
+		addDedupe(event, now)
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("Notify() error for event: %s of site: %s, %s", event.Type, event.Site, strings.Join(errs, "; "))
+	}
+	return nil
+}
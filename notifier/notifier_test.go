@@ -0,0 +1,293 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	header http.Header
+	query  string
+	body   string
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, map[string]*testRequest) {
+	requests := map[string]*testRequest{}
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		requests[r.URL.Path] = &testRequest{header: r.Header, query: r.URL.RawQuery, body: string(body)}
+		lock.Unlock()
+
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/dingtalk":
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		case "/feishu-fail":
+			w.Write([]byte(`{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// getTestSign returns the signature of DingTalk or Feishu robots, see: sendDingTalk() and sendFeishu()
+func getTestSign(secret string, timestamp string, isDingTalk bool) string {
+	var mac hash.Hash
+	if isDingTalk {
+		mac = hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "\n" + secret))
+	} else {
+		mac = hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func resetThrottle() {
+	throttleLock.Lock()
+	dedupeMap = map[string]time.Time{}
+	limiterMap = map[string]*targetLimiter{}
+	throttleLock.Unlock()
+}
+
+func TestCheckTarget(t *testing.T) {
+	for _, target := range []string{"Email/provider_email", "SMS/provider_sms", "Webhook/https://example.com/hook", "DingTalk/https://oapi.dingtalk.com/robot/send?access_token=x"} {
+		if err := CheckTarget(target); err != nil {
+			t.Errorf("CheckTarget(%s) error = %v", target, err)
+		}
+	}
+
+	for _, target := range []string{"Webhook", "Email/", "Pager/x", "Slack/example.com", "Feishu/ftp://example.com"} {
+		if err := CheckTarget(target); err == nil {
+			t.Errorf("CheckTarget(%s) should return an error", target)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	resetThrottle()
+	server, requests := newTestServer(t)
+
+	event := &Event{
+		Type:    EventHealthDown,
+		Owner:   "admin",
+		Site:    "admin/site",
+		Subject: "example.com",
+		Message: "connection refused",
+	}
+	targets := []string{
+		"Webhook/" + server.URL + "/webhook",
+		"Slack/" + server.URL + "/slack",
+		"Teams/" + server.URL + "/teams",
+		"DingTalk/" + server.URL + "/dingtalk?access_token=token",
+		"Feishu/" + server.URL + "/feishu",
+		"HTTP/" + server.URL + "/http",
+	}
+	secrets := map[string]string{targets[0]: "webhook-secret", targets[3]: "dingtalk-secret", targets[4]: "feishu-secret"}
+	err := Notify(event, targets, secrets)
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	webhook := requests["/webhook"]
+	timestamp := webhook.header.Get("X-CasWAF-Timestamp")
+	if got, want := webhook.header.Get("X-CasWAF-Signature"), GetSignature("webhook-secret", timestamp, []byte(webhook.body)); got != want {
+		t.Errorf("X-CasWAF-Signature = %s, want %s", got, want)
+	}
+	var payload map[string]interface{}
+	err = json.Unmarshal([]byte(webhook.body), &payload)
+	if err != nil || payload["event"] != EventHealthDown || payload["site"] != "admin/site" || payload["title"] != "CasWAF Health Check Alert: example.com is down" {
+		t.Errorf("the webhook payload = %s", webhook.body)
+	}
+
+	if body := requests["/slack"].body; !strings.Contains(body, "connection refused") {
+		t.Errorf("the Slack payload = %s", body)
+	}
+	if body := requests["/teams"].body; !strings.Contains(body, `"@type":"MessageCard"`) {
+		t.Errorf("the Teams payload = %s", body)
+	}
+
+	dingTalk := requests["/dingtalk"]
+	query, _ := url.ParseQuery(dingTalk.query)
+	if query.Get("access_token") != "token" || query.Get("sign") != getTestSign("dingtalk-secret", query.Get("timestamp"), true) || !strings.Contains(dingTalk.body, `"msgtype":"text"`) {
+		t.Errorf("the DingTalk request = %s, %s", dingTalk.query, dingTalk.body)
+	}
+	var feishu map[string]interface{}
+	err = json.Unmarshal([]byte(requests["/feishu"].body), &feishu)
+	if err != nil || feishu["msg_type"] != "text" || feishu["sign"] != getTestSign("feishu-secret", feishu["timestamp"].(string), false) {
+		t.Errorf("the Feishu payload = %s", requests["/feishu"].body)
+	}
+
+	want := "CasWAF Health Check Alert: example.com is down\nCasWAF health check failed for domain example.com, connection refused"
+	if body := requests["/http"].body; body != want {
+		t.Errorf("the HTTP payload = %s, want %s", body, want)
+	}
+	if header := requests["/http"].header.Get("X-CasWAF-Signature"); header != "" {
+		t.Errorf("the HTTP target without secret is signed: %s", header)
+	}
+
+	// the invalid targets saved before the targets are checked are skipped
+	delete(requests, "/http")
+	event = &Event{Type: EventNodeOnline, Site: "admin/site", Subject: "node1"}
+	err = Notify(event, []string{"Pager/x", "provider_email", "HTTP/" + server.URL + "/http"}, nil)
+	if err != nil || requests["/http"] == nil {
+		t.Errorf("Notify() error = %v, want the invalid targets skipped", err)
+	}
+
+	event = &Event{Type: EventHealthUp, Site: "admin/site", Subject: "example.com"}
+	err = Notify(event, []string{"Webhook/" + server.URL + "/fail", "Feishu/" + server.URL + "/feishu-fail?token=feishu-token"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Webhook") || !strings.Contains(err.Error(), "19021") {
+		t.Errorf("Notify() error = %v, want the errors of both targets", err)
+	}
+	// the tokens in the URLs are never in the errors
+	if strings.Contains(err.Error(), "/fail") || strings.Contains(err.Error(), "feishu-token") {
+		t.Errorf("Notify() error = %v, want the URLs redacted", err)
+	}
+
+	event = &Event{Type: EventHealthDown, Site: "admin/site", Subject: "example.org"}
+	err = Notify(event, []string{"Slack/http://127.0.0.1:1/services/slack-token"}, nil)
+	if err == nil || strings.Contains(err.Error(), "slack-token") {
+		t.Errorf("Notify() error = %v, want the URL redacted", err)
+	}
+}
+
+func TestMaskTarget(t *testing.T) {
+	slack := "Slack/https://hooks.slack.com/services/token"
+	masked := MaskTarget(slack)
+	if !strings.HasPrefix(masked, "Slack/https://hooks.slack.com/***") || strings.Contains(masked, "token") {
+		t.Errorf("MaskTarget(%s) = %s", slack, masked)
+	}
+	if other := MaskTarget("Slack/https://hooks.slack.com/services/other"); other == masked {
+		t.Errorf("MaskTarget() should tell the targets of the same host apart")
+	}
+	if got := MaskTarget("Email/provider_email"); got != "Email/provider_email" {
+		t.Errorf("MaskTarget() = %s, want the Email target kept", got)
+	}
+	if got := MaskTarget("Pager/https://example.com/token"); strings.Contains(got, "token") {
+		t.Errorf("MaskTarget() = %s, want the target of an unknown type masked", got)
+	}
+
+	if got := UnmaskTargets([]string{slack, "Email/provider_email"})[masked]; got != slack {
+		t.Errorf("UnmaskTargets()[%s] = %s, want %s", masked, got, slack)
+	}
+}
+
+func TestNotifyDedupe(t *testing.T) {
+	resetThrottle()
+	server, requests := newTestServer(t)
+	targets := []string{"HTTP/" + server.URL + "/http"}
+
+	err := Notify(&Event{Type: EventAttackSpike, Site: "admin/site", Subject: "example.com", Message: "first"}, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Notify(&Event{Type: EventAttackSpike, Site: "admin/site", Subject: "example.com", Message: "second"}, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["/http"].body; !strings.HasSuffix(body, "first") {
+		t.Errorf("the duplicate event is sent: %s", body)
+	}
+
+	err = Notify(&Event{Type: EventAttackSpike, Site: "admin/other", Subject: "example.com", Message: "other"}, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := requests["/http"].body; !strings.HasSuffix(body, "other") {
+		t.Errorf("the event of another site is deduplicated: %s", body)
+	}
+
+	// the event failed to send to all the targets is not deduplicated
+	failed := []string{"HTTP/" + server.URL + "/fail"}
+	event := &Event{Type: EventNodeOffline, Site: "admin/site", Subject: "node1"}
+	for i := 0; i < 2; i++ {
+		delete(requests, "/fail")
+		if err = Notify(event, failed, nil); err == nil {
+			t.Fatalf("Notify() #%d should return the error of the failed target", i)
+		}
+		if requests["/fail"] == nil {
+			t.Fatalf("Notify() #%d does not retry the event failed to send", i)
+		}
+	}
+
+	// and the one sent to any of the targets is
+	err = Notify(event, append(failed, targets...), nil)
+	if err == nil {
+		t.Fatalf("Notify() should return the error of the failed target")
+	}
+	delete(requests, "/fail")
+	err = Notify(event, failed, nil)
+	if err != nil || requests["/fail"] != nil {
+		t.Errorf("Notify() = %v, the event sent to a target is not deduplicated", err)
+	}
+}
+
+func TestAllowTarget(t *testing.T) {
+	resetThrottle()
+	t.Setenv("alertRateLimit", "2")
+
+	now := time.Now()
+	for i, want := range []bool{true, true, false, false} {
+		if allowed, _ := allowTarget("HTTP/x", now); allowed != want {
+			t.Errorf("allowTarget() #%d = %v, want %v", i, allowed, want)
+		}
+	}
+
+	allowed, suppressed := allowTarget("HTTP/x", now.Add(31*time.Minute))
+	if !allowed || suppressed != 2 {
+		t.Errorf("allowTarget() = %v, %d, want true, 2", allowed, suppressed)
+	}
+
+	if allowed, _ = allowTarget("HTTP/y", now); !allowed {
+		t.Errorf("allowTarget() limits another target")
+	}
+}
+
+func TestSetTemplate(t *testing.T) {
+	err := SetTemplate(EventNodeOffline, "{{.Subject}} is offline", "since {{.Data.heartbeatTime}}{{.Data.missing}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetTemplate(EventNodeOffline, defaultTemplates[EventNodeOffline].Title, defaultTemplates[EventNodeOffline].Content)
+
+	title, content, err := render(&Event{Type: EventNodeOffline, Subject: "node1", Data: map[string]string{"heartbeatTime": "2024-01-01T00:00:00Z"}})
+	if err != nil || title != "node1 is offline" || content != "since 2024-01-01T00:00:00Z" {
+		t.Errorf("render() = %s, %s, %v", title, content, err)
+	}
+
+	title, _, _ = render(&Event{Type: "Unknown"})
+	if title != "CasWAF Alert: Unknown" {
+		t.Errorf("render() title = %s, want the default template", title)
+	}
+
+	if err = SetTemplate(EventNodeOffline, "{{.Subject", ""); err == nil {
+		t.Errorf("SetTemplate() should return the error of an invalid template")
+	}
+}
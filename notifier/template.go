@@ -0,0 +1,161 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"text/template"
+
+	"github.com/casbin/caswaf/conf"
+)
+
+type messageTemplate struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+
+	title   *template.Template
+	content *template.Template
+}
+
+var defaultTemplates = map[string]*messageTemplate{
+	EventHealthDown: {
+		Title:   "CasWAF Health Check Alert: {{.Subject}} is down",
+		Content: "CasWAF health check failed for domain {{.Subject}}, {{.Message}}",
+	},
+	EventHealthUp: {
+		Title:   "CasWAF Health Check Recovered: {{.Subject}} is up",
+		Content: "CasWAF health check succeeded for domain {{.Subject}} again{{if .Data.downTime}}, it has been down since {{.Data.downTime}}{{end}}",
+	},
+	EventCertExpiring:      {Title: "CasWAF Certificate Expiry Alert: {{.Subject}}", Content: "{{.Message}}"},
+	EventDomainExpiring:    {Title: "CasWAF Domain Expiry Alert: {{.Subject}}", Content: "{{.Message}}"},
+	EventCertRenewalFailed: {Title: "CasWAF Certificate Renewal Alert: {{.Subject}}", Content: "{{.Message}}"},
+	EventAttackSpike:       {Title: "CasWAF Attack Spike Alert: {{.Subject}}", Content: "{{.Message}}"},
+	EventNodeOffline:       {Title: "CasWAF Node Offline Alert: {{.Subject}}", Content: "{{.Message}}"},
+	EventNodeOnline:        {Title: "CasWAF Node Online: {{.Subject}}", Content: "{{.Message}}"},
+	"":                     {Title: "CasWAF Alert: {{.Type}}", Content: "{{.Message}}"},
+}
+
+var (
+	templates     = map[string]*messageTemplate{}
+	templatesLock = &sync.RWMutex{}
+	templatesOnce = &sync.Once{}
+)
+
+func (t *messageTemplate) parse(eventType string) error {
+	var err error
+	t.title, err = template.New(eventType + " title").Option("missingkey=zero").Parse(t.Title)
+	if err != nil {
+		return err
+	}
+	t.content, err = template.New(eventType + " content").Option("missingkey=zero").Parse(t.Content)
+	return err
+}
+
+// SetTemplate overrides the title and content templates of the event type, the templates are
+// text/template executed on the Event
+func SetTemplate(eventType string, title string, content string) error {
+	templatesOnce.Do(initTemplates)
+	return setTemplate(eventType, title, content)
+}
+
+func setTemplate(eventType string, title string, content string) error {
+	t := &messageTemplate{Title: title, Content: content}
+	err := t.parse(eventType)
+	if err != nil {
+		return err
+	}
+
+	templatesLock.Lock()
+	templates[eventType] = t
+	templatesLock.Unlock()
+	return nil
+}
+
+// initTemplates parses the default templates and the ones of alertTemplateFile, which is a JSON file like:
+// {"Health Down": {"title": "...", "content": "..."}}
+func initTemplates() {
+	for eventType, t := range defaultTemplates {
+		err := setTemplate(eventType, t.Title, t.Content)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	path := conf.GetConfigString("alertTemplateFile")
+	if path == "" {
+		return
+	}
+
+	err := loadTemplateFile(path)
+	if err != nil {
+		fmt.Printf("initTemplates() error: %v\n", err)
+	}
+}
+
+func loadTemplateFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	m := map[string]*messageTemplate{}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+
+	for eventType, t := range m {
+		err = setTemplate(eventType, t.Title, t.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getTemplate(eventType string) *messageTemplate {
+	templatesOnce.Do(initTemplates)
+
+	templatesLock.RLock()
+	defer templatesLock.RUnlock()
+
+	if t, ok := templates[eventType]; ok {
+		return t
+	}
+	return templates[""]
+}
+
+// render returns the title and content of the event by the template of its type
+func render(event *Event) (string, string, error) {
+	t := getTemplate(event.Type)
+
+	title := &bytes.Buffer{}
+	err := t.title.Execute(title, event)
+	if err != nil {
+		return "", "", err
+	}
+
+	content := &bytes.Buffer{}
+	err = t.content.Execute(content, event)
+	if err != nil {
+		return "", "", err
+	}
+
+	return title.String(), content.String(), nil
+}
@@ -39,8 +39,25 @@ type Cert struct {
 	AccessKey    string `xorm:"varchar(100)" json:"accessKey"`
 	AccessSecret string `xorm:"varchar(100)" json:"accessSecret"`
 
+	// the credentials of the DNS provider, see certificate.GetDnsProviderFields()
+	Credentials map[string]string `xorm:"mediumtext" json:"credentials"`
+
+	// the ACME CA issuing the cert, the global one configured by acmeDirectoryUrl is used if empty
+	AcmeDirectoryUrl string `xorm:"varchar(200)" json:"acmeDirectoryUrl"`
+	AcmeEabKeyId     string `xorm:"varchar(200)" json:"acmeEabKeyId"`
+	AcmeEabHmacKey   string `xorm:"varchar(200)" json:"acmeEabHmacKey"`
+	AcmeCaBundle     string `xorm:"mediumtext" json:"acmeCaBundle"`
+
+	// the last expiry alerts as "<expire time>/<threshold>", see checkCertAlerts()
+	ExpireAlert       string `xorm:"varchar(100)" json:"expireAlert"`
+	DomainExpireAlert string `xorm:"varchar(100)" json:"domainExpireAlert"`
+
 	Certificate string `xorm:"mediumtext" json:"certificate"`
 	PrivateKey  string `xorm:"mediumtext" json:"privateKey"`
+
+	// the ECDSA certificate served instead of the RSA one to the clients supporting ECDSA
+	EccCertificate string `xorm:"mediumtext" json:"eccCertificate"`
+	EccPrivateKey  string `xorm:"mediumtext" json:"eccPrivateKey"`
 }
 
 func GetGlobalCerts() ([]*Cert, error) {
@@ -100,6 +117,15 @@ func GetMaskedCert(cert *Cert) *Cert {
 	if cert.AccessSecret != "" {
 		cert.AccessSecret = "***"
 	}
+	if cert.AcmeEabHmacKey != "" {
+		cert.AcmeEabHmacKey = "***"
+	}
+
+	for _, field := range certificate.GetDnsProviderFields(cert.Provider) {
+		if field.IsSecret && cert.Credentials[field.Name] != "" {
+			cert.Credentials[field.Name] = "***"
+		}
+	}
 
 	return cert
 }
@@ -113,10 +139,24 @@ func GetMaskedCerts(certs []*Cert) []*Cert {
 
 func UpdateCert(id string, cert *Cert) (bool, error) {
 	owner, name := util.GetOwnerAndNameFromId(id)
-	if c, err := getCert(owner, name); err != nil || c == nil {
+	c, err := getCert(owner, name)
+	if err != nil || c == nil {
 		return false, err
 	}
 
+	// the masked secrets are not changed
+	if cert.AccessSecret == "***" {
+		cert.AccessSecret = c.AccessSecret
+	}
+	if cert.AcmeEabHmacKey == "***" {
+		cert.AcmeEabHmacKey = c.AcmeEabHmacKey
+	}
+	for key, value := range cert.Credentials {
+		if value == "***" {
+			cert.Credentials[key] = c.Credentials[key]
+		}
+	}
+
 	if cert.Certificate != "" {
 		expireTime, err := getCertExpireTime(cert.Certificate)
 		if err != nil {
@@ -128,7 +168,12 @@ func UpdateCert(id string, cert *Cert) (bool, error) {
 		cert.ExpireTime = ""
 	}
 
-	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Update(cert)
+	_, err = ormer.Engine.ID(core.PK{owner, name}).AllCols().Omit(certAlertCols...).Update(cert)
+	if err != nil {
+		return false, err
+	}
+
+	err = refreshCertMap()
 	if err != nil {
 		return false, err
 	}
@@ -142,6 +187,13 @@ func AddCert(cert *Cert) (bool, error) {
 		return false, err
 	}
 
+	if affected != 0 {
+		err = refreshCertMap()
+		if err != nil {
+			return false, err
+		}
+	}
+
 	return affected != 0, nil
 }
 
@@ -151,6 +203,13 @@ func DeleteCert(cert *Cert) (bool, error) {
 		return false, err
 	}
 
+	if affected != 0 {
+		err = refreshCertMap()
+		if err != nil {
+			return false, err
+		}
+	}
+
 	return affected != 0, nil
 }
 
@@ -164,18 +223,14 @@ func RenewCert(cert *Cert) (bool, error) {
 		useProxy = true
 	}
 
-	client, err := GetAcmeClient(useProxy)
+	client, err := GetAcmeClient(cert, useProxy)
 	if err != nil {
 		return false, err
 	}
 
-	var certStr, privateKey string
-	if cert.Provider == "Aliyun" {
-		certStr, privateKey = certificate.ObtainCertificateAli(client, cert.Name, cert.AccessKey, cert.AccessSecret)
-	} else if cert.Provider == "GoDaddy" {
-		certStr, privateKey = certificate.ObtainCertificateGoDaddy(client, cert.Name, cert.AccessKey, cert.AccessSecret)
-	} else {
-		return false, fmt.Errorf("unknown provider: %s", cert.Provider)
+	certStr, privateKey, err := certificate.ObtainCertificateByDns(client, cert.Provider, cert.Name, cert.getDnsCredentials())
+	if err != nil {
+		return false, err
 	}
 
 	expireTime, err := getCertExpireTime(certStr)
@@ -190,6 +245,23 @@ func RenewCert(cert *Cert) (bool, error) {
 	return UpdateCert(cert.GetId(), cert)
 }
 
+// getDnsCredentials returns the credentials of the DNS provider, the certs created before the credentials
+// map only have the access key and secret
+func (cert *Cert) getDnsCredentials() map[string]string {
+	if len(cert.Credentials) != 0 {
+		return cert.Credentials
+	}
+
+	switch cert.Provider {
+	case "Aliyun":
+		return map[string]string{"accessKey": cert.AccessKey, "secretKey": cert.AccessSecret}
+	case "GoDaddy":
+		return map[string]string{"apiKey": cert.AccessKey, "apiSecret": cert.AccessSecret}
+	default:
+		return map[string]string{}
+	}
+}
+
 func (cert *Cert) isCertNearExpire() (bool, error) {
 	if cert.ExpireTime == "" {
 		return true, nil
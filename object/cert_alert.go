@@ -0,0 +1,223 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/notifier"
+	"github.com/casbin/caswaf/util"
+	"github.com/xorm-io/core"
+)
+
+const certAlertInterval = time.Hour
+
+// certAlertCols are maintained by checkCertAlerts() and not overwritten by UpdateCert()
+var certAlertCols = []string{"expire_alert", "domain_expire_alert"}
+
+// getCertAlertDays returns the thresholds in days before the expiry to alert, configured by certAlertDays
+// as "30,14,7,1", in descending order
+func getCertAlertDays() []int {
+	s := conf.GetConfigString("certAlertDays")
+	if s == "" {
+		s = "30,14,7,1"
+	}
+
+	res := []int{}
+	for _, token := range strings.Split(s, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(token))
+		if err != nil || days < 0 {
+			fmt.Printf("getCertAlertDays() error: invalid threshold: %s\n", token)
+			continue
+		}
+		res = append(res, days)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(res)))
+	return res
+}
+
+// getCertAlertThreshold returns the smallest threshold reached by the expire time and the days left,
+// it returns false if no threshold is reached, and the threshold is -1 if the time has expired
+func getCertAlertThreshold(expireTime string, alertDays []int, now time.Time) (int, int, bool) {
+	t, err := time.Parse(time.RFC3339, expireTime)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	left := t.Sub(now)
+	daysLeft := int(left.Hours() / 24)
+	if left < 0 {
+		return -1, daysLeft, true
+	}
+
+	threshold, ok := 0, false
+	for _, days := range alertDays {
+		if daysLeft <= days {
+			threshold, ok = days, true
+		}
+	}
+	return threshold, daysLeft, ok
+}
+
+// claimCertAlert records the alert of the cert, it returns false if the alert has been sent, e.g., by another node
+func claimCertAlert(cert *Cert, col string, alert string) (bool, error) {
+	affected, err := ormer.Engine.Table(new(Cert)).ID(core.PK{cert.Owner, cert.Name}).Where(fmt.Sprintf("%s is null or %s <> ?", col, col), alert).
+		Update(map[string]interface{}{col: alert})
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+// releaseCertAlert clears the claim of the alert failed to send, so that it is sent again by the next check
+func releaseCertAlert(cert *Cert, col string, alert string) error {
+	_, err := ormer.Engine.Table(new(Cert)).ID(core.PK{cert.Owner, cert.Name}).Where(fmt.Sprintf("%s = ?", col), alert).
+		Update(map[string]interface{}{col: ""})
+	return err
+}
+
+func sendCertAlert(cert *Cert, event *notifier.Event) error {
+	sites, err := getCertSites(cert)
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, site := range sites {
+		if !site.isAlertEnabled() {
+			continue
+		}
+
+		// the owner and site of the event are set by SendSiteAlert(), so each site has its own copy
+		err = SendSiteAlert(site, &notifier.Event{Type: event.Type, Subject: cert.Name, Message: event.Message, Data: event.Data})
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func checkCertExpireAlert(cert *Cert, eventType string, kind string, expireTime string, col string, alertDays []int) error {
+	threshold, daysLeft, ok := getCertAlertThreshold(expireTime, alertDays, time.Now())
+	if !ok {
+		return nil
+	}
+
+	alert := fmt.Sprintf("%s/%d", expireTime, threshold)
+	ok, err := claimCertAlert(cert, col, alert)
+	if err != nil || !ok {
+		return err
+	}
+
+	message := fmt.Sprintf("The %s of cert: %s expires in %d days at %s", strings.ToLower(kind), cert.Name, daysLeft, expireTime)
+	if threshold == -1 {
+		message = fmt.Sprintf("The %s of cert: %s has expired at %s", strings.ToLower(kind), cert.Name, expireTime)
+	}
+	err = sendCertAlert(cert, &notifier.Event{
+		Type:    eventType,
+		Message: message,
+		Data: map[string]string{
+			"expireTime": expireTime,
+			"daysLeft":   strconv.Itoa(daysLeft),
+			"threshold":  strconv.Itoa(threshold),
+		},
+	})
+	if err != nil {
+		releaseErr := releaseCertAlert(cert, col, alert)
+		if releaseErr != nil {
+			return fmt.Errorf("%v; releaseCertAlert() error: %v", err, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkCertAlerts alerts the sites of the certs whose certificate or domain registration reaches a threshold
+// of certAlertDays, once per threshold and expire time
+func checkCertAlerts() error {
+	certs, err := GetGlobalCerts()
+	if err != nil {
+		return err
+	}
+
+	alertDays := getCertAlertDays()
+	for _, cert := range certs {
+		if cert.Type == "Client CA" {
+			continue
+		}
+
+		err = checkCertExpireAlert(cert, notifier.EventCertExpiring, "Certificate", cert.ExpireTime, "expire_alert", alertDays)
+		if err != nil {
+			fmt.Printf("checkCertExpireAlert() error: %v, cert: %s\n", err, cert.GetId())
+		}
+
+		err = checkCertExpireAlert(cert, notifier.EventDomainExpiring, "Domain", cert.DomainExpireTime, "domain_expire_alert", alertDays)
+		if err != nil {
+			fmt.Printf("checkCertExpireAlert() error: %v, cert: %s\n", err, cert.GetId())
+		}
+	}
+	return nil
+}
+
+// alertCertJobFailed alerts the sites of the cert whose job has failed after all the attempts
+func alertCertJobFailed(job *CertJob) error {
+	cert, err := getCert(job.Owner, job.Cert)
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		cert = &Cert{Owner: job.Owner, Name: job.Cert}
+	}
+
+	return sendCertAlert(cert, &notifier.Event{
+		Type:    notifier.EventCertRenewalFailed,
+		Message: fmt.Sprintf("The %s job of cert: %s for domain: %s has failed after %d attempts, the last error: %s", strings.ToLower(job.Type), job.Cert, job.Domain, job.Attempts, job.LastError),
+		Data: map[string]string{
+			"job":       job.Name,
+			"domain":    job.Domain,
+			"attempts":  strconv.Itoa(job.Attempts),
+			"lastError": job.LastError,
+		},
+	})
+}
+
+func StartCertAlertLoop() {
+	fmt.Printf("StartCertAlertLoop() Start!\n\n")
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("[%s] Recovered from StartCertAlertLoop() panic: %v\n", util.GetCurrentTime(), r)
+				StartCertAlertLoop()
+			}
+		}()
+
+		for {
+			err := checkCertAlerts()
+			if err != nil {
+				fmt.Printf("checkCertAlerts() error: %v\n", err)
+			}
+
+			time.Sleep(certAlertInterval)
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/caswaf/notifier"
+	"github.com/xorm-io/core"
+)
+
+func TestGetCertAlertThreshold(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alertDays := []int{30, 14, 7, 1}
+
+	tests := []struct {
+		expireTime string
+		threshold  int
+		daysLeft   int
+		ok         bool
+	}{
+		{"2024-03-01T00:00:00Z", 0, 60, false},
+		{"2024-01-31T00:00:00Z", 30, 30, true},
+		{"2024-01-20T00:00:00Z", 30, 19, true},
+		{"2024-01-09T12:00:00Z", 14, 8, true},
+		{"2024-01-08T00:00:00Z", 7, 7, true},
+		{"2024-01-01T12:00:00Z", 1, 0, true},
+		{"2023-12-31T00:00:00Z", -1, -1, true},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		threshold, daysLeft, ok := getCertAlertThreshold(tt.expireTime, alertDays, now)
+		if threshold != tt.threshold || daysLeft != tt.daysLeft || ok != tt.ok {
+			t.Errorf("getCertAlertThreshold(%s) = (%d, %d, %v), want (%d, %d, %v)", tt.expireTime, threshold, daysLeft, ok, tt.threshold, tt.daysLeft, tt.ok)
+		}
+	}
+}
+
+func TestGetCertAlertDays(t *testing.T) {
+	if got := getCertAlertDays(); !reflect.DeepEqual(got, []int{30, 14, 7, 1}) {
+		t.Errorf("getCertAlertDays() = %v, want the default thresholds", got)
+	}
+
+	t.Setenv("certAlertDays", "7, 60,x,1")
+	if got := getCertAlertDays(); !reflect.DeepEqual(got, []int{60, 7, 1}) {
+		t.Errorf("getCertAlertDays() = %v, want [60 7 1]", got)
+	}
+}
+
+func TestSendSiteAlert(t *testing.T) {
+	requests := map[string]*http.Request{}
+	bodies := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests[r.URL.Path] = r
+		bodies[r.URL.Path] = string(body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	site := &Site{
+		Owner:          "admin",
+		Name:           "site",
+		AlertProviders: []string{"Webhook/" + server.URL + "/webhook", "HTTP/" + server.URL + "/http"},
+		AlertSecrets:   map[string]string{"Webhook/" + server.URL + "/webhook": "secret"},
+	}
+	err := SendSiteAlert(site, &notifier.Event{Type: notifier.EventCertExpiring, Subject: "example.com", Message: "Content"})
+	if err != nil {
+		t.Fatalf("SendSiteAlert() error = %v", err)
+	}
+
+	var webhook map[string]interface{}
+	err = json.Unmarshal([]byte(bodies["/webhook"]), &webhook)
+	if err != nil || webhook["event"] != notifier.EventCertExpiring || webhook["owner"] != "admin" || webhook["site"] != "admin/site" || webhook["content"] != "Content" {
+		t.Errorf("the webhook payload = %s", bodies["/webhook"])
+	}
+	timestamp := requests["/webhook"].Header.Get("X-CasWAF-Timestamp")
+	if got := requests["/webhook"].Header.Get("X-CasWAF-Signature"); got != notifier.GetSignature("secret", timestamp, []byte(bodies["/webhook"])) {
+		t.Errorf("the webhook signature = %s", got)
+	}
+
+	if !strings.HasSuffix(bodies["/http"], "\nContent") {
+		t.Errorf("the HTTP payload = %s", bodies["/http"])
+	}
+
+	site.AlertProviders = []string{"Webhook/" + server.URL + "/fail"}
+	err = SendSiteAlert(site, &notifier.Event{Type: notifier.EventCertExpiring, Subject: "example.org", Message: "Content"})
+	if err == nil {
+		t.Errorf("SendSiteAlert() should return the error of the failed target")
+	}
+}
+
+func TestCheckCertExpireAlert(t *testing.T) {
+	InitMemoryAdapter()
+
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sent++
+	}))
+	defer server.Close()
+
+	expireTime := time.Now().Add(5 * 24 * time.Hour).Format(time.RFC3339)
+	cert := &Cert{Owner: "admin", Name: "alert.example.com", ExpireTime: expireTime}
+	site := &Site{Owner: "admin", Name: "alert-site", Domain: "alert.example.com", EnableAlert: true, AlertProviders: []string{"HTTP/" + server.URL + "/fail"}}
+	// the sites with the alerts disabled or inactive are never alerted
+	disabledSite := &Site{Owner: "admin", Name: "alert-disabled", Domain: "alert.example.com", AlertProviders: []string{"HTTP/" + server.URL + "/ok"}}
+	inactiveSite := &Site{Owner: "admin", Name: "alert-inactive", Domain: "alert.example.com", EnableAlert: true, Status: "Inactive", AlertProviders: []string{"HTTP/" + server.URL + "/ok"}}
+	_, err := ormer.Engine.Insert(cert, site, disabledSite, inactiveSite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getExpireAlert := func() string {
+		c, err := getCert(cert.Owner, cert.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.ExpireAlert
+	}
+
+	// the claim of the alert failed to send is cleared to retry
+	err = checkCertExpireAlert(cert, notifier.EventCertExpiring, "Certificate", expireTime, "expire_alert", []int{7})
+	if err == nil {
+		t.Fatalf("checkCertExpireAlert() should return the error of the failed target")
+	}
+	if alert := getExpireAlert(); alert != "" {
+		t.Fatalf("ExpireAlert = %s after the failed alert, want empty", alert)
+	}
+
+	site.AlertProviders = []string{"HTTP/" + server.URL + "/ok"}
+	_, err = ormer.Engine.ID(core.PK{site.Owner, site.Name}).Cols("alert_providers").Update(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = checkCertExpireAlert(cert, notifier.EventCertExpiring, "Certificate", expireTime, "expire_alert", []int{7})
+		if err != nil {
+			t.Fatalf("checkCertExpireAlert() #%d error = %v", i, err)
+		}
+	}
+	if sent != 1 {
+		t.Errorf("the alert is sent %d times, want once", sent)
+	}
+	if alert, want := getExpireAlert(), expireTime+"/7"; alert != want {
+		t.Errorf("ExpireAlert = %s, want %s", alert, want)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"sync"
+)
+
+type tlsCertCacheItem struct {
+	hash    string
+	tlsCert *tls.Certificate
+}
+
+// tlsCertCache caches the parsed certificates by cert name, so the PEM certificate and private key
+// are not parsed again on every TLS handshake
+var (
+	tlsCertCache     = map[string]*tlsCertCacheItem{}
+	tlsCertCacheLock = &sync.RWMutex{}
+)
+
+func getCertHash(certificate string, privateKey string) string {
+	hash := sha256.Sum256([]byte(certificate + "\n" + privateKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func getCachedTlsCert(key string, certificate string, privateKey string) (*tls.Certificate, error) {
+	hash := getCertHash(certificate, privateKey)
+
+	tlsCertCacheLock.RLock()
+	item, ok := tlsCertCache[key]
+	tlsCertCacheLock.RUnlock()
+	if ok && item.hash == hash {
+		return item.tlsCert, nil
+	}
+
+	tlsCert, err := tls.X509KeyPair([]byte(certificate), []byte(privateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCertCacheLock.Lock()
+	tlsCertCache[key] = &tlsCertCacheItem{hash: hash, tlsCert: &tlsCert}
+	tlsCertCacheLock.Unlock()
+	return &tlsCert, nil
+}
+
+// GetTlsCert returns the parsed certificate of the cert, which is cached until the content is changed
+func GetTlsCert(cert *Cert) (*tls.Certificate, error) {
+	return getCachedTlsCert(cert.Name, cert.Certificate, cert.PrivateKey)
+}
+
+// GetEccTlsCert returns the parsed ECDSA certificate of a dual cert, or nil if the cert has no ECDSA certificate
+func GetEccTlsCert(cert *Cert) (*tls.Certificate, error) {
+	if cert.EccCertificate == "" {
+		return nil, nil
+	}
+	return getCachedTlsCert(cert.Name+"#ecc", cert.EccCertificate, cert.EccPrivateKey)
+}
+
+func clearTlsCertCache() {
+	tlsCertCacheLock.Lock()
+	tlsCertCache = map[string]*tlsCertCacheItem{}
+	tlsCertCacheLock.Unlock()
+}
+
+// refreshCertMap reloads the certs used by the gateway after they are changed
+func refreshCertMap() error {
+	newCertMap, err := getCertMap()
+	if err != nil {
+		return err
+	}
+
+	certMap = newCertMap
+	clearTlsCertCache()
+	return nil
+}
+
+func GetCertByName(name string) *Cert {
+	cert, ok := certMap[name]
+	if !ok {
+		return nil
+	}
+	return cert
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func newTestCertPem(t *testing.T, commonName string) (string, string) {
+	_, key, certPem := newTestChainCert(t, commonName, false, nil, nil)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certPem, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestGetTlsCert(t *testing.T) {
+	t.Cleanup(clearTlsCertCache)
+
+	certificate, privateKey := newTestCertPem(t, "example.com")
+	cert := &Cert{Owner: "admin", Name: "example.com", Certificate: certificate, PrivateKey: privateKey}
+
+	tlsCert, err := GetTlsCert(cert)
+	if err != nil {
+		t.Fatalf("GetTlsCert() error = %v", err)
+	}
+	if tlsCert2, _ := GetTlsCert(cert); tlsCert2 != tlsCert {
+		t.Errorf("GetTlsCert() parses the unchanged cert again")
+	}
+
+	cert.Certificate, cert.PrivateKey = newTestCertPem(t, "example.com")
+	if tlsCert2, _ := GetTlsCert(cert); tlsCert2 == tlsCert {
+		t.Errorf("GetTlsCert() returns the cached cert after the content is changed")
+	}
+
+	cert.PrivateKey = ""
+	if _, err = GetTlsCert(cert); err == nil {
+		t.Errorf("GetTlsCert() accepts a cert without private key")
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/util"
+	"github.com/xorm-io/core"
+)
+
+const (
+	// the first retry is after 5 minutes, so a failing domain makes less than 5 failed validations an hour,
+	// which is the limit of Let's Encrypt per account and hostname
+	certJobRetryDelay    = 5 * time.Minute
+	certJobMaxRetryDelay = 24 * time.Hour
+	certJobMaxAttempts   = 8
+	// certJobFailedCooldown is how long a domain whose job failed is not renewed automatically again
+	certJobFailedCooldown = 24 * time.Hour
+	certJobRetention      = 7 * 24 * time.Hour
+)
+
+// CertJob is the job issuing or renewing a cert, which is run by the worker of the node, or any node if the node is empty.
+// The cert of a site is issued by the HTTP-01 or TLS-ALPN-01 challenge of the site, other certs by their DNS provider.
+type CertJob struct {
+	Owner       string `xorm:"varchar(100) notnull pk" json:"owner"`
+	Name        string `xorm:"varchar(100) notnull pk" json:"name"`
+	CreatedTime string `xorm:"varchar(100)" json:"createdTime"`
+	UpdatedTime string `xorm:"varchar(100)" json:"updatedTime"`
+
+	Type   string `xorm:"varchar(100)" json:"type"`
+	Cert   string `xorm:"varchar(100) index" json:"cert"`
+	Site   string `xorm:"varchar(100)" json:"site"`
+	Domain string `xorm:"varchar(100)" json:"domain"`
+	Node   string `xorm:"varchar(100)" json:"node"`
+
+	State         string `xorm:"varchar(100) index" json:"state"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `xorm:"mediumtext" json:"lastError"`
+	NextRetryTime string `xorm:"varchar(100)" json:"nextRetryTime"`
+	FinishedTime  string `xorm:"varchar(100)" json:"finishedTime"`
+}
+
+func GetCertJobs(owner string) ([]*CertJob, error) {
+	jobs := []*CertJob{}
+	err := ormer.Engine.Desc("created_time").Find(&jobs, &CertJob{Owner: owner})
+	return jobs, err
+}
+
+func (job *CertJob) GetId() string {
+	return fmt.Sprintf("%s/%s", job.Owner, job.Name)
+}
+
+func (job *CertJob) isActive() bool {
+	return job.State == "Pending" || job.State == "Running"
+}
+
+func getLatestCertJob(owner string, cert string) (*CertJob, error) {
+	job := CertJob{}
+	existed, err := ormer.Engine.Where("owner = ? and cert = ?", owner, cert).Desc("created_time").Get(&job)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		return &job, nil
+	} else {
+		return nil, nil
+	}
+}
+
+func updateCertJob(job *CertJob) error {
+	job.UpdatedTime = util.GetCurrentTime()
+	_, err := ormer.Engine.ID(core.PK{job.Owner, job.Name}).AllCols().Update(job)
+	return err
+}
+
+// addCertJob queues the job unless the cert has an active one. An automatic job is also not queued within
+// the cooldown after the last job of the cert failed, while a manual one retries the active job immediately.
+func addCertJob(job *CertJob, isManual bool) (*CertJob, error) {
+	latestJob, err := getLatestCertJob(job.Owner, job.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if latestJob != nil && latestJob.isActive() {
+		if isManual && latestJob.State == "Pending" {
+			latestJob.Attempts = 0
+			latestJob.NextRetryTime = util.GetCurrentTime()
+			err = updateCertJob(latestJob)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return latestJob, nil
+	}
+
+	if !isManual && latestJob != nil && latestJob.State == "Failed" && !isTimeBefore(latestJob.FinishedTime, certJobFailedCooldown) {
+		return latestJob, nil
+	}
+
+	currentTime := util.GetCurrentTime()
+	job.Name = fmt.Sprintf("job_%s", util.GetRandomHexString(8))
+	job.CreatedTime = currentTime
+	job.UpdatedTime = currentTime
+	job.State = "Pending"
+	job.NextRetryTime = currentTime
+	_, err = ormer.Engine.Insert(job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// isTimeBefore returns whether the RFC3339 time is more than the duration ago
+func isTimeBefore(s string, duration time.Duration) bool {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return true
+	}
+	return time.Since(t) > duration
+}
+
+func (site *Site) getCertNode() string {
+	if len(site.Nodes) != 0 {
+		return site.Nodes[0].Name
+	}
+	return getNodeNameFromTag(site.Tag)
+}
+
+func (site *Site) addCertJob(domain string, jobType string, isManual bool) (*CertJob, error) {
+	job := &CertJob{
+		Owner:  site.Owner,
+		Type:   jobType,
+		Cert:   domain,
+		Site:   site.GetId(),
+		Domain: domain,
+		Node:   site.getCertNode(),
+	}
+	return addCertJob(job, isManual)
+}
+
+func getSiteByCert(cert *Cert) (*Site, error) {
+	sites, err := GetGlobalSites()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, site := range sites {
+		if site.Owner != cert.Owner {
+			continue
+		}
+		if strings.EqualFold(site.Domain, cert.Name) {
+			return site, nil
+		}
+		for _, domain := range site.OtherDomains {
+			if strings.EqualFold(domain, cert.Name) {
+				return site, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// AddCertRenewJob queues the job renewing the cert by its DNS provider, or by the challenge of its site
+func AddCertRenewJob(cert *Cert) (*CertJob, error) {
+	if cert.Provider != "" {
+		job := &CertJob{
+			Owner:  cert.Owner,
+			Type:   "Renew",
+			Cert:   cert.Name,
+			Domain: cert.Name,
+		}
+		return addCertJob(job, true)
+	}
+
+	site, err := getSiteByCert(cert)
+	if err != nil {
+		return nil, err
+	}
+	if site == nil {
+		return nil, fmt.Errorf("the cert: %s has neither a DNS provider nor a site to renew it", cert.GetId())
+	}
+	return site.addCertJob(cert.Name, "Renew", true)
+}
+
+func getCertJobRetryDelay(attempts int) time.Duration {
+	delay := certJobRetryDelay
+	for i := 1; i < attempts && delay < certJobMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > certJobMaxRetryDelay {
+		delay = certJobMaxRetryDelay
+	}
+	return delay
+}
+
+// finishCertJob records the result of the job, a failed job is retried with backoff until the max attempts
+func finishCertJob(job *CertJob, jobErr error) error {
+	job.Attempts += 1
+	if jobErr == nil {
+		job.State = "Succeeded"
+		job.LastError = ""
+		job.FinishedTime = util.GetCurrentTime()
+	} else if job.Attempts >= certJobMaxAttempts {
+		job.State = "Failed"
+		job.LastError = jobErr.Error()
+		job.FinishedTime = util.GetCurrentTime()
+
+		err := alertCertJobFailed(job)
+		if err != nil {
+			fmt.Printf("alertCertJobFailed() error: %v, job: %s\n", err, job.GetId())
+		}
+	} else {
+		job.State = "Pending"
+		job.LastError = jobErr.Error()
+		job.NextRetryTime = time.Now().Add(getCertJobRetryDelay(job.Attempts)).Format(time.RFC3339)
+	}
+	return updateCertJob(job)
+}
@@ -0,0 +1,293 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xorm-io/core"
+)
+
+func TestGetCertJobRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{4, 40 * time.Minute},
+		{9, 1280 * time.Minute},
+		{10, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := getCertJobRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("getCertJobRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+
+	// Let's Encrypt allows 5 failed validations per account and hostname an hour
+	total := time.Duration(0)
+	failures := 1
+	for total+getCertJobRetryDelay(failures) < time.Hour {
+		total += getCertJobRetryDelay(failures)
+		failures++
+	}
+	if failures >= 5 {
+		t.Errorf("a failing job makes %d attempts within an hour", failures)
+	}
+}
+
+func TestIsTimeBefore(t *testing.T) {
+	if !isTimeBefore(time.Now().Add(-2*time.Hour).Format(time.RFC3339), time.Hour) {
+		t.Errorf("the time 2 hours ago should be before 1 hour")
+	}
+	if isTimeBefore(time.Now().Add(time.Minute).Format(time.RFC3339), 0) {
+		t.Errorf("a future time should not be before now")
+	}
+	if !isTimeBefore("", time.Hour) {
+		t.Errorf("an empty time should be before any duration")
+	}
+}
+
+func getTestCertJob(t *testing.T, job *CertJob) *CertJob {
+	res := CertJob{}
+	existed, err := ormer.Engine.ID(core.PK{job.Owner, job.Name}).Get(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !existed {
+		t.Fatalf("the job: %s does not exist", job.GetId())
+	}
+	return &res
+}
+
+func getTestCertJobCount(t *testing.T, cert string) int64 {
+	count, err := ormer.Engine.Count(&CertJob{Owner: "admin", Cert: cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestAddCertJob(t *testing.T) {
+	InitMemoryAdapter()
+
+	// an active job is not queued twice
+	job, err := addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != "Pending" {
+		t.Fatalf("State = %s, want Pending", job.State)
+	}
+	for _, isManual := range []bool{false, true} {
+		res, err := addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, isManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Name != job.Name {
+			t.Errorf("addCertJob() manual = %v queues the job: %s, want the active job: %s", isManual, res.Name, job.Name)
+		}
+	}
+	if count := getTestCertJobCount(t, "a.com"); count != 1 {
+		t.Errorf("the cert has %d jobs, want 1", count)
+	}
+
+	// a manual job retries the pending job immediately
+	job.Attempts = 3
+	job.NextRetryTime = time.Now().Add(time.Hour).Format(time.RFC3339)
+	err = updateCertJob(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := getTestCertJob(t, job); res.Attempts != 3 {
+		t.Errorf("an automatic job resets the attempts of the pending job to %d", res.Attempts)
+	}
+	_, err = addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := getTestCertJob(t, job); res.Attempts != 0 || !isTimeBefore(res.NextRetryTime, 0) {
+		t.Errorf("the retried job = %d attempts, next retry at %s, want 0 attempts and due now", res.Attempts, res.NextRetryTime)
+	}
+
+	// but not the running one
+	job.State = "Running"
+	job.Attempts = 2
+	err = updateCertJob(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := getTestCertJob(t, job); res.State != "Running" || res.Attempts != 2 {
+		t.Errorf("the running job = %s with %d attempts, want unchanged", res.State, res.Attempts)
+	}
+}
+
+func TestAddCertJobFailedCooldown(t *testing.T) {
+	InitMemoryAdapter()
+
+	for _, tt := range []struct {
+		cert         string
+		finishedTime time.Time
+		isManual     bool
+		wantQueued   bool
+	}{
+		{cert: "recent.com", finishedTime: time.Now().Add(-time.Hour), isManual: false, wantQueued: false},
+		{cert: "recent-manual.com", finishedTime: time.Now().Add(-time.Hour), isManual: true, wantQueued: true},
+		{cert: "old.com", finishedTime: time.Now().Add(-certJobFailedCooldown - time.Hour), isManual: false, wantQueued: true},
+	} {
+		failedJob := &CertJob{
+			Owner:        "admin",
+			Name:         fmt.Sprintf("job_%s", tt.cert),
+			CreatedTime:  tt.finishedTime.Add(-time.Hour).Format(time.RFC3339),
+			Type:         "Renew",
+			Cert:         tt.cert,
+			Domain:       tt.cert,
+			State:        "Failed",
+			Attempts:     certJobMaxAttempts,
+			FinishedTime: tt.finishedTime.Format(time.RFC3339),
+		}
+		_, err := ormer.Engine.Insert(failedJob)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		job, err := addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: tt.cert, Domain: tt.cert}, tt.isManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if queued := job.Name != failedJob.Name; queued != tt.wantQueued {
+			t.Errorf("addCertJob() for %s queued = %v, want %v", tt.cert, queued, tt.wantQueued)
+		}
+		if count, want := getTestCertJobCount(t, tt.cert), map[bool]int64{false: 1, true: 2}[tt.wantQueued]; count != want {
+			t.Errorf("the cert: %s has %d jobs, want %d", tt.cert, count, want)
+		}
+	}
+}
+
+func TestFinishCertJob(t *testing.T) {
+	InitMemoryAdapter()
+
+	job, err := addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < certJobMaxAttempts; i++ {
+		err = finishCertJob(job, errors.New("challenge failed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := getTestCertJob(t, job)
+		if res.State != "Pending" || res.Attempts != i || res.LastError != "challenge failed" || isTimeBefore(res.NextRetryTime, 0) {
+			t.Fatalf("the job after %d failures = %+v, want pending to retry later", i, res)
+		}
+	}
+
+	err = finishCertJob(job, errors.New("challenge failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := getTestCertJob(t, job)
+	if res.State != "Failed" || res.Attempts != certJobMaxAttempts || res.FinishedTime == "" {
+		t.Errorf("the job after %d failures = %+v, want failed", certJobMaxAttempts, res)
+	}
+
+	job, err = addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "b.com", Domain: "b.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = finishCertJob(job, errors.New("challenge failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = finishCertJob(job, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = getTestCertJob(t, job)
+	if res.State != "Succeeded" || res.Attempts != 2 || res.LastError != "" || res.FinishedTime == "" {
+		t.Errorf("the succeeded job = %+v", res)
+	}
+}
+
+func TestClaimCertJob(t *testing.T) {
+	InitMemoryAdapter()
+
+	job, err := addCertJob(&CertJob{Owner: "admin", Type: "Renew", Cert: "a.com", Domain: "a.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the nodes polling the same pending job at once
+	nodes := 8
+	results := make([]bool, nodes)
+	wg := sync.WaitGroup{}
+	for i := 0; i < nodes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			copied := *job
+			ok, err := claimCertJob(&copied, fmt.Sprintf("node%d", i))
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = ok
+		}(i)
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, ok := range results {
+		if !ok {
+			continue
+		}
+		if winner != "" {
+			t.Fatalf("the job is claimed by both %s and node%d", winner, i)
+		}
+		winner = fmt.Sprintf("node%d", i)
+	}
+	if winner == "" {
+		t.Fatalf("the job is claimed by no node")
+	}
+
+	res := getTestCertJob(t, job)
+	if res.State != "Running" || res.Node != winner {
+		t.Errorf("the claimed job = %s on %s, want running on %s", res.State, res.Node, winner)
+	}
+
+	jobs, err := getDueCertJobs(winner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("the running job is still due: %v", jobs)
+	}
+}
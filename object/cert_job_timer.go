@@ -0,0 +1,176 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/casbin/caswaf/util"
+	"github.com/xorm-io/core"
+)
+
+const certJobPollInterval = 5 * time.Second
+
+// getDueCertJobs returns the pending jobs of the node whose retry time has come, in the order they are queued
+func getDueCertJobs(hostname string) ([]*CertJob, error) {
+	jobs := []*CertJob{}
+	err := ormer.Engine.Where("state = ? and (node = ? or node = ?)", "Pending", hostname, "").Asc("created_time").Find(&jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*CertJob{}
+	for _, job := range jobs {
+		if isTimeBefore(job.NextRetryTime, 0) {
+			res = append(res, job)
+		}
+	}
+	return res, nil
+}
+
+// claimCertJob marks the job as running by the node, it returns false if the job is claimed by another node
+func claimCertJob(job *CertJob, hostname string) (bool, error) {
+	job.State = "Running"
+	if job.Node == "" {
+		job.Node = hostname
+	}
+	job.UpdatedTime = util.GetCurrentTime()
+	affected, err := ormer.Engine.ID(core.PK{job.Owner, job.Name}).Where("state = ?", "Pending").Cols("state", "node", "updated_time").Update(job)
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+func runCertJob(job *CertJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if job.Site == "" {
+		cert, err := getCert(job.Owner, job.Cert)
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			return fmt.Errorf("the cert: %s/%s does not exist", job.Owner, job.Cert)
+		}
+
+		_, err = RenewCert(cert)
+		return err
+	}
+
+	site, err := GetSite(job.Site)
+	if err != nil {
+		return err
+	}
+	if site == nil {
+		return fmt.Errorf("the site: %s does not exist", job.Site)
+	}
+	return site.updateCertForDomain(job.Domain)
+}
+
+// runCertJobs runs the due jobs of the node one by one, so the node only places one ACME order at a time
+func runCertJobs() error {
+	hostname := util.GetHostname()
+	jobs, err := getDueCertJobs(hostname)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		var ok bool
+		ok, err = claimCertJob(job, hostname)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		jobErr := runCertJob(job)
+		if jobErr != nil {
+			fmt.Printf("runCertJob() error: %v, job: %s, domain: %s, attempts: %d\n", jobErr, job.GetId(), job.Domain, job.Attempts+1)
+		}
+
+		err = finishCertJob(job, jobErr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resetRunningCertJobs queues again the jobs of the node interrupted by a restart
+func resetRunningCertJobs(hostname string) error {
+	_, err := ormer.Engine.Where("state = ? and node = ?", "Running", hostname).Cols("state").Update(&CertJob{State: "Pending"})
+	return err
+}
+
+func deleteExpiredCertJobs() error {
+	jobs := []*CertJob{}
+	err := ormer.Engine.In("state", "Succeeded", "Failed").Find(&jobs)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if !isTimeBefore(job.FinishedTime, certJobRetention) {
+			continue
+		}
+
+		_, err = ormer.Engine.ID(core.PK{job.Owner, job.Name}).Delete(&CertJob{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func StartCertJobLoop() {
+	fmt.Printf("StartCertJobLoop() Start!\n\n")
+
+	err := resetRunningCertJobs(util.GetHostname())
+	if err != nil {
+		fmt.Printf("resetRunningCertJobs() error: %v\n", err)
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("[%s] Recovered from StartCertJobLoop() panic: %v\n", util.GetCurrentTime(), r)
+				StartCertJobLoop()
+			}
+		}()
+
+		for {
+			err := runCertJobs()
+			if err != nil {
+				fmt.Printf("runCertJobs() error: %v\n", err)
+			}
+
+			err = deleteExpiredCertJobs()
+			if err != nil {
+				fmt.Printf("deleteExpiredCertJobs() error: %v\n", err)
+			}
+
+			time.Sleep(certJobPollInterval)
+		}
+	}()
+}
@@ -0,0 +1,249 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+)
+
+// ParseCertChain returns the certificates in the PEM chain, from the leaf to the root
+func ParseCertChain(s string) ([]*x509.Certificate, error) {
+	res := []*x509.Certificate{}
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the certificate %d of the chain: %v", len(res)+1, err)
+		}
+		res = append(res, cert)
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("the certificate should contain at least one PEM certificate")
+	}
+	return res, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// getCertRoots returns the system roots with the roots of certRootBundle, which is the path of the PEM
+// file of the internal CAs, or nil if the system roots are unavailable and no bundle is configured
+func getCertRoots() (*x509.CertPool, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		// the system roots are unavailable, e.g., on some Windows versions
+		roots = nil
+	}
+
+	bundlePath := conf.GetConfigString("certRootBundle")
+	if bundlePath == "" {
+		return roots, nil
+	}
+
+	bundle, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cert root bundle: %v", err)
+	}
+	if roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("the cert root bundle: %s has no PEM certificate", bundlePath)
+	}
+	return roots, nil
+}
+
+// getCertVerifyTime returns the time to verify the chain of the leaf at, which is now if the leaf is valid,
+// or a time inside the validity of the leaf, so an expired leaf is not reported as an incomplete chain
+func getCertVerifyTime(leaf *x509.Certificate, now time.Time) time.Time {
+	if now.After(leaf.NotAfter) {
+		return leaf.NotAfter
+	}
+	if now.Before(leaf.NotBefore) {
+		return leaf.NotBefore
+	}
+	return now
+}
+
+// validateCertChain checks that every certificate of the chain is issued by the next one, and the
+// last one is a root, or is issued by a root in the system pool or certRootBundle
+func validateCertChain(chain []*x509.Certificate) error {
+	for i := 0; i < len(chain)-1; i++ {
+		err := chain[i].CheckSignatureFrom(chain[i+1])
+		if err != nil {
+			return fmt.Errorf("the certificate chain is out of order or incomplete: \"%s\" is not issued by \"%s\": %v", chain[i].Subject, chain[i+1].Subject, err)
+		}
+	}
+
+	last := chain[len(chain)-1]
+	if isSelfSigned(last) {
+		return nil
+	}
+
+	roots, err := getCertRoots()
+	if err != nil {
+		return err
+	}
+	if roots == nil {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		CurrentTime:   getCertVerifyTime(chain[0], time.Now()),
+	})
+	if err != nil {
+		return fmt.Errorf("the certificate chain is incomplete, the issuer: \"%s\" of \"%s\" is missing, the root of an internal CA can be added to certRootBundle: %v", last.Issuer, last.Subject, err)
+	}
+	return nil
+}
+
+// getCertSiteDomains returns the domains of the sites served by the cert, i.e., whose cert is looked
+// up by GetCertByDomain() with the domain or its base domain as the cert name
+func getCertSiteDomains(cert *Cert) ([]string, error) {
+	sites, err := GetGlobalSites()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, site := range sites {
+		domains := append([]string{site.Domain}, site.OtherDomains...)
+		for _, domain := range domains {
+			if cert.isCertOfDomain(domain) {
+				res = append(res, domain)
+			}
+		}
+	}
+	return res, nil
+}
+
+// getCertSites returns the sites with any domain served by the cert
+func getCertSites(cert *Cert) ([]*Site, error) {
+	sites, err := GetGlobalSites()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*Site{}
+	for _, site := range sites {
+		domains := append([]string{site.Domain}, site.OtherDomains...)
+		for _, domain := range domains {
+			if cert.isCertOfDomain(domain) {
+				res = append(res, site)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func (cert *Cert) isCertOfDomain(domain string) bool {
+	if domain == "" {
+		return false
+	}
+	if domain == cert.Name {
+		return true
+	}
+	if _, ok := certMap[domain]; ok {
+		return false
+	}
+	baseDomain, err := getBaseDomain(domain)
+	return err == nil && baseDomain == cert.Name
+}
+
+// ValidateCert checks the chain of the cert, that the private key matches the leaf certificate,
+// and that the certificate covers the domains of the sites it serves. The ECDSA certificate of
+// a dual cert is checked in the same way.
+func ValidateCert(cert *Cert) error {
+	domains, err := getCertSiteDomains(cert)
+	if err != nil {
+		return err
+	}
+
+	leaf, err := validateCertPair(cert.Certificate, cert.PrivateKey, domains)
+	if err != nil {
+		return err
+	}
+
+	if cert.EccCertificate != "" {
+		leaf, err = validateCertPair(cert.EccCertificate, cert.EccPrivateKey, domains)
+		if err != nil {
+			return fmt.Errorf("the ECDSA certificate is invalid: %v", err)
+		}
+		if leaf.PublicKeyAlgorithm != x509.ECDSA {
+			return fmt.Errorf("the ECDSA certificate should have an ECDSA key instead of %s", leaf.PublicKeyAlgorithm)
+		}
+	}
+	return nil
+}
+
+func validateCertPair(certificate string, privateKey string, domains []string) (*x509.Certificate, error) {
+	chain, err := ParseCertChain(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tls.X509KeyPair([]byte(certificate), []byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("the private key does not match the certificate: %v", err)
+	}
+
+	err = validateCertChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(chain[0].NotAfter) {
+		return nil, fmt.Errorf("the certificate has expired at %s", chain[0].NotAfter.Format(time.RFC3339))
+	}
+
+	uncovered := []string{}
+	for _, domain := range domains {
+		if chain[0].VerifyHostname(domain) != nil {
+			uncovered = append(uncovered, domain)
+		}
+	}
+	if len(uncovered) != 0 {
+		return nil, fmt.Errorf("the certificate does not cover the site domains: %s, the certificate SANs are: %s", strings.Join(uncovered, ", "), strings.Join(chain[0].DNSNames, ", "))
+	}
+	return chain[0], nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestChainCert(t *testing.T, commonName string, isCa bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	return newTestChainCertAt(t, commonName, isCa, parent, parentKey, time.Now(), time.Now().Add(time.Hour))
+}
+
+// newTestChainCertAt returns a test cert valid from notBefore to notAfter
+func newTestChainCertAt(t *testing.T, commonName string, isCa bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, notBefore time.Time, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  isCa,
+		BasicConstraintsValid: true,
+	}
+	if isCa {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestValidateCertChain(t *testing.T) {
+	root, rootKey, rootPem := newTestChainCert(t, "root", true, nil, nil)
+	intermediate, intermediateKey, intermediatePem := newTestChainCert(t, "intermediate", true, root, rootKey)
+	_, _, leafPem := newTestChainCert(t, "example.com", false, intermediate, intermediateKey)
+
+	tests := []struct {
+		name    string
+		chain   string
+		wantErr bool
+	}{
+		{"complete", leafPem + intermediatePem + rootPem, false},
+		{"self-signed", rootPem, false},
+		{"out of order", leafPem + rootPem + intermediatePem, true},
+		{"missing intermediate", leafPem + rootPem, true},
+		{"missing root", leafPem + intermediatePem, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := ParseCertChain(tt.chain)
+			if err != nil {
+				t.Fatalf("ParseCertChain() error = %v", err)
+			}
+			err = validateCertChain(chain)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCertChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the chain of an internal CA is verified by the root in certRootBundle
+	bundlePath := filepath.Join(t.TempDir(), "roots.pem")
+	err := os.WriteFile(bundlePath, []byte(rootPem), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("certRootBundle", bundlePath)
+	chain, err := ParseCertChain(leafPem + intermediatePem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateCertChain(chain); err != nil {
+		t.Errorf("validateCertChain() error = %v with the root in certRootBundle", err)
+	}
+
+	t.Setenv("certRootBundle", filepath.Join(t.TempDir(), "missing.pem"))
+	if err = validateCertChain(chain); err == nil {
+		t.Errorf("validateCertChain() should return the error of a missing certRootBundle")
+	}
+
+	if _, err := ParseCertChain("not a certificate"); err == nil {
+		t.Errorf("ParseCertChain() accepts a non-PEM certificate")
+	}
+}
+
+func TestValidateExpiredCert(t *testing.T) {
+	InitMemoryAdapter()
+
+	now := time.Now()
+	root, rootKey, rootPem := newTestChainCertAt(t, "root", true, nil, nil, now.Add(-48*time.Hour), now.Add(time.Hour))
+	leaf, leafKey, leafPem := newTestChainCertAt(t, "expired.example.com", false, root, rootKey, now.Add(-24*time.Hour), now.Add(-time.Hour))
+
+	// the chain of an expired leaf is complete at a time inside its validity
+	if got := getCertVerifyTime(leaf, now); !got.Equal(leaf.NotAfter) {
+		t.Errorf("getCertVerifyTime() = %v, want %v", got, leaf.NotAfter)
+	}
+	t.Setenv("certRootBundle", writeTestFile(t, rootPem))
+	chain, err := ParseCertChain(leafPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = validateCertChain(chain); err != nil {
+		t.Errorf("validateCertChain() error = %v for an expired leaf", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPem := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	err = ValidateCert(&Cert{Owner: "admin", Name: "expired.example.com", Certificate: leafPem, PrivateKey: keyPem})
+	if err == nil || !strings.Contains(err.Error(), "has expired") {
+		t.Errorf("ValidateCert() error = %v, want the expiry of the certificate", err)
+	}
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "test.pem")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+
+	"github.com/casbin/caswaf/util"
+	"github.com/xorm-io/core"
+)
+
+// Credential is a Basic Auth user or an API key of a site whose AuthMode is "Basic Auth" or "API Key".
+// The secret is the bcrypt hash of the password, or the SHA-256 hash of the API key.
+type Credential struct {
+	Owner       string `xorm:"varchar(100) notnull pk" json:"owner"`
+	Name        string `xorm:"varchar(100) notnull pk" json:"name"`
+	CreatedTime string `xorm:"varchar(100) notnull" json:"createdTime"`
+	UpdatedTime string `xorm:"varchar(100) notnull" json:"updatedTime"`
+
+	Site      string `xorm:"varchar(100) notnull index" json:"site"`
+	Type      string `xorm:"varchar(100) notnull" json:"type"`
+	Username  string `xorm:"varchar(100)" json:"username"`
+	Secret    string `xorm:"varchar(100)" json:"secret"`
+	KeyPrefix string `xorm:"varchar(100)" json:"keyPrefix"`
+	RateLimit int    `xorm:"int" json:"rateLimit"`
+	IsEnabled bool   `xorm:"bool" json:"isEnabled"`
+
+	UsageCount   int64  `xorm:"bigint" json:"usageCount"`
+	LastUsedTime string `xorm:"varchar(100)" json:"lastUsedTime"`
+}
+
+// credentialUsageCols are maintained by AddCredentialUsage() and not overwritten by UpdateCredential()
+var credentialUsageCols = []string{"usage_count", "last_used_time"}
+
+func GetGlobalCredentials() ([]*Credential, error) {
+	credentials := []*Credential{}
+	err := ormer.Engine.Asc("owner").Desc("created_time").Find(&credentials)
+	return credentials, err
+}
+
+func GetCredentials(owner string, site string) ([]*Credential, error) {
+	credentials := []*Credential{}
+	err := ormer.Engine.Desc("created_time").Find(&credentials, &Credential{Owner: owner, Site: site})
+	return credentials, err
+}
+
+func getCredential(owner string, name string) (*Credential, error) {
+	credential := Credential{Owner: owner, Name: name}
+	existed, err := ormer.Engine.Get(&credential)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		return &credential, nil
+	} else {
+		return nil, nil
+	}
+}
+
+func GetCredential(id string) (*Credential, error) {
+	owner, name := util.GetOwnerAndNameFromId(id)
+	return getCredential(owner, name)
+}
+
+// GetMaskedCredential hides the secret, which is only known when the credential is added
+func GetMaskedCredential(credential *Credential) *Credential {
+	if credential == nil {
+		return nil
+	}
+
+	if credential.Secret != "" {
+		credential.Secret = "***"
+	}
+	return credential
+}
+
+func GetMaskedCredentials(credentials []*Credential) []*Credential {
+	for i, credential := range credentials {
+		credentials[i] = GetMaskedCredential(credential)
+	}
+	return credentials
+}
+
+func UpdateCredential(id string, credential *Credential) (bool, error) {
+	owner, name := util.GetOwnerAndNameFromId(id)
+	c, err := getCredential(owner, name)
+	if err != nil {
+		return false, err
+	} else if c == nil {
+		return false, nil
+	}
+
+	// the secret is kept unless a new one is set
+	if credential.Secret == "" || credential.Secret == "***" {
+		credential.Secret = c.Secret
+	}
+	credential.UpdatedTime = util.GetCurrentTime()
+
+	_, err = ormer.Engine.ID(core.PK{owner, name}).AllCols().Omit(credentialUsageCols...).Update(credential)
+	if err != nil {
+		return false, err
+	}
+
+	err = refreshCredentialMap()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func AddCredential(credential *Credential) (bool, error) {
+	// the usage is maintained by AddCredentialUsage()
+	credential.UsageCount, credential.LastUsedTime = 0, ""
+
+	affected, err := ormer.Engine.Insert(credential)
+	if err != nil {
+		return false, err
+	}
+	if affected != 0 {
+		err = refreshCredentialMap()
+		if err != nil {
+			return false, err
+		}
+	}
+	return affected != 0, nil
+}
+
+func DeleteCredential(credential *Credential) (bool, error) {
+	affected, err := ormer.Engine.ID(core.PK{credential.Owner, credential.Name}).Delete(&Credential{})
+	if err != nil {
+		return false, err
+	}
+	if affected != 0 {
+		err = refreshCredentialMap()
+		if err != nil {
+			return false, err
+		}
+	}
+	return affected != 0, nil
+}
+
+func (credential *Credential) GetId() string {
+	return fmt.Sprintf("%s/%s", credential.Owner, credential.Name)
+}
+
+// GetSiteId returns the ID of the site protected by the credential, which has the same owner
+func (credential *Credential) GetSiteId() string {
+	return util.GetIdFromOwnerAndName(credential.Owner, credential.Site)
+}
+
+// AddCredentialUsage adds the usage aggregated in memory to the credential in the DB
+func AddCredentialUsage(id string, usageCount int64, lastUsedTime string) error {
+	owner, name := util.GetOwnerAndNameFromId(id)
+	_, err := ormer.Engine.ID(core.PK{owner, name}).
+		Incr("usage_count", usageCount).
+		Update(&Credential{LastUsedTime: lastUsedTime})
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+var credentialMap = map[string][]*Credential{}
+
+func InitCredentialMap() {
+	err := refreshCredentialMap()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func refreshCredentialMap() error {
+	newCredentialMap := map[string][]*Credential{}
+	credentials, err := GetGlobalCredentials()
+	if err != nil {
+		return err
+	}
+
+	for _, credential := range credentials {
+		if !credential.IsEnabled {
+			continue
+		}
+
+		siteId := credential.GetSiteId()
+		newCredentialMap[siteId] = append(newCredentialMap[siteId], credential)
+	}
+
+	credentialMap = newCredentialMap
+	return nil
+}
+
+// GetSiteCredentials returns the enabled credentials of the site
+func GetSiteCredentials(site *Site) []*Credential {
+	return credentialMap[site.GetId()]
+}
@@ -31,8 +31,14 @@ type Node struct {
 	Tag         string `xorm:"varchar(100)" json:"tag"`
 	ClientIp    string `xorm:"varchar(100)" json:"clientIp"`
 	UpgradeMode string `xorm:"varchar(100)" json:"upgradeMode"`
+
+	HeartbeatTime string `xorm:"varchar(100)" json:"heartbeatTime"`
+	IsOffline     bool   `json:"isOffline"`
 }
 
+// nodeHeartbeatCols are maintained by StartNodeMonitorLoop() and not overwritten by UpdateNode()
+var nodeHeartbeatCols = []string{"heartbeat_time", "is_offline"}
+
 func GetGlobalNodes() ([]*Node, error) {
 	nodes := []*Node{}
 	err := ormer.Engine.Asc("owner").Desc("created_time").Find(&nodes)
@@ -75,7 +81,7 @@ func UpdateNode(id string, node *Node) (bool, error) {
 		return false, nil
 	}
 
-	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Update(node)
+	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Omit(nodeHeartbeatCols...).Update(node)
 	if err != nil {
 		return false, err
 	}
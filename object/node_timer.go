@@ -0,0 +1,175 @@
+// Copyright 2025 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/notifier"
+	"github.com/casbin/caswaf/util"
+	"github.com/xorm-io/core"
+)
+
+const (
+	nodeHeartbeatInterval = time.Minute
+	nodeOfflineTimeout    = 5 * time.Minute
+)
+
+func (site *Site) isOnNode(name string) bool {
+	if site.Node == name {
+		return true
+	}
+
+	for _, node := range site.Nodes {
+		if node.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// claimNodeOffline sets whether the node is offline, it returns false if the node is already in the state,
+// e.g., it has been set by another node
+func claimNodeOffline(node *Node, isOffline bool) (bool, error) {
+	session := ormer.Engine.Table(new(Node)).ID(core.PK{node.Owner, node.Name})
+	if isOffline {
+		session = session.Where("is_offline is null or is_offline = ?", false)
+	} else {
+		session = session.Where("is_offline = ?", true)
+	}
+
+	affected, err := session.Update(map[string]interface{}{"is_offline": isOffline})
+	if err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+// alertNode alerts the sites running on the node
+func alertNode(node *Node, eventType string, message string) error {
+	sites, err := GetGlobalSites()
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, site := range sites {
+		if !site.isOnNode(node.Name) || !site.isAlertEnabled() {
+			continue
+		}
+
+		err = SendSiteAlert(site, &notifier.Event{
+			Type:    eventType,
+			Subject: node.Name,
+			Message: message,
+			Data:    map[string]string{"heartbeatTime": node.HeartbeatTime},
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// updateNodeHeartbeat updates the heartbeat time of this node, and alerts it is online if it has been offline
+func updateNodeHeartbeat() error {
+	hostname := util.GetHostname()
+	_, err := ormer.Engine.Table(new(Node)).Where("name = ?", hostname).Update(map[string]interface{}{"heartbeat_time": util.GetCurrentTime()})
+	if err != nil {
+		return err
+	}
+
+	nodes := []*Node{}
+	err = ormer.Engine.Where("name = ? and is_offline = ?", hostname, true).Find(&nodes)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		ok, err := claimNodeOffline(node, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		err = alertNode(node, notifier.EventNodeOnline, fmt.Sprintf("The node: %s is online again", node.Name))
+		if err != nil {
+			fmt.Printf("alertNode() error: %v, node: %s\n", err, node.GetId())
+		}
+	}
+	return nil
+}
+
+// checkOfflineNodes alerts the sites of the nodes without a heartbeat in nodeOfflineTimeout, once per node
+// among all the nodes
+func checkOfflineNodes() error {
+	nodes, err := GetGlobalNodes()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.IsOffline || node.HeartbeatTime == "" || !isTimeBefore(node.HeartbeatTime, nodeOfflineTimeout) {
+			continue
+		}
+
+		ok, err := claimNodeOffline(node, true)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		err = alertNode(node, notifier.EventNodeOffline, fmt.Sprintf("The node: %s has not sent the heartbeat since %s", node.Name, node.HeartbeatTime))
+		if err != nil {
+			fmt.Printf("alertNode() error: %v, node: %s\n", err, node.GetId())
+		}
+	}
+	return nil
+}
+
+func StartNodeMonitorLoop() {
+	fmt.Printf("StartNodeMonitorLoop() Start!\n\n")
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("[%s] Recovered from StartNodeMonitorLoop() panic: %v\n", util.GetCurrentTime(), r)
+				StartNodeMonitorLoop()
+			}
+		}()
+
+		for {
+			err := updateNodeHeartbeat()
+			if err != nil {
+				fmt.Printf("updateNodeHeartbeat() error: %v\n", err)
+			}
+
+			err = checkOfflineNodes()
+			if err != nil {
+				fmt.Printf("checkOfflineNodes() error: %v\n", err)
+			}
+
+			time.Sleep(nodeHeartbeatInterval)
+		}
+	}()
+}
@@ -62,6 +62,20 @@ func InitConfig() {
 	CreateTables()
 }
 
+// InitMemoryAdapter uses an in-memory sqlite DB with the tables created and the caches loaded,
+// which is used by the tests without a database server
+func InitMemoryAdapter() {
+	ormer = NewAdapter("sqlite", ":memory:", "")
+	// each connection of sqlite has its own in-memory DB
+	ormer.Engine.SetMaxOpenConns(1)
+	ormer.createTable()
+
+	err := refreshRuleMap()
+	if err != nil {
+		panic(err)
+	}
+}
+
 func InitAdapter() {
 	if createDatabase {
 		err := createDatabaseForPostgres(conf.GetConfigString("driverName"), conf.GetConfigDataSourceName(), conf.GetConfigString("dbName"))
@@ -203,4 +217,14 @@ func (a *Ormer) createTable() {
 	if err != nil {
 		panic(err)
 	}
+
+	err = a.Engine.Sync2(new(Credential))
+	if err != nil {
+		panic(err)
+	}
+
+	err = a.Engine.Sync2(new(CertJob))
+	if err != nil {
+		panic(err)
+	}
 }
@@ -26,11 +26,29 @@ type Record struct {
 	Owner       string `xorm:"varchar(100) notnull" json:"owner"`
 	CreatedTime string `xorm:"varchar(100) notnull" json:"createdTime"`
 
-	Method    string `xorm:"varchar(100)" json:"method"`
-	Host      string `xorm:"varchar(100)" json:"host"`
-	Path      string `xorm:"varchar(100)" json:"path"`
-	ClientIp  string `xorm:"varchar(100)" json:"clientIp"`
-	UserAgent string `xorm:"varchar(512)" json:"userAgent"`
+	Method     string `xorm:"varchar(100)" json:"method"`
+	Host       string `xorm:"varchar(100)" json:"host"`
+	Path       string `xorm:"varchar(100)" json:"path"`
+	ClientIp   string `xorm:"varchar(100)" json:"clientIp"`
+	UserAgent  string `xorm:"varchar(512)" json:"userAgent"`
+	Credential string `xorm:"varchar(100)" json:"credential"`
+
+	Action     string       `xorm:"varchar(100)" json:"action"`
+	Score      int          `json:"score"`
+	RuleScores []*RuleScore `xorm:"mediumtext" json:"ruleScores"`
+	WafMatches []*WafMatch  `xorm:"mediumtext" json:"wafMatches"`
+}
+
+type RuleScore struct {
+	Rule  string `json:"rule"`
+	Score int    `json:"score"`
+}
+
+type WafMatch struct {
+	Rule      string `json:"rule"`
+	WafRuleId int    `json:"wafRuleId"`
+	Target    string `json:"target"`
+	Message   string `json:"message"`
 }
 
 func GetRecords(owner string) ([]*Record, error) {
@@ -70,6 +88,12 @@ func UpdateRecord(owner string, id string, record *Record) (bool, error) {
 	return affected != 0, nil
 }
 
+// UpdateRecordRuleResult saves the outcome of the rules evaluated for the request of the record
+func UpdateRecordRuleResult(record *Record) error {
+	_, err := ormer.Engine.ID(core.PK{record.Id}).Cols("action", "score", "rule_scores", "waf_matches").Update(record)
+	return err
+}
+
 func GetRecord(owner string, id string) (*Record, error) {
 	idNum, err := strconv.Atoi(id)
 	if err != nil {
@@ -16,6 +16,7 @@ package object
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/casbin/caswaf/util"
 	"github.com/xorm-io/core"
@@ -25,6 +26,9 @@ type Expression struct {
 	Name     string `json:"name"`
 	Operator string `json:"operator"`
 	Value    string `json:"value"`
+
+	regexp *regexp.Regexp
+	err    error
 }
 
 type Rule struct {
@@ -39,8 +43,26 @@ type Rule struct {
 	StatusCode  int           `xorm:"int notnull" json:"statusCode"`
 	Reason      string        `xorm:"varchar(100) notnull" json:"reason"`
 	IsVerbose   bool          `xorm:"bool" json:"isVerbose"`
+	Priority    int           `xorm:"int" json:"priority"`
+	Phase       string        `xorm:"varchar(100)" json:"phase"`
+	Score       int           `xorm:"int" json:"score"`
+
+	ClearanceTtl     int    `xorm:"int" json:"clearanceTtl"`
+	ClearanceBinding string `xorm:"varchar(100)" json:"clearanceBinding"`
+
+	EvalCount   int64   `xorm:"bigint" json:"evalCount"`
+	HitCount    int64   `xorm:"bigint" json:"hitCount"`
+	EvalTime    int64   `xorm:"bigint" json:"evalTime"`
+	AvgEvalTime float64 `xorm:"-" json:"avgEvalTime"`
+	LastHitTime string  `xorm:"varchar(100)" json:"lastHitTime"`
+
+	hasInvalidExpression bool
+	revision             int64
 }
 
+// ruleStatsCols are maintained by AddRuleStats() and not overwritten by UpdateRule()
+var ruleStatsCols = []string{"eval_count", "hit_count", "eval_time", "last_hit_time"}
+
 func GetGlobalRules() ([]*Rule, error) {
 	rules := []*Rule{}
 	err := ormer.Engine.Asc("owner").Desc("created_time").Find(&rules)
@@ -50,7 +72,12 @@ func GetGlobalRules() ([]*Rule, error) {
 func GetRules(owner string) ([]*Rule, error) {
 	rules := []*Rule{}
 	err := ormer.Engine.Desc("updated_time").Find(&rules, &Rule{Owner: owner})
-	return rules, err
+	if err != nil {
+		return rules, err
+	}
+
+	populateRuleStats(rules)
+	return rules, nil
 }
 
 func getRule(owner string, name string) (*Rule, error) {
@@ -60,6 +87,7 @@ func getRule(owner string, name string) (*Rule, error) {
 		return nil, err
 	}
 	if existed {
+		populateRuleStats([]*Rule{&rule})
 		return &rule, nil
 	} else {
 		return nil, nil
@@ -79,7 +107,7 @@ func UpdateRule(id string, rule *Rule) (bool, error) {
 		return false, nil
 	}
 	rule.UpdatedTime = util.GetCurrentTime()
-	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Update(rule)
+	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Omit(ruleStatsCols...).Update(rule)
 	if err != nil {
 		return false, err
 	}
@@ -91,6 +119,9 @@ func UpdateRule(id string, rule *Rule) (bool, error) {
 }
 
 func AddRule(rule *Rule) (bool, error) {
+	// the stats are maintained by AddRuleStats()
+	rule.EvalCount, rule.HitCount, rule.EvalTime, rule.LastHitTime = 0, 0, 0, ""
+
 	affected, err := ormer.Engine.Insert(rule)
 	if err != nil {
 		return false, err
@@ -109,6 +140,12 @@ func DeleteRule(rule *Rule) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if affected != 0 {
+		err = refreshRuleMap()
+		if err != nil {
+			return false, err
+		}
+	}
 
 	return affected != 0, nil
 }
@@ -117,6 +154,42 @@ func (rule *Rule) GetId() string {
 	return fmt.Sprintf("%s/%s", rule.Owner, rule.Name)
 }
 
+// GetRevision returns the revision of the rule expressions, it changes whenever the expressions are
+// edited, while UpdatedTime has second resolution and stays the same for two saves within a second
+func (rule *Rule) GetRevision() int64 {
+	return rule.revision
+}
+
+// GetRegexp returns the compiled regex of the expression value, the regex is
+// precompiled when the rule is loaded by refreshRuleMap() and compiled on demand otherwise
+func (expression *Expression) GetRegexp() (*regexp.Regexp, error) {
+	if expression.regexp != nil {
+		return expression.regexp, nil
+	}
+	return regexp.Compile(expression.Value)
+}
+
+// GetError returns the error of the expression found when the rule is loaded, e.g., an unknown operator
+// of a legacy rule saved before the operators are checked
+func (expression *Expression) GetError() error {
+	return expression.err
+}
+
+// GetValidExpressions returns the expressions of the rule without the invalid ones found when the rule is loaded
+func (rule *Rule) GetValidExpressions() []*Expression {
+	if !rule.hasInvalidExpression {
+		return rule.Expressions
+	}
+
+	res := []*Expression{}
+	for _, expression := range rule.Expressions {
+		if expression.err == nil {
+			res = append(res, expression)
+		}
+	}
+	return res
+}
+
 func GetRuleCount(owner, field, value string) (int64, error) {
 	session := GetSession(owner, -1, -1, field, value, "", "")
 	return session.Count(&Rule{})
@@ -130,5 +203,26 @@ func GetPaginationRules(owner string, offset, limit int, field, value, sortField
 		return rules, err
 	}
 
+	populateRuleStats(rules)
 	return rules, nil
 }
+
+// AddRuleStats adds the evaluation statistics aggregated in memory to the rule in the DB,
+// evalTime is the total evaluation time in microseconds
+func AddRuleStats(id string, evalCount int64, hitCount int64, evalTime int64, lastHitTime string) error {
+	owner, name := util.GetOwnerAndNameFromId(id)
+	_, err := ormer.Engine.ID(core.PK{owner, name}).
+		Incr("eval_count", evalCount).
+		Incr("hit_count", hitCount).
+		Incr("eval_time", evalTime).
+		Update(&Rule{LastHitTime: lastHitTime})
+	return err
+}
+
+func populateRuleStats(rules []*Rule) {
+	for _, rule := range rules {
+		if rule.EvalCount != 0 {
+			rule.AvgEvalTime = float64(rule.EvalTime) / float64(rule.EvalCount)
+		}
+	}
+}
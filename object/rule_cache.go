@@ -16,11 +16,27 @@ package object
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
 
 	"github.com/casbin/caswaf/util"
 )
 
-var ruleMap = map[string]*Rule{}
+var (
+	ruleMap       = map[string]*Rule{}
+	globalRuleMap = map[string][]*Rule{}
+
+	ruleRevision        int64
+	ruleRefreshHandlers []func(rules map[string]*Rule)
+)
+
+// AddRuleRefreshHandler registers a handler called with the rules loaded by refreshRuleMap(), the
+// caches of compiled rules use it to drop the edited and deleted rules
+func AddRuleRefreshHandler(handler func(rules map[string]*Rule)) {
+	ruleRefreshHandlers = append(ruleRefreshHandlers, handler)
+}
 
 func InitRuleMap() {
 	err := refreshRuleMap()
@@ -31,19 +47,47 @@ func InitRuleMap() {
 
 func refreshRuleMap() error {
 	newRuleMap := map[string]*Rule{}
+	newGlobalRuleMap := map[string][]*Rule{}
 	rules, err := GetGlobalRules()
 	if err != nil {
 		return err
 	}
 
 	for _, rule := range rules {
-		newRuleMap[util.GetIdFromOwnerAndName(rule.Owner, rule.Name)] = rule
+		id := util.GetIdFromOwnerAndName(rule.Owner, rule.Name)
+		rule.setRevision(ruleMap[id])
+		err = rule.compileExpressions(ruleMap[id])
+		if err != nil {
+			fmt.Printf("refreshRuleMap() error: rule: %s, the invalid expressions are skipped: %v\n", id, err)
+		}
+
+		newRuleMap[id] = rule
+		if rule.Owner == "admin" && (rule.Phase == "Before" || rule.Phase == "After") {
+			newGlobalRuleMap[rule.Phase] = append(newGlobalRuleMap[rule.Phase], rule)
+		}
+	}
+
+	for _, globalRules := range newGlobalRuleMap {
+		sortRulesByPriority(globalRules)
 	}
 
 	ruleMap = newRuleMap
+	globalRuleMap = newGlobalRuleMap
+	for _, handler := range ruleRefreshHandlers {
+		handler(newRuleMap)
+	}
 	return nil
 }
 
+// setRevision keeps the revision of the old rule if the expressions are unchanged, or takes a new one
+func (rule *Rule) setRevision(oldRule *Rule) {
+	if oldRule != nil && util.StructToJsonNoIndent(oldRule.Expressions) == util.StructToJsonNoIndent(rule.Expressions) {
+		rule.revision = oldRule.revision
+		return
+	}
+	rule.revision = atomic.AddInt64(&ruleRevision, 1)
+}
+
 func GetRulesByRuleIds(ids []string) ([]*Rule, error) {
 	var res []*Rule
 	for _, id := range ids {
@@ -55,3 +99,97 @@ func GetRulesByRuleIds(ids []string) ([]*Rule, error) {
 	}
 	return res, nil
 }
+
+// GetSiteRulesByRuleIds returns the rules to evaluate for a site in order: the global rules of admin with
+// the "Before" phase, the site rules sorted by priority, and then the global rules with the "After" phase.
+// Rules with a smaller priority are evaluated first, rules with the same priority keep their order.
+func GetSiteRulesByRuleIds(ids []string) ([]*Rule, error) {
+	siteRules, err := GetRulesByRuleIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	sortRulesByPriority(siteRules)
+
+	res := []*Rule{}
+	added := map[*Rule]bool{}
+	for _, rules := range [][]*Rule{globalRuleMap["Before"], siteRules, globalRuleMap["After"]} {
+		for _, rule := range rules {
+			if added[rule] {
+				continue
+			}
+			added[rule] = true
+			res = append(res, rule)
+		}
+	}
+	return res, nil
+}
+
+func sortRulesByPriority(rules []*Rule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+}
+
+func isRegexOperator(operator string) bool {
+	return operator == "match" || operator == "does not match"
+}
+
+// IsWholeRuleType returns true if the expressions of the rule type are evaluated as a whole instead of
+// being matched one by one
+func IsWholeRuleType(ruleType string) bool {
+	return ruleType == "WAF" || ruleType == "CRS" || ruleType == "IP Rate Limiting" || ruleType == "Compound"
+}
+
+// compileExpressions checks the operators and precompiles the regexes of the rule's expressions, an invalid
+// expression is marked to be skipped by the requests. The regexes of oldRule are reused if it has the same
+// version (updated time) as rule.
+func (rule *Rule) compileExpressions(oldRule *Rule) error {
+	errs := []string{}
+	if operators := getRuleOperators(rule.Type); operators != nil {
+		for _, expression := range rule.Expressions {
+			err := checkExpression(operators, rule.Type, expression)
+			if err != nil {
+				expression.err = err
+				rule.hasInvalidExpression = true
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if IsWholeRuleType(rule.Type) {
+		return joinErrors(errs)
+	}
+
+	isSameVersion := oldRule != nil && oldRule.UpdatedTime == rule.UpdatedTime && len(oldRule.Expressions) == len(rule.Expressions)
+	for i, expression := range rule.Expressions {
+		if !isRegexOperator(expression.Operator) || expression.err != nil {
+			continue
+		}
+
+		if isSameVersion {
+			oldExpression := oldRule.Expressions[i]
+			if oldExpression.regexp != nil && oldExpression.Value == expression.Value {
+				expression.regexp = oldExpression.regexp
+				continue
+			}
+		}
+
+		re, err := regexp.Compile(expression.Value)
+		if err != nil {
+			expression.err = fmt.Errorf("invalid regex: %s, %v", expression.Value, err)
+			rule.hasInvalidExpression = true
+			errs = append(errs, expression.err.Error())
+			continue
+		}
+		expression.regexp = re
+	}
+
+	return joinErrors(errs)
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var stringOperators = []string{
+	"exists", "does not exist",
+	"contains", "does not contain",
+	"equals", "does not equal",
+	"starts with", "ends with",
+	"is in", "is not in",
+	"match", "does not match",
+}
+
+var numberOperators = []string{
+	"exists", "does not exist",
+	"equals", "does not equal",
+	"greater than", "greater than or equal to",
+	"less than", "less than or equal to",
+}
+
+var ipOperators = []string{"is in", "is not in", "is abroad"}
+
+var compoundOperators = []string{"begin", "and", "or", "expression"}
+
+var crsOperators = []string{"paranoia level", "enable group", "exclude rule"}
+
+func getRuleOperators(ruleType string) []string {
+	switch ruleType {
+	case "User-Agent", "Header", "Cookie", "Query", "Method", "Path", "Host", "Content-Type", "Client Cert":
+		return stringOperators
+	case "Content-Length":
+		return numberOperators
+	case "IP":
+		return ipOperators
+	case "Compound":
+		return compoundOperators
+	case "CRS":
+		return crsOperators
+	default:
+		return nil
+	}
+}
+
+// checkExpression checks that the expression uses an operator supported by the rule type and that the
+// regex of a "match" or "does not match" expression can be compiled
+func checkExpression(operators []string, ruleType string, expression *Expression) error {
+	if !containsString(operators, expression.Operator) {
+		return fmt.Errorf("unknown operator: %s for rule type: %s", expression.Operator, ruleType)
+	}
+
+	if isRegexOperator(expression.Operator) {
+		_, err := regexp.Compile(expression.Value)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %s, %v", expression.Value, err)
+		}
+	}
+	return nil
+}
+
+// CheckRuleOperators checks that every expression uses an operator supported by the rule type
+// and that the regexes of "match" and "does not match" expressions can be compiled.
+// Rule types whose expressions have no operator (e.g., "WAF") are not checked.
+func CheckRuleOperators(ruleType string, expressions []*Expression) error {
+	operators := getRuleOperators(ruleType)
+	if operators == nil {
+		return nil
+	}
+
+	for _, expression := range expressions {
+		err := checkExpression(operators, ruleType, expression)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
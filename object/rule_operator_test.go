@@ -0,0 +1,48 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestCheckRuleOperators(t *testing.T) {
+	tests := []struct {
+		name       string
+		ruleType   string
+		expression *Expression
+		wantErr    bool
+	}{
+		{"ua contains", "User-Agent", &Expression{Operator: "contains", Value: "curl"}, false},
+		{"ua valid regex", "User-Agent", &Expression{Operator: "match", Value: "^Mozilla/5\\.0"}, false},
+		{"ua invalid regex", "User-Agent", &Expression{Operator: "match", Value: "(curl"}, true},
+		{"ua unknown operator", "User-Agent", &Expression{Operator: "is abroad", Value: ""}, true},
+		{"header invalid negated regex", "Header", &Expression{Name: "X-Test", Operator: "does not match", Value: "[a-"}, true},
+		{"ip is abroad", "IP", &Expression{Operator: "is abroad"}, false},
+		{"ip unknown operator", "IP", &Expression{Operator: "contains", Value: "127.0.0.1"}, true},
+		{"content length number operator", "Content-Length", &Expression{Operator: "greater than", Value: "1024"}, false},
+		{"content length string operator", "Content-Length", &Expression{Operator: "match", Value: "1"}, true},
+		{"compound or", "Compound", &Expression{Operator: "or", Value: "admin/rule1"}, false},
+		{"compound unknown operator", "Compound", &Expression{Operator: "xor", Value: "admin/rule1"}, true},
+		{"waf is not checked", "WAF", &Expression{Operator: "", Value: "SecRule"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRuleOperators(tt.ruleType, []*Expression{tt.expression})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRuleOperators() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/casbin/caswaf/notifier"
 	"github.com/casbin/caswaf/run"
 	"github.com/casbin/caswaf/util"
 	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
@@ -43,30 +44,44 @@ type Site struct {
 	UpdatedTime string `xorm:"varchar(100)" json:"updatedTime"`
 	DisplayName string `xorm:"varchar(100)" json:"displayName"`
 
-	Tag            string      `xorm:"varchar(100)" json:"tag"`
-	Domain         string      `xorm:"varchar(100)" json:"domain"`
-	OtherDomains   []string    `xorm:"varchar(500)" json:"otherDomains"`
-	NeedRedirect   bool        `json:"needRedirect"`
-	DisableVerbose bool        `json:"disableVerbose"`
-	Rules          []string    `xorm:"varchar(500)" json:"rules"`
-	EnableAlert    bool        `json:"enableAlert"`
-	AlertInterval  int         `json:"alertInterval"`
-	AlertTryTimes  int         `json:"alertTryTimes"`
-	AlertProviders []string    `xorm:"varchar(500)" json:"alertProviders"`
-	Challenges     []string    `xorm:"mediumtext" json:"challenges"`
-	Host           string      `xorm:"varchar(100)" json:"host"`
-	Port           int         `json:"port"`
-	Hosts          []string    `xorm:"varchar(1000)" json:"hosts"`
-	SslMode        string      `xorm:"varchar(100)" json:"sslMode"`
-	SslCert        string      `xorm:"-" json:"sslCert"`
-	PublicIp       string      `xorm:"varchar(100)" json:"publicIp"`
-	Node           string      `xorm:"varchar(100)" json:"node"`
-	IsSelf         bool        `json:"isSelf"`
-	Status         string      `xorm:"varchar(100)" json:"status"`
-	Nodes          []*NodeItem `xorm:"mediumtext" json:"nodes"`
+	Tag            string            `xorm:"varchar(100)" json:"tag"`
+	Domain         string            `xorm:"varchar(100)" json:"domain"`
+	OtherDomains   []string          `xorm:"varchar(500)" json:"otherDomains"`
+	NeedRedirect   bool              `json:"needRedirect"`
+	DisableVerbose bool              `json:"disableVerbose"`
+	Rules          []string          `xorm:"varchar(500)" json:"rules"`
+	CaptchaScore   int               `json:"captchaScore"`
+	BlockScore     int               `json:"blockScore"`
+	EnableAlert    bool              `json:"enableAlert"`
+	AlertInterval  int               `json:"alertInterval"`
+	AlertTryTimes  int               `json:"alertTryTimes"`
+	AlertProviders []string          `xorm:"varchar(500)" json:"alertProviders"`
+	AlertSecrets   map[string]string `xorm:"mediumtext" json:"alertSecrets"`
+	Challenges     []string          `xorm:"mediumtext" json:"challenges"`
+	Host           string            `xorm:"varchar(100)" json:"host"`
+	Port           int               `json:"port"`
+	Hosts          []string          `xorm:"varchar(1000)" json:"hosts"`
+	SslMode        string            `xorm:"varchar(100)" json:"sslMode"`
+	SslCert        string            `xorm:"-" json:"sslCert"`
+	CertKeyType    string            `xorm:"varchar(100)" json:"certKeyType"`
+	EnableDualCert bool              `json:"enableDualCert"`
+	CertChallenge  string            `xorm:"varchar(100)" json:"certChallenge"`
+	ClientAuthMode string            `xorm:"varchar(100)" json:"clientAuthMode"`
+	ClientCaCert   string            `xorm:"varchar(100)" json:"clientCaCert"`
+	PublicIp       string            `xorm:"varchar(100)" json:"publicIp"`
+	Node           string            `xorm:"varchar(100)" json:"node"`
+	IsSelf         bool              `json:"isSelf"`
+	Status         string            `xorm:"varchar(100)" json:"status"`
+	Nodes          []*NodeItem       `xorm:"mediumtext" json:"nodes"`
 
 	CasdoorApplication string                  `xorm:"varchar(100)" json:"casdoorApplication"`
 	ApplicationObj     *casdoorsdk.Application `xorm:"-" json:"applicationObj"`
+	IdentityHeaders    []string                `xorm:"varchar(500)" json:"identityHeaders"`
+	IdentityJwtSecret  string                  `xorm:"varchar(100)" json:"identityJwtSecret"`
+	AccessPolicy       string                  `xorm:"mediumtext" json:"accessPolicy"`
+	AuthMode           string                  `xorm:"varchar(100)" json:"authMode"`
+	ApiKeyHeader       string                  `xorm:"varchar(100)" json:"apiKeyHeader"`
+	ApiKeyParam        string                  `xorm:"varchar(100)" json:"apiKeyParam"`
 }
 
 func GetGlobalSites() ([]*Site, error) {
@@ -140,6 +155,25 @@ func GetMaskedSite(site *Site, node string) *Site {
 		site.IsSelf = true
 	}
 
+	if site.IdentityJwtSecret != "" {
+		site.IdentityJwtSecret = "***"
+	}
+	// the URLs of the alert providers carry the tokens of the chat bots, so they are masked as the keys of
+	// the alert secrets too
+	alertSecrets := map[string]string{}
+	for provider, secret := range site.AlertSecrets {
+		if secret != "" {
+			secret = "***"
+		}
+		alertSecrets[notifier.MaskTarget(provider)] = secret
+	}
+	if site.AlertSecrets != nil {
+		site.AlertSecrets = alertSecrets
+	}
+	for i, provider := range site.AlertProviders {
+		site.AlertProviders[i] = notifier.MaskTarget(provider)
+	}
+
 	return site
 }
 
@@ -152,15 +186,40 @@ func GetMaskedSites(sites []*Site, node string) []*Site {
 
 func UpdateSite(id string, site *Site) (bool, error) {
 	owner, name := util.GetOwnerAndNameFromId(id)
-	if s, err := getSite(owner, name); err != nil {
+	s, err := getSite(owner, name)
+	if err != nil {
 		return false, err
 	} else if s == nil {
 		return false, nil
 	}
 
+	// the masked secrets are not changed
+	if site.IdentityJwtSecret == "***" {
+		site.IdentityJwtSecret = s.IdentityJwtSecret
+	}
+	providerMap := notifier.UnmaskTargets(s.AlertProviders)
+	for i, provider := range site.AlertProviders {
+		if oldProvider, ok := providerMap[provider]; ok {
+			site.AlertProviders[i] = oldProvider
+		}
+	}
+	alertSecrets := map[string]string{}
+	for provider, secret := range site.AlertSecrets {
+		if oldProvider, ok := providerMap[provider]; ok {
+			provider = oldProvider
+		}
+		if secret == "***" {
+			secret = s.AlertSecrets[provider]
+		}
+		alertSecrets[provider] = secret
+	}
+	if site.AlertSecrets != nil {
+		site.AlertSecrets = alertSecrets
+	}
+
 	site.UpdatedTime = util.GetCurrentTime()
 
-	_, err := ormer.Engine.ID(core.PK{owner, name}).AllCols().Update(site)
+	_, err = ormer.Engine.ID(core.PK{owner, name}).AllCols().Update(site)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,112 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+// accessModelText is the Casbin model of the access policy of a site. The first policy matching the
+// request decides, and the request is denied if no policy matches, e.g.,
+//
+//	p, *, /public/*, *, allow
+//	p, role:admin, /admin/*, *, allow
+//	p, *, /admin/*, *, deny
+//	p, authenticated, /*, GET|POST, allow
+//
+// The subject of a policy is one of: "*", "anonymous", "authenticated", "user:<organization>/<name>",
+// "org:<organization>", "group:<group>" and "role:<role>".
+const accessModelText = `
+[request_definition]
+r = sub, path, method
+
+[policy_definition]
+p = sub, path, method, eft
+
+[policy_effect]
+e = priority(p.eft) || deny
+
+[matchers]
+m = subjectMatch(r.sub, p.sub) && keyMatch2(r.path, p.path) && (p.method == "*" || regexMatch(r.method, "^(" + p.method + ")$"))
+`
+
+func subjectMatch(args ...interface{}) (interface{}, error) {
+	subjects, ok := args[0].([]string)
+	if !ok {
+		return false, fmt.Errorf("subjectMatch() error: the request subject should be a string slice")
+	}
+	policySubject, ok := args[1].(string)
+	if !ok {
+		return false, fmt.Errorf("subjectMatch() error: the policy subject should be a string")
+	}
+
+	if policySubject == "*" {
+		return true, nil
+	}
+	for _, subject := range subjects {
+		if subject == policySubject {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NewAccessEnforcer parses the access policy, which has a "p, sub, path, method, eft" policy per line
+func NewAccessEnforcer(policy string) (*casbin.SyncedEnforcer, error) {
+	m, err := model.NewModelFromString(accessModelText)
+	if err != nil {
+		return nil, err
+	}
+
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		return nil, err
+	}
+	enforcer.AddFunction("subjectMatch", subjectMatch)
+
+	for i, line := range strings.Split(policy, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := strings.Split(line, ",")
+		for j := range tokens {
+			tokens[j] = strings.TrimSpace(tokens[j])
+		}
+		if len(tokens) != 5 || tokens[0] != "p" {
+			return nil, fmt.Errorf("the access policy of line %d should be \"p, sub, path, method, eft\": %s", i+1, line)
+		}
+		if tokens[4] != "allow" && tokens[4] != "deny" {
+			return nil, fmt.Errorf("the effect of the access policy of line %d should be \"allow\" or \"deny\": %s", i+1, line)
+		}
+
+		_, err = enforcer.AddPolicy(tokens[1], tokens[2], tokens[3], tokens[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return enforcer, nil
+}
+
+func CheckAccessPolicy(policy string) error {
+	_, err := NewAccessEnforcer(policy)
+	return err
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "testing"
+
+func TestCheckAccessPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		wantErr bool
+	}{
+		{"", false},
+		{"# comment\np, *, /public/*, *, allow\r\n", false},
+		{"p, *, /public/*, allow", true},
+		{"g, alice, admin", true},
+		{"p, *, /public/*, *, permit", true},
+	}
+
+	for _, tt := range tests {
+		err := CheckAccessPolicy(tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckAccessPolicy(%q) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+		}
+	}
+}
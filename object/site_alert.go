@@ -0,0 +1,30 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "github.com/casbin/caswaf/notifier"
+
+// SendSiteAlert sends the event of the site to its alert providers, the Email and SMS ones are sent to
+// the site owner, and the alert secret of each provider signs its messages
+func SendSiteAlert(site *Site, event *notifier.Event) error {
+	event.Owner = site.Owner
+	event.Site = site.GetId()
+	return notifier.Notify(event, site.AlertProviders, site.AlertSecrets)
+}
+
+// isAlertEnabled returns whether the site sends alerts, the same as its health check
+func (site *Site) isAlertEnabled() bool {
+	return site.EnableAlert && site.Status != "Inactive"
+}
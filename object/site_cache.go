@@ -82,7 +82,7 @@ func refreshSiteMap() error {
 		return err
 	}
 
-	certMap, err = getCertMap()
+	err = refreshCertMap()
 	if err != nil {
 		return err
 	}
@@ -118,6 +118,7 @@ func refreshSiteMap() error {
 	}
 
 	siteMap = newSiteMap
+	refreshTlsAlpnKeyAuthMap(sites)
 	healthCheckNeededDomains = newHealthCheckNeededDomains
 	return nil
 }
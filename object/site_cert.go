@@ -72,14 +72,13 @@ func (site *Site) preCheckCertForDomain(domain string) (bool, error) {
 		return false, err
 	}
 
+	lock.Lock()
 	site.Challenges = []string{fmt.Sprintf("%s:%s", token, keyAuth)}
-
 	_, err = UpdateSiteNoRefresh(site.GetId(), site)
-	if err != nil {
-		return false, err
+	if err == nil {
+		err = refreshSiteMap()
 	}
-
-	err = refreshSiteMap()
+	lock.Unlock()
 	if err != nil {
 		return false, err
 	}
@@ -99,13 +98,13 @@ func (site *Site) preCheckCertForDomain(domain string) (bool, error) {
 		}
 	}
 
+	lock.Lock()
 	site.Challenges = []string{}
 	_, err = UpdateSiteNoRefresh(site.GetId(), site)
-	if err != nil {
-		return false, err
+	if err == nil {
+		err = refreshSiteMap()
 	}
-
-	err = refreshSiteMap()
+	lock.Unlock()
 	if err != nil {
 		return false, err
 	}
@@ -114,20 +113,40 @@ func (site *Site) preCheckCertForDomain(domain string) (bool, error) {
 }
 
 func (site *Site) updateCertForDomain(domain string) error {
-	ok, err := site.preCheckCertForDomain(domain)
+	// the TLS-ALPN-01 challenge is answered by the HTTPS listener, so the port 80 is not checked
+	if !site.isTlsAlpnChallenge() {
+		ok, err := site.preCheckCertForDomain(domain)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("preCheckCertForDomain(): the challenge of domain: %s is not reachable by HTTP", domain)
+		}
+	}
+
+	// the cert is renewed by the ACME CA of the previous one
+	oldCert, err := getCert(site.Owner, domain)
 	if err != nil {
 		return err
 	}
-	if !ok {
-		fmt.Printf("preCheckCertForDomain(): not ok for domain: %s\n", domain)
-		return nil
+	if oldCert == nil {
+		oldCert = &Cert{}
 	}
 
-	certificate, privateKey, err := getHttp01Cert(site.GetId(), domain)
+	keyType, eccKeyType := site.getCertKeyTypes()
+	certificate, privateKey, err := getSiteCert(site, domain, keyType, oldCert)
 	if err != nil {
 		return err
 	}
 
+	eccCertificate, eccPrivateKey := "", ""
+	if eccKeyType != "" {
+		eccCertificate, eccPrivateKey, err = getSiteCert(site, domain, eccKeyType, oldCert)
+		if err != nil {
+			return err
+		}
+	}
+
 	expireTime, err := getCertExpireTime(certificate)
 	if err != nil {
 		fmt.Printf("getCertExpireTime() error: %v\n", err)
@@ -144,7 +163,7 @@ func (site *Site) updateCertForDomain(domain string) error {
 		CreatedTime:      util.GetCurrentTime(),
 		DisplayName:      domain,
 		Type:             "SSL",
-		CryptoAlgorithm:  "RSA",
+		CryptoAlgorithm:  getCryptoAlgorithm(keyType),
 		ExpireTime:       expireTime,
 		DomainExpireTime: domainExpireTime,
 		Provider:         "",
@@ -153,6 +172,12 @@ func (site *Site) updateCertForDomain(domain string) error {
 		AccessSecret:     "",
 		Certificate:      certificate,
 		PrivateKey:       privateKey,
+		EccCertificate:   eccCertificate,
+		EccPrivateKey:    eccPrivateKey,
+		AcmeDirectoryUrl: oldCert.AcmeDirectoryUrl,
+		AcmeEabKeyId:     oldCert.AcmeEabKeyId,
+		AcmeEabHmacKey:   oldCert.AcmeEabHmacKey,
+		AcmeCaBundle:     oldCert.AcmeCaBundle,
 	}
 
 	_, err = DeleteCert(&cert)
@@ -173,17 +198,10 @@ func (site *Site) updateCertForDomain(domain string) error {
 	return nil
 }
 
+// checkCerts queues the cert jobs of the domains whose cert is missing or near expire
 func (site *Site) checkCerts() error {
-	hostname := util.GetHostname()
-	if len(site.Nodes) != 0 {
-		if site.Nodes[0].Name != hostname {
-			return nil
-		}
-	} else {
-		nodeName := getNodeNameFromTag(site.Tag)
-		if nodeName != hostname {
-			return nil
-		}
+	if site.getCertNode() != util.GetHostname() {
+		return nil
 	}
 
 	domains := []string{}
@@ -205,6 +223,7 @@ func (site *Site) checkCerts() error {
 			return err
 		}
 
+		jobType := "Issue"
 		if cert != nil {
 			var nearExpire bool
 			nearExpire, err = cert.isCertNearExpire()
@@ -215,9 +234,10 @@ func (site *Site) checkCerts() error {
 			if !nearExpire {
 				continue
 			}
+			jobType = "Renew"
 		}
 
-		err = site.updateCertForDomain(domain)
+		_, err = site.addCertJob(domain, jobType, false)
 		if err != nil {
 			return err
 		}
@@ -47,7 +47,7 @@ func (a *Account) GetRegistration() *registration.Resource {
 	return a.Registration
 }
 
-func getLegoClientAndAccount(email string, privateKey string, devMode bool, useProxy bool) (*lego.Client, *Account, error) {
+func getLegoClientAndAccount(email string, privateKey string, acmeConfig *AcmeConfig, useProxy bool) (*lego.Client, *Account, error) {
 	eccKey, err := decodeEccKey(privateKey)
 	if err != nil {
 		return nil, nil, err
@@ -59,18 +59,16 @@ func getLegoClientAndAccount(email string, privateKey string, devMode bool, useP
 	}
 
 	config := lego.NewConfig(account)
-	if devMode {
-		config.CADirURL = lego.LEDirectoryStaging
-	} else {
-		config.CADirURL = lego.LEDirectoryProduction
-	}
-
+	config.CADirURL = acmeConfig.getDirectoryUrl()
 	config.Certificate.KeyType = certcrypto.RSA2048
 
+	httpClient := proxy.DefaultHttpClient
 	if useProxy {
-		config.HTTPClient = proxy.ProxyHttpClient
-	} else {
-		config.HTTPClient = proxy.DefaultHttpClient
+		httpClient = proxy.ProxyHttpClient
+	}
+	config.HTTPClient, err = getAcmeHttpClient(httpClient, acmeConfig.CaBundle)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	client, err := lego.NewClient(config)
@@ -81,9 +79,9 @@ func getLegoClientAndAccount(email string, privateKey string, devMode bool, useP
 	return client, account, nil
 }
 
-func getAcmeClient(email string, privateKey string, devMode bool, useProxy bool) (*lego.Client, error) {
+func getAcmeClient(email string, privateKey string, acmeConfig *AcmeConfig, useProxy bool) (*lego.Client, error) {
 	// Create a user. New accounts need an email and private key to start.
-	client, account, err := getLegoClientAndAccount(email, privateKey, devMode, useProxy)
+	client, account, err := getLegoClientAndAccount(email, privateKey, acmeConfig, useProxy)
 	if err != nil {
 		return nil, err
 	}
@@ -97,11 +95,20 @@ func getAcmeClient(email string, privateKey string, devMode bool, useProxy bool)
 		}
 
 		if acmeError.Type != "urn:ietf:params:acme:error:accountDoesNotExist" {
-			panic(acmeError)
+			return nil, acmeError
 		}
 
 		// Failed to get account, so create an account based on the private key.
-		account.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if acmeConfig.EabKeyId != "" {
+			// the CA requires the account to be bound to an account of the CA, e.g., ZeroSSL
+			account.Registration, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+				TermsOfServiceAgreed: true,
+				Kid:                  acmeConfig.EabKeyId,
+				HmacEncoded:          acmeConfig.EabHmacKey,
+			})
+		} else {
+			account.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -110,7 +117,8 @@ func getAcmeClient(email string, privateKey string, devMode bool, useProxy bool)
 	return client, nil
 }
 
-func GetAcmeClient(useProxy bool) (*lego.Client, error) {
+// GetAcmeClient returns the client of the ACME CA of the cert, the cert can be nil to use the global ACME CA
+func GetAcmeClient(cert *Cert, useProxy bool) (*lego.Client, error) {
 	acmeEmail := beego.AppConfig.String("acmeEmail")
 	acmePrivateKey := beego.AppConfig.String("acmePrivateKey")
 	if acmeEmail == "" {
@@ -120,5 +128,10 @@ func GetAcmeClient(useProxy bool) (*lego.Client, error) {
 		return nil, fmt.Errorf("acmePrivateKey should not be empty")
 	}
 
-	return getAcmeClient(acmeEmail, acmePrivateKey, false, useProxy)
+	acmeConfig, err := cert.getAcmeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return getAcmeClient(acmeEmail, acmePrivateKey, acmeConfig, useProxy)
 }
@@ -0,0 +1,133 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/lego/v4/lego"
+)
+
+// AcmeConfig is the ACME CA issuing the certs, which is Let's Encrypt by default
+type AcmeConfig struct {
+	DirectoryUrl string
+	EabKeyId     string
+	EabHmacKey   string
+	// CaBundle is the PEM roots trusted for the ACME server besides the system ones, e.g., of a private step-ca
+	CaBundle string
+}
+
+// getGlobalAcmeConfig returns the ACME CA configured by acmeDirectoryUrl, acmeEabKeyId, acmeEabHmacKey
+// and acmeCaBundle, which is the path of the PEM file
+func getGlobalAcmeConfig() (*AcmeConfig, error) {
+	res := &AcmeConfig{
+		DirectoryUrl: conf.GetConfigString("acmeDirectoryUrl"),
+		EabKeyId:     conf.GetConfigString("acmeEabKeyId"),
+		EabHmacKey:   conf.GetConfigString("acmeEabHmacKey"),
+	}
+
+	caBundlePath := conf.GetConfigString("acmeCaBundle")
+	if caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, err
+		}
+		res.CaBundle = string(caBundle)
+	}
+	return res, nil
+}
+
+// getAcmeConfig returns the ACME CA of the cert, the global one is used if the cert has no directory URL
+func (cert *Cert) getAcmeConfig() (*AcmeConfig, error) {
+	if cert == nil || cert.AcmeDirectoryUrl == "" {
+		return getGlobalAcmeConfig()
+	}
+
+	return &AcmeConfig{
+		DirectoryUrl: cert.AcmeDirectoryUrl,
+		EabKeyId:     cert.AcmeEabKeyId,
+		EabHmacKey:   cert.AcmeEabHmacKey,
+		CaBundle:     cert.AcmeCaBundle,
+	}, nil
+}
+
+func (config *AcmeConfig) getDirectoryUrl() string {
+	if config.DirectoryUrl == "" {
+		return lego.LEDirectoryProduction
+	}
+	return config.DirectoryUrl
+}
+
+// CheckAcmeConfig checks the ACME CA of a cert or the global one before it is used
+func CheckAcmeConfig(config *AcmeConfig) error {
+	if config.DirectoryUrl != "" {
+		u, err := url.Parse(config.DirectoryUrl)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("the ACME directory URL should be an HTTPS URL: %s", config.DirectoryUrl)
+		}
+	}
+
+	if config.DirectoryUrl == "" && (config.EabKeyId != "" || config.CaBundle != "") {
+		return fmt.Errorf("the ACME directory URL should be set for the EAB key or CA bundle")
+	}
+
+	if (config.EabKeyId == "") != (config.EabHmacKey == "") {
+		return fmt.Errorf("the EAB key ID and HMAC key should be set together")
+	}
+
+	if config.CaBundle != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(config.CaBundle)) {
+		return fmt.Errorf("the ACME CA bundle has no valid PEM certificate")
+	}
+	return nil
+}
+
+// getAcmeHttpClient returns the HTTP client trusting the CA bundle of the ACME server, the proxy of
+// the base client is kept
+func getAcmeHttpClient(base *http.Client, caBundle string) (*http.Client, error) {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	if caBundle == "" {
+		return base, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, fmt.Errorf("the ACME CA bundle has no valid PEM certificate")
+	}
+
+	transport, ok := base.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+
+	res := *base
+	res.Transport = transport
+	return &res, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type testJws struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// testAcmeServer is a stand-in of Pebble, which only supports the account creation with EAB
+type testAcmeServer struct {
+	*httptest.Server
+	eabKeyId   string
+	eabHmacKey []byte
+
+	lock     sync.Mutex
+	accounts map[string]bool
+}
+
+func newTestAcmeServer(t *testing.T, eabKeyId string, eabHmacKey []byte) *testAcmeServer {
+	s := &testAcmeServer{eabKeyId: eabKeyId, eabHmacKey: eabHmacKey, accounts: map[string]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dir", func(w http.ResponseWriter, r *http.Request) {
+		writeTestAcmeJson(w, http.StatusOK, map[string]interface{}{
+			"newNonce":   s.URL + "/nonce",
+			"newAccount": s.URL + "/account",
+			"newOrder":   s.URL + "/order",
+			"meta":       map[string]interface{}{"externalAccountRequired": true},
+		})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+	})
+	mux.HandleFunc("/account", s.handleNewAccount)
+
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func writeTestAcmeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Replay-Nonce", "nonce")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeTestAcmeProblem(w http.ResponseWriter, problemType string, detail string) {
+	writeTestAcmeJson(w, http.StatusBadRequest, map[string]interface{}{
+		"type":   "urn:ietf:params:acme:error:" + problemType,
+		"detail": detail,
+		"status": http.StatusBadRequest,
+	})
+}
+
+func decodeTestJws(data []byte, v interface{}) (*testJws, error) {
+	var jws testJws
+	err := json.Unmarshal(data, &jws)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return &jws, json.Unmarshal(payload, v)
+}
+
+func (s *testAcmeServer) handleNewAccount(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeTestAcmeProblem(w, "malformed", err.Error())
+		return
+	}
+
+	var req struct {
+		OnlyReturnExisting     bool            `json:"onlyReturnExisting"`
+		ExternalAccountBinding json.RawMessage `json:"externalAccountBinding"`
+	}
+	jws, err := decodeTestJws(body, &req)
+	if err != nil {
+		writeTestAcmeProblem(w, "malformed", err.Error())
+		return
+	}
+
+	// the account is identified by the protected header, which only differs in the key as the nonce is constant
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.accounts[jws.Protected] || req.OnlyReturnExisting {
+		if !s.accounts[jws.Protected] {
+			writeTestAcmeProblem(w, "accountDoesNotExist", "no account exists with the provided key")
+			return
+		}
+		w.Header().Set("Location", s.URL+"/account/1")
+		writeTestAcmeJson(w, http.StatusOK, map[string]interface{}{"status": "valid"})
+		return
+	}
+
+	if req.ExternalAccountBinding == nil {
+		writeTestAcmeProblem(w, "externalAccountRequired", "the external account binding is required")
+		return
+	}
+
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	eab, err := decodeTestJws(req.ExternalAccountBinding, &json.RawMessage{})
+	if err == nil {
+		var protected []byte
+		protected, err = base64.RawURLEncoding.DecodeString(eab.Protected)
+		if err == nil {
+			err = json.Unmarshal(protected, &header)
+		}
+	}
+	if err != nil {
+		writeTestAcmeProblem(w, "malformed", err.Error())
+		return
+	}
+
+	mac := hmac.New(sha256.New, s.eabHmacKey)
+	mac.Write([]byte(eab.Protected + "." + eab.Payload))
+	if header.Kid != s.eabKeyId || base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) != eab.Signature {
+		writeTestAcmeProblem(w, "unauthorized", fmt.Sprintf("the external account binding of kid: %s is invalid", header.Kid))
+		return
+	}
+
+	s.accounts[jws.Protected] = true
+	w.Header().Set("Location", s.URL+"/account/1")
+	writeTestAcmeJson(w, http.StatusCreated, map[string]interface{}{"status": "valid"})
+}
+
+func newTestAccountKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestGetAcmeClient(t *testing.T) {
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	server := newTestAcmeServer(t, "kid-1", hmacKey)
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	accountKey := newTestAccountKey(t)
+
+	config := &AcmeConfig{
+		DirectoryUrl: server.URL + "/dir",
+		EabKeyId:     "kid-1",
+		EabHmacKey:   base64.RawURLEncoding.EncodeToString(hmacKey),
+	}
+	err := CheckAcmeConfig(config)
+	if err != nil {
+		t.Fatalf("CheckAcmeConfig() error = %v", err)
+	}
+
+	// the ACME server is not trusted without the CA bundle
+	_, err = getAcmeClient("admin@example.com", accountKey, config, false)
+	if err == nil {
+		t.Fatalf("getAcmeClient() without the CA bundle should fail")
+	}
+
+	config.CaBundle = caBundle
+	_, err = getAcmeClient("admin@example.com", accountKey, config, false)
+	if err != nil {
+		t.Fatalf("getAcmeClient() error = %v", err)
+	}
+
+	// the registered account is resolved by the key without EAB
+	_, err = getAcmeClient("admin@example.com", accountKey, &AcmeConfig{DirectoryUrl: config.DirectoryUrl, CaBundle: caBundle}, false)
+	if err != nil {
+		t.Fatalf("getAcmeClient() of the registered account error = %v", err)
+	}
+
+	config.EabHmacKey = base64.RawURLEncoding.EncodeToString([]byte("wrong"))
+	_, err = getAcmeClient("admin@example.com", newTestAccountKey(t), config, false)
+	if err == nil {
+		t.Fatalf("getAcmeClient() with a wrong EAB HMAC key should fail")
+	}
+}
+
+func TestCheckAcmeConfig(t *testing.T) {
+	tests := []struct {
+		config  AcmeConfig
+		wantErr bool
+	}{
+		{AcmeConfig{}, false},
+		{AcmeConfig{DirectoryUrl: "https://acme.zerossl.com/v2/DV90", EabKeyId: "kid", EabHmacKey: "key"}, false},
+		{AcmeConfig{DirectoryUrl: "http://localhost:14000/dir"}, true},
+		{AcmeConfig{DirectoryUrl: "https://localhost:14000/dir", EabKeyId: "kid"}, true},
+		{AcmeConfig{EabKeyId: "kid", EabHmacKey: "key"}, true},
+		{AcmeConfig{DirectoryUrl: "https://localhost:14000/dir", CaBundle: "invalid"}, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckAcmeConfig(&tt.config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckAcmeConfig(%+v) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
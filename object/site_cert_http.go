@@ -17,6 +17,7 @@ package object
 import (
 	"fmt"
 
+	"github.com/casbin/lego/v4/certcrypto"
 	"github.com/casbin/lego/v4/certificate"
 )
 
@@ -25,6 +26,10 @@ type HttpProvider struct {
 }
 
 func (p *HttpProvider) Present(domain string, token string, keyAuth string) error {
+	// the challenges are written with the lock of the site monitor, which also updates the site
+	lock.Lock()
+	defer lock.Unlock()
+
 	site, err := GetSite(p.siteId)
 	if err != nil {
 		return err
@@ -45,6 +50,9 @@ func (p *HttpProvider) Present(domain string, token string, keyAuth string) erro
 }
 
 func (p *HttpProvider) CleanUp(domain string, token string, keyAuth string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
 	site, err := GetSite(p.siteId)
 	if err != nil {
 		return err
@@ -64,21 +72,31 @@ func (p *HttpProvider) CleanUp(domain string, token string, keyAuth string) erro
 	return nil
 }
 
-func getHttp01Cert(siteId string, domain string) (string, string, error) {
-	client, err := GetAcmeClient(false)
+// getSiteCert obtains the cert of the domain by the HTTP-01 or TLS-ALPN-01 challenge of the site
+func getSiteCert(site *Site, domain string, keyType certcrypto.KeyType, cert *Cert) (string, string, error) {
+	client, err := GetAcmeClient(cert, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	if site.isTlsAlpnChallenge() {
+		err = client.Challenge.SetTLSALPN01Provider(&TlsAlpnProvider{siteId: site.GetId()})
+	} else {
+		err = client.Challenge.SetHTTP01Provider(&HttpProvider{siteId: site.GetId()})
+	}
 	if err != nil {
 		return "", "", err
 	}
 
-	provider := HttpProvider{siteId: siteId}
-	err = client.Challenge.SetHTTP01Provider(&provider)
+	privateKey, err := certcrypto.GeneratePrivateKey(keyType)
 	if err != nil {
 		return "", "", err
 	}
 
 	request := certificate.ObtainRequest{
-		Domains: []string{domain},
-		Bundle:  true,
+		Domains:    []string{domain},
+		Bundle:     true,
+		PrivateKey: privateKey,
 	}
 
 	resource, err := client.Certificate.Obtain(request)
@@ -0,0 +1,60 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"github.com/casbin/lego/v4/certcrypto"
+)
+
+var certKeyTypes = map[string]certcrypto.KeyType{
+	"RSA 2048": certcrypto.RSA2048,
+	"RSA 4096": certcrypto.RSA4096,
+	"EC P-256": certcrypto.EC256,
+	"EC P-384": certcrypto.EC384,
+}
+
+// IsValidCertKeyType returns whether the key type is supported, an empty one means the default RSA 2048
+func IsValidCertKeyType(keyType string) bool {
+	_, ok := certKeyTypes[keyType]
+	return ok || keyType == ""
+}
+
+func isEccKeyType(keyType certcrypto.KeyType) bool {
+	return keyType == certcrypto.EC256 || keyType == certcrypto.EC384
+}
+
+func getCryptoAlgorithm(keyType certcrypto.KeyType) string {
+	if isEccKeyType(keyType) {
+		return "ECC"
+	}
+	return "RSA"
+}
+
+// getCertKeyTypes returns the key type of the cert of the site, which is RSA 2048 by default. With dual
+// certs enabled, the cert is RSA and the second key type is the ECDSA one, which is empty otherwise.
+func (site *Site) getCertKeyTypes() (certcrypto.KeyType, certcrypto.KeyType) {
+	keyType, ok := certKeyTypes[site.CertKeyType]
+	if !ok {
+		keyType = certcrypto.RSA2048
+	}
+	if !site.EnableDualCert {
+		return keyType, ""
+	}
+
+	if isEccKeyType(keyType) {
+		return certcrypto.RSA2048, keyType
+	}
+	return keyType, certcrypto.EC256
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"testing"
+
+	"github.com/casbin/lego/v4/certcrypto"
+)
+
+func TestGetCertKeyTypes(t *testing.T) {
+	tests := []struct {
+		certKeyType    string
+		enableDualCert bool
+		keyType        certcrypto.KeyType
+		eccKeyType     certcrypto.KeyType
+	}{
+		{"", false, certcrypto.RSA2048, ""},
+		{"RSA 4096", false, certcrypto.RSA4096, ""},
+		{"EC P-384", false, certcrypto.EC384, ""},
+		{"", true, certcrypto.RSA2048, certcrypto.EC256},
+		{"RSA 4096", true, certcrypto.RSA4096, certcrypto.EC256},
+		{"EC P-384", true, certcrypto.RSA2048, certcrypto.EC384},
+	}
+
+	for _, tt := range tests {
+		site := &Site{CertKeyType: tt.certKeyType, EnableDualCert: tt.enableDualCert}
+		keyType, eccKeyType := site.getCertKeyTypes()
+		if keyType != tt.keyType || eccKeyType != tt.eccKeyType {
+			t.Errorf("getCertKeyTypes(%q, %v) = (%q, %q), want (%q, %q)", tt.certKeyType, tt.enableDualCert, keyType, eccKeyType, tt.keyType, tt.eccKeyType)
+		}
+	}
+}
+
+func TestGetEccTlsCert(t *testing.T) {
+	t.Cleanup(clearTlsCertCache)
+
+	certificate, privateKey := newTestCertPem(t, "example.com")
+	cert := &Cert{Owner: "admin", Name: "example.com"}
+
+	tlsCert, err := GetEccTlsCert(cert)
+	if err != nil || tlsCert != nil {
+		t.Fatalf("GetEccTlsCert() = %v, %v, want nil for a cert without ECDSA certificate", tlsCert, err)
+	}
+
+	cert.EccCertificate = certificate
+	cert.EccPrivateKey = privateKey
+	tlsCert, err = GetEccTlsCert(cert)
+	if err != nil || tlsCert == nil {
+		t.Fatalf("GetEccTlsCert() = %v, %v", tlsCert, err)
+	}
+}
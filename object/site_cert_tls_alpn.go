@@ -0,0 +1,108 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"crypto/tls"
+	"strings"
+	"sync"
+
+	"github.com/casbin/lego/v4/challenge/tlsalpn01"
+)
+
+type tlsAlpnChallengeCert struct {
+	keyAuth string
+	cert    *tls.Certificate
+}
+
+// tlsAlpnChallengeCerts caches the certs answering the pending TLS-ALPN-01 challenges by domain
+var tlsAlpnChallengeCerts sync.Map
+
+// tlsAlpnKeyAuthMap is the key authorizations of the pending TLS-ALPN-01 challenges by domain, which
+// is refreshed with the site map, so the handshakes never read the DB
+var (
+	tlsAlpnKeyAuthMap  = map[string]string{}
+	tlsAlpnKeyAuthLock sync.RWMutex
+)
+
+// TlsAlpnProvider answers the TLS-ALPN-01 challenge by the HTTPS listener of the gateway, which
+// serves the challenge cert returned by GetTlsAlpnChallengeCert() for the acme-tls/1 protocol.
+// The key authorization is saved to the challenges of the site by domain instead of token, so that
+// the challenge is answered by any node of the site after its site map is refreshed
+type TlsAlpnProvider struct {
+	siteId string
+}
+
+func (p *TlsAlpnProvider) Present(domain string, token string, keyAuth string) error {
+	return (&HttpProvider{siteId: p.siteId}).Present(domain, strings.ToLower(domain), keyAuth)
+}
+
+func (p *TlsAlpnProvider) CleanUp(domain string, token string, keyAuth string) error {
+	tlsAlpnChallengeCerts.Delete(strings.ToLower(domain))
+	return (&HttpProvider{siteId: p.siteId}).CleanUp(domain, strings.ToLower(domain), keyAuth)
+}
+
+// refreshTlsAlpnKeyAuthMap replaces the key authorizations by the challenges of the TLS-ALPN-01 sites
+func refreshTlsAlpnKeyAuthMap(sites []*Site) {
+	newTlsAlpnKeyAuthMap := map[string]string{}
+	for _, site := range sites {
+		if !site.isTlsAlpnChallenge() {
+			continue
+		}
+
+		for domain, keyAuth := range site.GetChallengeMap() {
+			newTlsAlpnKeyAuthMap[domain] = keyAuth
+		}
+	}
+
+	tlsAlpnKeyAuthLock.Lock()
+	tlsAlpnKeyAuthMap = newTlsAlpnKeyAuthMap
+	tlsAlpnKeyAuthLock.Unlock()
+}
+
+// getTlsAlpnKeyAuth returns the key authorization of the pending TLS-ALPN-01 challenge of the domain,
+// or "" if there is none
+func getTlsAlpnKeyAuth(domain string) string {
+	tlsAlpnKeyAuthLock.RLock()
+	defer tlsAlpnKeyAuthLock.RUnlock()
+	return tlsAlpnKeyAuthMap[domain]
+}
+
+// GetTlsAlpnChallengeCert returns the cert of the pending TLS-ALPN-01 challenge of the domain, or nil if there is none
+func GetTlsAlpnChallengeCert(domain string) (*tls.Certificate, error) {
+	domain = strings.ToLower(domain)
+	keyAuth := getTlsAlpnKeyAuth(domain)
+	if keyAuth == "" {
+		return nil, nil
+	}
+
+	if v, ok := tlsAlpnChallengeCerts.Load(domain); ok {
+		if challengeCert := v.(*tlsAlpnChallengeCert); challengeCert.keyAuth == keyAuth {
+			return challengeCert.cert, nil
+		}
+	}
+
+	cert, err := tlsalpn01.ChallengeCert(domain, keyAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsAlpnChallengeCerts.Store(domain, &tlsAlpnChallengeCert{keyAuth: keyAuth, cert: cert})
+	return cert, nil
+}
+
+func (site *Site) isTlsAlpnChallenge() bool {
+	return site.CertChallenge == "TLS-ALPN-01"
+}
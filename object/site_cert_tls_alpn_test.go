@@ -0,0 +1,68 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"testing"
+
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+func TestTlsAlpnProvider(t *testing.T) {
+	InitMemoryAdapter()
+	// the sites are refreshed without the Casdoor applications
+	casdoorsdk.InitConfig("http://127.0.0.1:1", "", "", "", "", "")
+
+	site := &Site{Owner: "admin", Name: "tls-alpn", Domain: "example.com", OtherDomains: []string{"www.example.com"}, PublicIp: "127.0.0.1", CertChallenge: "TLS-ALPN-01"}
+	_, err := AddSite(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &TlsAlpnProvider{siteId: site.GetId()}
+	err = provider.Present("WWW.example.com", "token", "keyAuth")
+	if err != nil {
+		t.Fatalf("Present() error = %v", err)
+	}
+
+	cert, err := GetTlsAlpnChallengeCert("www.example.com")
+	if err != nil || cert == nil {
+		t.Fatalf("GetTlsAlpnChallengeCert() = %v, %v, want the challenge cert", cert, err)
+	}
+	if cached, _ := GetTlsAlpnChallengeCert("www.example.com"); cached != cert {
+		t.Errorf("GetTlsAlpnChallengeCert() should return the cached cert of the same key authorization")
+	}
+
+	// the key authorization of a new order replaces the cached cert
+	err = provider.Present("www.example.com", "token2", "keyAuth2")
+	if err != nil {
+		t.Fatalf("Present() error = %v", err)
+	}
+	if renewed, _ := GetTlsAlpnChallengeCert("www.example.com"); renewed == nil || renewed == cert {
+		t.Errorf("GetTlsAlpnChallengeCert() should return the cert of the new key authorization")
+	}
+
+	if other, _ := GetTlsAlpnChallengeCert("other.com"); other != nil {
+		t.Errorf("GetTlsAlpnChallengeCert() returns a cert for a domain of no site")
+	}
+
+	err = provider.CleanUp("www.example.com", "token2", "keyAuth2")
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if cert, err = GetTlsAlpnChallengeCert("www.example.com"); err != nil || cert != nil {
+		t.Errorf("GetTlsAlpnChallengeCert() = %v, %v after CleanUp(), want nil", cert, err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import "net/http"
+
+// IdentityJwtHeader carries the signed-in user as a JWT signed with the identity JWT secret of the site
+const IdentityJwtHeader = "X-Auth-Jwt"
+
+// IdentityHeaders are the headers forwarding the signed-in user to the upstream, which are set by the gateway
+var IdentityHeaders = []string{
+	"X-Auth-Id",
+	"X-Auth-User",
+	"X-Auth-Organization",
+	"X-Auth-Display-Name",
+	"X-Auth-Email",
+	"X-Auth-Groups",
+	"X-Auth-Roles",
+	IdentityJwtHeader,
+}
+
+func IsIdentityHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, header := range IdentityHeaders {
+		if header == name {
+			return true
+		}
+	}
+	return false
+}
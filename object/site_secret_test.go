@@ -0,0 +1,90 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/casbin/caswaf/notifier"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+func TestMaskSiteSecrets(t *testing.T) {
+	InitMemoryAdapter()
+	// the sites are refreshed without the Casdoor applications
+	casdoorsdk.InitConfig("http://127.0.0.1:1", "", "", "", "", "")
+
+	dingTalk := "DingTalk/https://oapi.dingtalk.com/robot/send?access_token=a"
+	feishu := "Feishu/https://open.feishu.cn/open-apis/bot/v2/hook/b"
+	providers := []string{dingTalk, feishu, "Webhook/c", "Email/provider_email"}
+	_, err := AddSite(&Site{Owner: "admin", Name: "site-secret", IdentityJwtSecret: "jwt-secret", AlertProviders: providers, AlertSecrets: map[string]string{dingTalk: "secret-a", feishu: "secret-b", "Webhook/c": ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site, err := GetSite("admin/site-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site = GetMaskedSite(site, "")
+	if site.IdentityJwtSecret != "***" {
+		t.Fatalf("IdentityJwtSecret = %s, want masked", site.IdentityJwtSecret)
+	}
+	maskedProviders := []string{notifier.MaskTarget(dingTalk), notifier.MaskTarget(feishu), notifier.MaskTarget("Webhook/c"), "Email/provider_email"}
+	if !reflect.DeepEqual(site.AlertProviders, maskedProviders) || strings.Contains(site.AlertProviders[0], "access_token") {
+		t.Fatalf("AlertProviders = %v, want the URLs masked", site.AlertProviders)
+	}
+	if want := map[string]string{maskedProviders[0]: "***", maskedProviders[1]: "***", maskedProviders[2]: ""}; !reflect.DeepEqual(site.AlertSecrets, want) {
+		t.Fatalf("AlertSecrets = %v, want %v", site.AlertSecrets, want)
+	}
+
+	// the masked site is saved back as it is got
+	_, err = UpdateSite(site.GetId(), site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site, err = GetSite("admin/site-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.IdentityJwtSecret != "jwt-secret" {
+		t.Errorf("IdentityJwtSecret = %s, want the stored secret kept", site.IdentityJwtSecret)
+	}
+	if !reflect.DeepEqual(site.AlertProviders, providers) {
+		t.Errorf("AlertProviders = %v, want the stored providers kept", site.AlertProviders)
+	}
+	if want := map[string]string{dingTalk: "secret-a", feishu: "secret-b", "Webhook/c": ""}; !reflect.DeepEqual(site.AlertSecrets, want) {
+		t.Errorf("AlertSecrets = %v, want the stored secrets kept", site.AlertSecrets)
+	}
+
+	site.IdentityJwtSecret = "new-secret"
+	site.AlertSecrets[feishu] = "new-secret-b"
+	_, err = UpdateSite(site.GetId(), site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site, err = GetSite("admin/site-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.IdentityJwtSecret != "new-secret" {
+		t.Errorf("IdentityJwtSecret = %s, want new-secret", site.IdentityJwtSecret)
+	}
+	if site.AlertSecrets[feishu] != "new-secret-b" {
+		t.Errorf("AlertSecrets = %v, want new-secret-b for %s", site.AlertSecrets, feishu)
+	}
+}
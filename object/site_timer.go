@@ -64,9 +64,8 @@ func monitorSiteCerts() error {
 		//	continue
 		//}
 
-		lock.Lock()
+		// the certs are issued by the cert jobs, so a slow ACME order does not block the loop
 		err = site.checkCerts()
-		lock.Unlock()
 		if err != nil {
 			return err
 		}
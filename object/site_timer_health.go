@@ -16,13 +16,19 @@ package object
 
 import (
 	"fmt"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/casbin/caswaf/notifier"
+	"github.com/casbin/caswaf/util"
 )
 
-var healthCheckTryTimesMap = map[string]int{}
+var (
+	healthCheckTryTimesMap = map[string]int{}
+
+	// healthCheckDownMap records the time when a domain is alerted as down, to alert again when it is up
+	healthCheckDownMap = &sync.Map{}
+)
 
 func healthCheck(site *Site, domain string) error {
 	var isHealth bool
@@ -43,7 +49,17 @@ func healthCheck(site *Site, domain string) error {
 
 	if isHealth {
 		healthCheckTryTimesMap[domain] = GetSiteByDomain(domain).AlertTryTimes
-		return nil
+		downTime, ok := healthCheckDownMap.LoadAndDelete(domain)
+		if !ok {
+			return nil
+		}
+
+		return SendSiteAlert(site, &notifier.Event{
+			Type:    notifier.EventHealthUp,
+			Subject: domain,
+			Message: fmt.Sprintf("the domain: %s has been down since %s", domain, downTime),
+			Data:    map[string]string{"downTime": downTime.(string)},
+		})
 	}
 
 	healthCheckTryTimesMap[domain]--
@@ -51,29 +67,12 @@ func healthCheck(site *Site, domain string) error {
 		return nil
 	}
 
-	pingResponse = fmt.Sprintf("CasWAF health check failed for domain %s, %s", domain, pingResponse)
-	user, err := casdoorsdk.GetUser(site.Owner)
-	if err != nil {
-		return err
-	}
-	if user == nil {
-		return fmt.Errorf("user not found")
-	}
-	for _, provider := range site.AlertProviders {
-		if strings.HasPrefix(provider, "Email/") {
-			err := casdoorsdk.SendEmailByProvider("CasWAF HealthCheck Check Alert", pingResponse, "CasWAF", provider[6:], user.Email)
-			if err != nil {
-				fmt.Println(err)
-			}
-		}
-		if strings.HasPrefix(provider, "SMS/") {
-			err := casdoorsdk.SendSmsByProvider(pingResponse, provider[4:], user.Phone)
-			if err != nil {
-				fmt.Println(err)
-			}
-		}
-	}
-	return nil
+	healthCheckDownMap.Store(domain, util.GetCurrentTime())
+	return SendSiteAlert(site, &notifier.Event{
+		Type:    notifier.EventHealthDown,
+		Subject: domain,
+		Message: pingResponse,
+	})
 }
 
 func startHealthCheckLoop() {
@@ -88,6 +87,7 @@ func startHealthCheckLoop() {
 				site := GetSiteByDomain(domain)
 				if shouldStopHealthCheck(site) {
 					delete(healthCheckTryTimesMap, domain)
+					healthCheckDownMap.Delete(domain)
 					return
 				}
 
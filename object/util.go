@@ -179,7 +179,7 @@ func GetCertByDomain(domain string) (*Cert, error) {
 	}
 
 	cert, ok := certMap[domain]
-	if ok {
+	if ok && cert.Type != "Client CA" {
 		return cert, nil
 	}
 
@@ -189,9 +189,18 @@ func GetCertByDomain(domain string) (*Cert, error) {
 	}
 
 	cert, ok = certMap[baseDomain]
-	if ok {
+	if ok && cert.Type != "Client CA" {
 		return cert, nil
 	}
 
 	return nil, nil
 }
+
+// GetClientCaCert returns the CA bundle to verify the client certificates of a site with mTLS
+func GetClientCaCert(name string) *Cert {
+	cert := GetCertByName(name)
+	if cert == nil || cert.Type != "Client CA" {
+		return nil
+	}
+	return cert
+}
@@ -58,6 +58,9 @@ func initAPI() {
 	beego.Router("/api/add-cert", &controllers.ApiController{}, "POST:AddCert")
 	beego.Router("/api/delete-cert", &controllers.ApiController{}, "POST:DeleteCert")
 	beego.Router("/api/update-cert-domain-expire", &controllers.ApiController{}, "POST:UpdateCertDomainExpire")
+	beego.Router("/api/get-dns-providers", &controllers.ApiController{}, "GET:GetDnsProviders")
+	beego.Router("/api/get-cert-jobs", &controllers.ApiController{}, "GET:GetCertJobs")
+	beego.Router("/api/renew-cert", &controllers.ApiController{}, "POST:RenewCert")
 
 	beego.Router("/api/get-applications", &controllers.ApiController{}, "GET:GetApplications")
 
@@ -75,4 +78,13 @@ func initAPI() {
 	beego.Router("/api/add-rule", &controllers.ApiController{}, "POST:AddRule")
 	beego.Router("/api/update-rule", &controllers.ApiController{}, "POST:UpdateRule")
 	beego.Router("/api/delete-rule", &controllers.ApiController{}, "POST:DeleteRule")
+	beego.Router("/api/test-rules", &controllers.ApiController{}, "POST:TestRules")
+	beego.Router("/api/add-crs-exclusion", &controllers.ApiController{}, "POST:AddCrsExclusion")
+	beego.Router("/api/get-crs-groups", &controllers.ApiController{}, "GET:GetCrsGroups")
+
+	beego.Router("/api/get-credentials", &controllers.ApiController{}, "GET:GetCredentials")
+	beego.Router("/api/get-credential", &controllers.ApiController{}, "GET:GetCredential")
+	beego.Router("/api/add-credential", &controllers.ApiController{}, "POST:AddCredential")
+	beego.Router("/api/update-credential", &controllers.ApiController{}, "POST:UpdateCredential")
+	beego.Router("/api/delete-credential", &controllers.ApiController{}, "POST:DeleteCredential")
 }
@@ -17,6 +17,7 @@ package rule
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/casbin/caswaf/object"
 	"github.com/casbin/caswaf/util"
@@ -30,83 +31,234 @@ type RuleResult struct {
 	Action     string
 	StatusCode int
 	Reason     string
+
+	// the clearance granted after passing the "CAPTCHA" or "JS Challenge" action,
+	// valid for ClearanceTtl seconds and bound to the client IP or the user agent
+	ClearanceTtl     int
+	ClearanceBinding string
+
+	Score      int
+	RuleScores []*object.RuleScore
+	WafMatches []*object.WafMatch
+	Tags       []string
+	HitRules   []string
+}
+
+// isTerminatingAction returns whether the evaluation stops at a rule hit with the action. The rules hit
+// with "Log", "Tag" or "Score" are recorded in the result, and the evaluation continues with the next rule.
+func isTerminatingAction(action string) bool {
+	return action != "Log" && action != "Tag" && action != "Score"
 }
 
 func CheckRules(ruleIds []string, r *http.Request) (*RuleResult, error) {
-	rules, err := object.GetRulesByRuleIds(ruleIds)
+	return checkRules(ruleIds, nil, r, nil)
+}
+
+// CheckSiteRules evaluates the rules of the site, a request that is not decided by a terminating
+// rule is challenged or blocked when its anomaly score reaches the score thresholds of the site
+func CheckSiteRules(site *object.Site, r *http.Request) (*RuleResult, error) {
+	return checkRules(site.Rules, site, r, nil)
+}
+
+func checkRules(ruleIds []string, site *object.Site, r *http.Request, trace *RulesTrace) (*RuleResult, error) {
+	rules, err := object.GetSiteRulesByRuleIds(ruleIds)
 	if err != nil {
 		return nil, err
 	}
-	for i, rule := range rules {
-		var ruleObj Rule
-		switch rule.Type {
-		case "User-Agent":
-			ruleObj = &UaRule{}
-		case "IP":
-			ruleObj = &IpRule{}
-		case "WAF":
-			ruleObj = &WafRule{}
-		case "IP Rate Limiting":
-			ruleObj = &IpRateRule{
-				ruleName: rule.GetId(),
-			}
-		case "Compound":
-			ruleObj = &CompoundRule{}
-		default:
-			return nil, fmt.Errorf("unknown rule type: %s for rule: %s", rule.Type, rule.GetId())
+
+	res := &RuleResult{
+		RuleScores: []*object.RuleScore{},
+		WafMatches: []*object.WafMatch{},
+		Tags:       []string{},
+		HitRules:   []string{},
+	}
+	for _, rule := range rules {
+		var ruleTrace *RuleTrace
+		if trace != nil {
+			ruleTrace = newRuleTrace(rule)
+			trace.Rules = append(trace.Rules, ruleTrace)
 		}
 
-		result, err := ruleObj.checkRule(rule.Expressions, r)
+		ruleObj, err := newRuleObj(rule, ruleTrace)
 		if err != nil {
 			return nil, err
 		}
-		
-		if result != nil {
-			// Use rule's action if no action specified by the rule check
-			if result.Action == "" {
-				result.Action = rule.Action
+
+		var result *RuleResult
+		if ruleTrace != nil {
+			result, err = traceRule(ruleObj, rule, r, ruleTrace)
+			if err != nil {
+				ruleTrace.Error = err.Error()
+				return nil, err
 			}
-			
-			// Determine status code
-			if result.StatusCode == 0 {
-				if rule.StatusCode != 0 {
-					result.StatusCode = rule.StatusCode
-				} else {
-					// Set default status codes if not specified
-					switch result.Action {
-					case "Block":
-						result.StatusCode = 403
-					case "Drop":
-						result.StatusCode = 400
-					case "Allow":
-						result.StatusCode = 200
-					case "CAPTCHA":
-						result.StatusCode = 302
-					default:
-						return nil, fmt.Errorf("unknown rule action: %s for rule: %s", result.Action, rule.GetId())
-					}
-				}
+		} else if expressions, ok := getRuleExpressions(rule); ok {
+			startTime := time.Now()
+			result, err = ruleObj.checkRule(expressions, r)
+			if err != nil {
+				return nil, err
 			}
-			
-			// Update reason if rule has custom reason
-			if result.Action == "Block" || result.Action == "Drop" {
-				if rule.IsVerbose {
-					// Add verbose debug info with rule name and triggered expression
-					result.Reason = util.GenerateVerboseReason(rule.GetId(), result.Reason, rule.Reason)
-				} else if rule.Reason != "" {
-					result.Reason = rule.Reason
-				} else if result.Reason != "" {
-					result.Reason = fmt.Sprintf("hit rule %s: %s", ruleIds[i], result.Reason)
+			recordRuleStats(rule.GetId(), time.Since(startTime), result != nil)
+		}
+
+		if result == nil {
+			continue
+		}
+
+		// Use rule's action if no action specified by the rule check, or if the rule only logs, tags or scores
+		if result.Action == "" || !isTerminatingAction(rule.Action) {
+			result.Action = rule.Action
+		}
+
+		if ruleTrace != nil {
+			ruleTrace.IsHit = true
+			ruleTrace.Result = result
+		}
+		res.WafMatches = append(res.WafMatches, result.WafMatches...)
+
+		if !isTerminatingAction(result.Action) {
+			addNonTerminatingResult(res, rule, result)
+			continue
+		}
+
+		// Determine status code
+		if result.StatusCode == 0 {
+			if rule.StatusCode != 0 {
+				result.StatusCode = rule.StatusCode
+			} else {
+				// Set default status codes if not specified
+				switch result.Action {
+				case "Block":
+					result.StatusCode = 403
+				case "Drop":
+					result.StatusCode = 400
+				case "Allow":
+					result.StatusCode = 200
+				case "CAPTCHA":
+					result.StatusCode = 302
+				case "JS Challenge":
+					result.StatusCode = 403
+				default:
+					return nil, fmt.Errorf("unknown rule action: %s for rule: %s", result.Action, rule.GetId())
 				}
 			}
-			
-			return result, nil
 		}
+
+		// Update reason if rule has custom reason
+		if result.Action == "Block" || result.Action == "Drop" {
+			if rule.IsVerbose {
+				// Add verbose debug info with rule name and triggered expression
+				result.Reason = util.GenerateVerboseReason(rule.GetId(), result.Reason, rule.Reason)
+			} else if rule.Reason != "" {
+				result.Reason = rule.Reason
+			} else if result.Reason != "" {
+				result.Reason = fmt.Sprintf("hit rule %s: %s", rule.GetId(), result.Reason)
+			}
+		}
+
+		res.Action = result.Action
+		res.StatusCode = result.StatusCode
+		res.Reason = result.Reason
+		res.ClearanceTtl = rule.ClearanceTtl
+		res.ClearanceBinding = rule.ClearanceBinding
+		res.HitRules = append(res.HitRules, rule.GetId())
+		return res, nil
+	}
+
+	// Default action if no terminating rule matched
+	res.Action = "Allow"
+	res.StatusCode = 200
+	applyScoreThresholds(res, site)
+	return res, nil
+}
+
+func applyScoreThresholds(res *RuleResult, site *object.Site) {
+	if site == nil || res.Score == 0 {
+		return
+	}
+
+	if site.BlockScore > 0 && res.Score >= site.BlockScore {
+		res.Action = "Block"
+		res.StatusCode = 403
+		res.Reason = fmt.Sprintf("anomaly score %d reached the block threshold %d", res.Score, site.BlockScore)
+	} else if site.CaptchaScore > 0 && res.Score >= site.CaptchaScore {
+		res.Action = "CAPTCHA"
+		res.StatusCode = 302
+		res.Reason = fmt.Sprintf("anomaly score %d reached the CAPTCHA threshold %d", res.Score, site.CaptchaScore)
+	}
+}
+
+func addNonTerminatingResult(res *RuleResult, rule *object.Rule, result *RuleResult) {
+	res.HitRules = append(res.HitRules, rule.GetId())
+
+	switch result.Action {
+	case "Log":
+		fmt.Printf("[%s] rule hit: %s, reason: %s\n", util.GetCurrentTime(), rule.GetId(), result.Reason)
+	case "Tag":
+		tag := rule.Reason
+		if tag == "" {
+			tag = rule.Name
+		}
+		res.Tags = append(res.Tags, tag)
+	case "Score":
+		// WAF rules contribute the anomaly score of the transaction, other rules contribute their own score
+		score := result.Score
+		if score == 0 {
+			score = rule.Score
+		}
+		res.Score += score
+		res.RuleScores = append(res.RuleScores, &object.RuleScore{Rule: rule.GetId(), Score: score})
+	}
+}
+
+// getRuleExpressions returns the expressions of the rule to evaluate, the invalid expressions found when
+// the rule is loaded are skipped instead of failing every request, and a rule whose expressions are
+// evaluated as a whole is not evaluated if any of them is invalid
+func getRuleExpressions(rule *object.Rule) ([]*object.Expression, bool) {
+	expressions := rule.GetValidExpressions()
+	if len(expressions) != len(rule.Expressions) && object.IsWholeRuleType(rule.Type) {
+		return nil, false
+	}
+	return expressions, true
+}
+
+// newRuleObj creates the checker of the rule, a non-nil ruleTrace means the rule is evaluated in
+// trace mode, where matched WAF rules and nested rules are recorded and rate limiting is a dry run
+func newRuleObj(rule *object.Rule, ruleTrace *RuleTrace) (Rule, error) {
+	switch rule.Type {
+	case "User-Agent":
+		return &UaRule{}, nil
+	case "IP":
+		return &IpRule{}, nil
+	case "WAF":
+		return &WafRule{
+			isScore: rule.Action == "Score",
+			trace:   ruleTrace,
+		}, nil
+	case "IP Rate Limiting":
+		return &IpRateRule{
+			ruleName: rule.GetId(),
+			dryRun:   ruleTrace != nil,
+		}, nil
+	case "Compound":
+		return &CompoundRule{
+			ruleName: rule.GetId(),
+			revision: rule.GetRevision(),
+			trace:    ruleTrace,
+		}, nil
+	case "CRS":
+		return &CrsRule{
+			ruleName: rule.GetId(),
+			revision: rule.GetRevision(),
+			isScore:  rule.Action == "Score",
+			trace:    ruleTrace,
+		}, nil
+	case "Header", "Cookie", "Query", "Method", "Path", "Host", "Content-Type", "Client Cert":
+		return &RequestRule{
+			ruleType: rule.Type,
+		}, nil
+	case "Content-Length":
+		return &ContentLengthRule{}, nil
+	default:
+		return nil, fmt.Errorf("unknown rule type: %s for rule: %s", rule.Type, rule.GetId())
 	}
-	
-	// Default action if no rule matched
-	return &RuleResult{
-		Action:     "Allow",
-		StatusCode: 200,
-	}, nil
 }
@@ -17,44 +17,256 @@ package rule
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/casbin/caswaf/object"
-	"github.com/casbin/caswaf/util"
 )
 
-type CompoundRule struct{}
+type CompoundRule struct {
+	ruleName string
+	revision int64
+	trace    *RuleTrace
+}
 
-func (r *CompoundRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
-	operators := util.NewStack()
-	res := true
-	for _, expression := range expressions {
-		isHit := true
-		result, err := CheckRules([]string{expression.Value}, req)
-		if err != nil {
-			return nil, err
+type compiledCompoundRule struct {
+	revision int64
+	root     exprNode
+}
+
+var (
+	compiledCompoundRules     = map[string]*compiledCompoundRule{}
+	compiledCompoundRulesLock = &sync.RWMutex{}
+)
+
+func init() {
+	object.AddRuleRefreshHandler(refreshCompiledCompoundRules)
+}
+
+// refreshCompiledCompoundRules drops the compiled expressions of the edited and deleted rules
+func refreshCompiledCompoundRules(rules map[string]*object.Rule) {
+	compiledCompoundRulesLock.Lock()
+	defer compiledCompoundRulesLock.Unlock()
+	for ruleName, compiled := range compiledCompoundRules {
+		if rule, ok := rules[ruleName]; !ok || rule.GetRevision() != compiled.revision {
+			delete(compiledCompoundRules, ruleName)
 		}
-		if result == nil || result.Action == "" {
-			isHit = false
+	}
+}
+
+func (r *CompoundRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
+	root, err := getCompiledCompoundRule(r.ruleName, r.revision, expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &exprEnv{
+		req:      req,
+		visiting: map[string]bool{r.ruleName: true},
+		trace:    r.trace,
+	}
+	isHit, err := root.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if isHit {
+		return &RuleResult{}, nil
+	}
+	return nil, nil
+}
+
+// getCompoundExpressionText returns the expression of a compound rule. A rule holds either a single
+// expression with the "expression" operator, or a legacy chain of rule IDs with "begin", "and" and "or"
+// operators, which is evaluated from left to right and translated as ((rule1 && rule2) || rule3) ...
+func getCompoundExpressionText(expressions []*object.Expression) (string, error) {
+	if len(expressions) == 0 {
+		return "", fmt.Errorf("compound rule should have at least one expression")
+	}
+
+	if len(expressions) == 1 && expressions[0].Operator == "expression" {
+		return expressions[0].Value, nil
+	}
+
+	res := ""
+	for i, expression := range expressions {
+		ruleRef := fmt.Sprintf("rule(%s)", strconv.Quote(expression.Value))
+		if i == 0 {
+			switch expression.Operator {
+			case "begin", "and":
+				res = ruleRef
+			case "or":
+				// the legacy evaluation starts from true, so a leading "or" always hits
+				res = fmt.Sprintf("true || %s", ruleRef)
+			default:
+				return "", fmt.Errorf("unknown operator: %s for the first expression", expression.Operator)
+			}
+			continue
 		}
+
 		switch expression.Operator {
 		case "and", "begin":
-			res = res && isHit
+			res = fmt.Sprintf("(%s) && %s", res, ruleRef)
 		case "or":
-			operators.Push(res)
-			res = isHit
+			res = fmt.Sprintf("(%s) || %s", res, ruleRef)
+		case "expression":
+			return "", fmt.Errorf("the \"expression\" operator should be used in the only expression of the rule")
 		default:
-			return nil, fmt.Errorf("unknown operator: %s", expression.Operator)
+			return "", fmt.Errorf("unknown operator: %s", expression.Operator)
 		}
-		if operators.Size() > 0 {
-			last, ok := operators.Pop()
-			for ok {
-				res = last.(bool) || res
-				last, ok = operators.Pop()
-			}
+	}
+	return res, nil
+}
+
+func compileCompoundRule(expressions []*object.Expression) (exprNode, []string, error) {
+	text, err := getCompoundExpressionText(expressions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseCompoundExpression(text)
+}
+
+func getCompiledCompoundRule(ruleName string, revision int64, expressions []*object.Expression) (exprNode, error) {
+	compiledCompoundRulesLock.RLock()
+	compiled, ok := compiledCompoundRules[ruleName]
+	compiledCompoundRulesLock.RUnlock()
+	if ok && ruleName != "" && compiled.revision == revision {
+		return compiled.root, nil
+	}
+
+	root, _, err := compileCompoundRule(expressions)
+	if err != nil {
+		return nil, fmt.Errorf("compound rule: %s, %v", ruleName, err)
+	}
+
+	if ruleName != "" {
+		compiledCompoundRulesLock.Lock()
+		compiledCompoundRules[ruleName] = &compiledCompoundRule{revision: revision, root: root}
+		compiledCompoundRulesLock.Unlock()
+	}
+	return root, nil
+}
+
+// isRuleHit evaluates a rule referenced by a compound rule, nested compound rules are evaluated
+// in the same environment so that reference cycles are detected instead of recursing forever
+func isRuleHit(ruleId string, env *exprEnv) (bool, error) {
+	rules, err := object.GetRulesByRuleIds([]string{ruleId})
+	if err != nil {
+		return false, err
+	}
+	rule := rules[0]
+
+	var ruleTrace *RuleTrace
+	if env.trace != nil {
+		ruleTrace = newRuleTrace(rule)
+		env.trace.NestedRules = append(env.trace.NestedRules, ruleTrace)
+	}
+
+	startTime := time.Now()
+	isHit, err := evalReferencedRule(rule, env, ruleTrace)
+	if ruleTrace == nil && err == nil {
+		recordRuleStats(rule.GetId(), time.Since(startTime), isHit)
+	}
+	if ruleTrace != nil {
+		ruleTrace.IsHit = isHit
+		if err != nil {
+			ruleTrace.Error = err.Error()
 		}
 	}
-	if res {
-		return &RuleResult{}, nil
+	return isHit, err
+}
+
+func evalReferencedRule(rule *object.Rule, env *exprEnv, ruleTrace *RuleTrace) (bool, error) {
+	ruleId := rule.GetId()
+	if rule.Type != "Compound" {
+		ruleObj, err := newRuleObj(rule, ruleTrace)
+		if err != nil {
+			return false, err
+		}
+
+		var result *RuleResult
+		if ruleTrace != nil {
+			result, err = traceRule(ruleObj, rule, env.req, ruleTrace)
+		} else if expressions, ok := getRuleExpressions(rule); ok {
+			result, err = ruleObj.checkRule(expressions, env.req)
+		}
+		if err != nil {
+			return false, err
+		}
+		return result != nil, nil
 	}
-	return nil, nil
+
+	if env.visiting[ruleId] {
+		return false, fmt.Errorf("compound rule reference cycle detected at rule: %s", ruleId)
+	}
+	env.visiting[ruleId] = true
+	defer delete(env.visiting, ruleId)
+
+	if _, ok := getRuleExpressions(rule); !ok {
+		return false, nil
+	}
+
+	root, err := getCompiledCompoundRule(ruleId, rule.GetRevision(), rule.Expressions)
+	if err != nil {
+		return false, err
+	}
+
+	parentTrace := env.trace
+	if ruleTrace != nil {
+		env.trace = ruleTrace
+		defer func() { env.trace = parentTrace }()
+	}
+	isHit, err := root.eval(env)
+	if ruleTrace != nil {
+		for _, expression := range rule.Expressions {
+			expressionTrace := newExpressionTrace(expression)
+			expressionTrace.IsHit = isHit
+			ruleTrace.Expressions = append(ruleTrace.Expressions, expressionTrace)
+		}
+	}
+	return isHit, err
+}
+
+// CheckCompoundRule compiles and type checks the expressions of the compound rule ruleId,
+// and makes sure that all referenced rules exist and that no reference cycle is formed
+func CheckCompoundRule(ruleId string, expressions []*object.Expression) error {
+	_, refs, err := compileCompoundRule(expressions)
+	if err != nil {
+		return err
+	}
+
+	return checkCompoundRuleCycle(ruleId, refs, []string{ruleId}, map[string]bool{})
+}
+
+func checkCompoundRuleCycle(ruleId string, refs []string, path []string, checked map[string]bool) error {
+	for _, ref := range refs {
+		refPath := append(append([]string{}, path...), ref)
+		if ref == ruleId {
+			return fmt.Errorf("compound rule reference cycle detected: %s", strings.Join(refPath, " -> "))
+		}
+		if checked[ref] {
+			continue
+		}
+		checked[ref] = true
+
+		rules, err := object.GetRulesByRuleIds([]string{ref})
+		if err != nil {
+			return err
+		}
+		rule := rules[0]
+		if rule.Type != "Compound" {
+			continue
+		}
+
+		_, nestedRefs, err := compileCompoundRule(rule.Expressions)
+		if err != nil {
+			return fmt.Errorf("compound rule: %s, %v", ref, err)
+		}
+		err = checkCompoundRuleCycle(ruleId, nestedRefs, refPath, checked)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
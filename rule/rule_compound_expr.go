@@ -0,0 +1,609 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+// This file implements the boolean expression language of compound rules, e.g.,
+//
+//	rule("admin/rule-bot") && !(path startsWith "/public/" || ip in ["10.0.0.0/8"])
+//	method in ["POST", "PUT"] and header("Content-Type") contains "xml"
+//	exists(cookie("session")) or content_length > 1048576
+//
+// Operands are rule references, attribute predicates, exists() checks and true/false,
+// combined with "&&"/"and", "||"/"or", "!"/"not" and parentheses.
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/casbin/caswaf/util"
+)
+
+const (
+	attributeTypeString = "string"
+	attributeTypeNumber = "number"
+	attributeTypeIp     = "ip"
+)
+
+// exprAttributes maps the attributes usable in predicates to their types
+var exprAttributes = map[string]string{
+	"method":         attributeTypeString,
+	"path":           attributeTypeString,
+	"host":           attributeTypeString,
+	"user_agent":     attributeTypeString,
+	"content_type":   attributeTypeString,
+	"header":         attributeTypeString,
+	"cookie":         attributeTypeString,
+	"query":          attributeTypeString,
+	"client_cert":    attributeTypeString,
+	"ip":             attributeTypeIp,
+	"content_length": attributeTypeNumber,
+}
+
+// exprRequestAttributes maps attributes to the request rule types reading them,
+// "header", "cookie", "query" and "client_cert" take a name argument, e.g., header("X-Api-Key")
+var exprRequestAttributes = map[string]string{
+	"method":       "Method",
+	"path":         "Path",
+	"host":         "Host",
+	"content_type": "Content-Type",
+	"header":       "Header",
+	"cookie":       "Cookie",
+	"query":        "Query",
+	"client_cert":  "Client Cert",
+}
+
+func isKeyedAttribute(name string) bool {
+	return name == "header" || name == "cookie" || name == "query" || name == "client_cert"
+}
+
+var exprOperators = map[string][]string{
+	attributeTypeString: {"==", "!=", "contains", "startsWith", "endsWith", "matches", "in"},
+	attributeTypeNumber: {"==", "!=", "<", "<=", ">", ">="},
+	attributeTypeIp:     {"==", "!=", "in"},
+}
+
+type exprEnv struct {
+	req      *http.Request
+	visiting map[string]bool
+	trace    *RuleTrace
+}
+
+type exprNode interface {
+	eval(env *exprEnv) (bool, error)
+}
+
+type boolNode struct {
+	value bool
+}
+
+type notNode struct {
+	expr exprNode
+}
+
+type andNode struct {
+	left  exprNode
+	right exprNode
+}
+
+type orNode struct {
+	left  exprNode
+	right exprNode
+}
+
+type ruleRefNode struct {
+	ruleId string
+}
+
+type attributeNode struct {
+	name string
+	arg  string
+}
+
+type existsNode struct {
+	attribute *attributeNode
+}
+
+type predicateNode struct {
+	attribute *attributeNode
+	operator  string
+	str       string
+	num       int64
+	list      []string
+	ipNets    []*net.IPNet
+	re        *regexp.Regexp
+}
+
+func (n *boolNode) eval(env *exprEnv) (bool, error) {
+	return n.value, nil
+}
+
+func (n *notNode) eval(env *exprEnv) (bool, error) {
+	res, err := n.expr.eval(env)
+	return !res, err
+}
+
+func (n *andNode) eval(env *exprEnv) (bool, error) {
+	res, err := n.left.eval(env)
+	if err != nil || !res {
+		return false, err
+	}
+	return n.right.eval(env)
+}
+
+func (n *orNode) eval(env *exprEnv) (bool, error) {
+	res, err := n.left.eval(env)
+	if err != nil || res {
+		return res, err
+	}
+	return n.right.eval(env)
+}
+
+func (n *ruleRefNode) eval(env *exprEnv) (bool, error) {
+	return isRuleHit(n.ruleId, env)
+}
+
+func (n *attributeNode) getString(req *http.Request) (string, bool) {
+	switch n.name {
+	case "user_agent":
+		return req.UserAgent(), req.UserAgent() != ""
+	case "ip":
+		return util.GetClientIp(req), true
+	default:
+		return getRequestAttribute(exprRequestAttributes[n.name], n.arg, req)
+	}
+}
+
+func (n *existsNode) eval(env *exprEnv) (bool, error) {
+	if n.attribute.name == "content_length" {
+		return env.req.ContentLength >= 0, nil
+	}
+	_, exists := n.attribute.getString(env.req)
+	return exists, nil
+}
+
+func (n *predicateNode) eval(env *exprEnv) (bool, error) {
+	if exprAttributes[n.attribute.name] == attributeTypeNumber {
+		contentLength := env.req.ContentLength
+		if contentLength < 0 {
+			return false, nil
+		}
+
+		switch n.operator {
+		case "==":
+			return contentLength == n.num, nil
+		case "!=":
+			return contentLength != n.num, nil
+		case "<":
+			return contentLength < n.num, nil
+		case "<=":
+			return contentLength <= n.num, nil
+		case ">":
+			return contentLength > n.num, nil
+		case ">=":
+			return contentLength >= n.num, nil
+		}
+		return false, fmt.Errorf("unknown operator: %s", n.operator)
+	}
+
+	value, _ := n.attribute.getString(env.req)
+	switch n.operator {
+	case "==":
+		return value == n.str, nil
+	case "!=":
+		return value != n.str, nil
+	case "contains":
+		return strings.Contains(value, n.str), nil
+	case "startsWith":
+		return strings.HasPrefix(value, n.str), nil
+	case "endsWith":
+		return strings.HasSuffix(value, n.str), nil
+	case "matches":
+		return n.re.MatchString(value), nil
+	case "in":
+		if n.ipNets != nil {
+			ip := net.ParseIP(value)
+			for _, ipNet := range n.ipNets {
+				if ip != nil && ipNet.Contains(ip) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return containsString(n.list, value), nil
+	}
+	return false, fmt.Errorf("unknown operator: %s", n.operator)
+}
+
+type exprToken struct {
+	kind  string // "ident", "string", "number", "op" or "eof"
+	value string
+	pos   int
+}
+
+func tokenizeExpr(text string) ([]*exprToken, error) {
+	tokens := []*exprToken{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, &exprToken{kind: "ident", value: string(runes[start:i]), pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, &exprToken{kind: "number", value: string(runes[start:i]), pos: start})
+		case c == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			value, err := strconv.Unquote(string(runes[start:i]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %v", start, err)
+			}
+			tokens = append(tokens, &exprToken{kind: "string", value: value, pos: start})
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch string(runes[i : i+2]) {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					op = string(runes[i : i+2])
+				}
+			}
+			if op == "" && strings.ContainsRune("()[],!<>", c) {
+				op = string(c)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, &exprToken{kind: "op", value: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	tokens = append(tokens, &exprToken{kind: "eof", pos: len(runes)})
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []*exprToken
+	pos    int
+	refs   []string
+}
+
+// parseCompoundExpression parses and type checks a compound rule expression,
+// it returns the syntax tree and the IDs of the referenced rules
+func parseCompoundExpression(text string) (exprNode, []string, error) {
+	tokens, err := tokenizeExpr(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, nil, p.errorf("unexpected %s", p.describe(p.peek()))
+	}
+	return node, p.refs, nil
+}
+
+func (p *exprParser) peek() *exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() *exprToken {
+	token := p.tokens[p.pos]
+	if token.kind != "eof" {
+		p.pos++
+	}
+	return token
+}
+
+func (p *exprParser) isOp(values ...string) bool {
+	token := p.peek()
+	if token.kind != "op" && token.kind != "ident" {
+		return false
+	}
+	return containsString(values, token.value)
+}
+
+func (p *exprParser) expect(kind string, value string) (*exprToken, error) {
+	token := p.next()
+	if token.kind != kind || (value != "" && token.value != value) {
+		expected := kind
+		if value != "" {
+			expected = fmt.Sprintf("%q", value)
+		}
+		return nil, fmt.Errorf("expected %s but got %s at position %d", expected, p.describe(token), token.pos)
+	}
+	return token, nil
+}
+
+func (p *exprParser) describe(token *exprToken) string {
+	if token.kind == "eof" {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", token.value)
+}
+
+func (p *exprParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, a...), p.peek().pos)
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||", "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&", "and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.isOp("!", "not") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.peek()
+	if token.kind == "op" && token.value == "(" {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect("op", ")")
+		return expr, err
+	}
+
+	if token.kind != "ident" {
+		return nil, p.errorf("unexpected %s", p.describe(token))
+	}
+
+	switch token.value {
+	case "true", "false":
+		p.next()
+		return &boolNode{value: token.value == "true"}, nil
+	case "rule":
+		p.next()
+		ruleId, err := p.parseCallArgument()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(ruleId, "/") {
+			return nil, fmt.Errorf("rule reference should be in the form of \"owner/name\": %s", ruleId)
+		}
+		p.refs = append(p.refs, ruleId)
+		return &ruleRefNode{ruleId: ruleId}, nil
+	case "exists":
+		p.next()
+		_, err := p.expect("op", "(")
+		if err != nil {
+			return nil, err
+		}
+		attribute, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect("op", ")")
+		if err != nil {
+			return nil, err
+		}
+		return &existsNode{attribute: attribute}, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *exprParser) parseCallArgument() (string, error) {
+	_, err := p.expect("op", "(")
+	if err != nil {
+		return "", err
+	}
+	arg, err := p.expect("string", "")
+	if err != nil {
+		return "", err
+	}
+	_, err = p.expect("op", ")")
+	if err != nil {
+		return "", err
+	}
+	return arg.value, nil
+}
+
+func (p *exprParser) parseAttribute() (*attributeNode, error) {
+	token, err := p.expect("ident", "")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := exprAttributes[token.value]; !ok {
+		return nil, fmt.Errorf("unknown attribute: %s at position %d", token.value, token.pos)
+	}
+
+	attribute := &attributeNode{name: token.value}
+	if isKeyedAttribute(token.value) {
+		attribute.arg, err = p.parseCallArgument()
+		if err != nil {
+			return nil, err
+		}
+		if attribute.arg == "" {
+			return nil, fmt.Errorf("the name of %s should not be empty at position %d", token.value, token.pos)
+		}
+	}
+	return attribute, nil
+}
+
+func (p *exprParser) parsePredicate() (exprNode, error) {
+	attribute, err := p.parseAttribute()
+	if err != nil {
+		return nil, err
+	}
+
+	attributeType := exprAttributes[attribute.name]
+	opToken := p.next()
+	if (opToken.kind != "op" && opToken.kind != "ident") || !containsString(exprOperators[attributeType], opToken.value) {
+		return nil, fmt.Errorf("operator %s is not supported for %s attribute %s at position %d", p.describe(opToken), attributeType, attribute.name, opToken.pos)
+	}
+
+	node := &predicateNode{attribute: attribute, operator: opToken.value}
+	if node.operator == "in" {
+		node.list, err = p.parseStringList()
+		if err != nil {
+			return nil, err
+		}
+		if attributeType == attributeTypeIp {
+			node.ipNets, err = parseIpNets(node.list)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	}
+
+	if attributeType == attributeTypeNumber {
+		token, err := p.expect("number", "")
+		if err != nil {
+			return nil, err
+		}
+		node.num, err = strconv.ParseInt(token.value, 10, 64)
+		return node, err
+	}
+
+	token, err := p.expect("string", "")
+	if err != nil {
+		return nil, err
+	}
+	node.str = token.value
+	if node.operator == "matches" {
+		node.re, err = regexp.Compile(node.str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %s, %v", node.str, err)
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseStringList() ([]string, error) {
+	_, err := p.expect("op", "[")
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for !p.isOp("]") {
+		if len(res) != 0 {
+			_, err = p.expect("op", ",")
+			if err != nil {
+				return nil, err
+			}
+		}
+		token, err := p.expect("string", "")
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, token.value)
+	}
+	p.next()
+	return res, nil
+}
+
+func parseIpNets(ips []string) ([]*net.IPNet, error) {
+	res := []*net.IPNet{}
+	for _, ipStr := range ips {
+		if !strings.Contains(ipStr, "/") {
+			ip := net.ParseIP(ipStr)
+			if ip == nil {
+				return nil, fmt.Errorf("unknown IP or CIDR format: %s", ipStr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			res = append(res, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(ipStr)
+		if err != nil {
+			return nil, fmt.Errorf("unknown IP or CIDR format: %s", ipStr)
+		}
+		res = append(res, ipNet)
+	}
+	return res, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
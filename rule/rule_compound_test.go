@@ -0,0 +1,236 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestParseCompoundExpression(t *testing.T) {
+	tests := []struct {
+		text     string
+		want     bool
+		wantRefs []string
+		wantErr  bool
+	}{
+		{text: `method == "POST" && path startsWith "/api/"`, want: true},
+		{text: `method in ["GET", "HEAD"] or query("q") matches "^sel"`, want: true},
+		{text: `!(host == "example.com")`, want: false},
+		{text: `not exists(header("X-Missing")) and exists(cookie("session"))`, want: true},
+		{text: `content_length >= 5 && content_length < 10`, want: true},
+		{text: `header("content-type") contains "json" && ip in ["192.0.2.0/24", "10.0.0.1"]`, want: true},
+		{text: `ip == "10.0.0.1" || false`, want: false},
+		{text: `true && (false || user_agent endsWith "")`, want: true},
+		{text: `rule("admin/rule1") && path == "/"`, wantRefs: []string{"admin/rule1"}},
+		{text: `content_length contains "1"`, wantErr: true},
+		{text: `method > 1`, wantErr: true},
+		{text: `content_length > "1"`, wantErr: true},
+		{text: `unknown == "1"`, wantErr: true},
+		{text: `header == "1"`, wantErr: true},
+		{text: `path matches "("`, wantErr: true},
+		{text: `ip in ["not-an-ip"]`, wantErr: true},
+		{text: `rule("rule1")`, wantErr: true},
+		{text: `(method == "GET"`, wantErr: true},
+		{text: `method == "GET" path == "/"`, wantErr: true},
+		{text: `method == "GET`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			root, refs, err := parseCompoundExpression(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCompoundExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantRefs != nil {
+				if !reflect.DeepEqual(refs, tt.wantRefs) {
+					t.Errorf("parseCompoundExpression() refs = %v, want %v", refs, tt.wantRefs)
+				}
+				return
+			}
+
+			req := newTestRequest()
+			req.RemoteAddr = "192.0.2.10:1234"
+			got, err := root.eval(&exprEnv{req: req, visiting: map[string]bool{}})
+			if err != nil {
+				t.Fatalf("eval() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("eval() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCompoundExpressionText(t *testing.T) {
+	tests := []struct {
+		name        string
+		expressions []*object.Expression
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "legacy chain",
+			expressions: []*object.Expression{
+				{Operator: "begin", Value: "admin/rule1"},
+				{Operator: "and", Value: "admin/rule2"},
+				{Operator: "or", Value: "admin/rule3"},
+			},
+			want: `((rule("admin/rule1")) && rule("admin/rule2")) || rule("admin/rule3")`,
+		},
+		{
+			name: "legacy chain beginning with or",
+			expressions: []*object.Expression{
+				{Operator: "or", Value: "admin/rule1"},
+				{Operator: "and", Value: "admin/rule2"},
+			},
+			want: `(true || rule("admin/rule1")) && rule("admin/rule2")`,
+		},
+		{
+			name:        "expression",
+			expressions: []*object.Expression{{Operator: "expression", Value: `path == "/"`}},
+			want:        `path == "/"`,
+		},
+		{
+			name: "expression mixed with chain",
+			expressions: []*object.Expression{
+				{Operator: "begin", Value: "admin/rule1"},
+				{Operator: "expression", Value: `path == "/"`},
+			},
+			wantErr: true,
+		},
+		{
+			name:        "empty",
+			expressions: []*object.Expression{},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCompoundExpressionText(tt.expressions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCompoundExpressionText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCompoundExpressionText() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func addTestRule(t *testing.T, name string, ruleType string, expressions ...*object.Expression) *object.Rule {
+	rule := &object.Rule{Owner: "admin", Name: name, Type: ruleType, Action: "Block", Expressions: expressions}
+	_, err := object.AddRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func newRuleRef(ruleIds ...string) *object.Expression {
+	refs := []string{}
+	for _, ruleId := range ruleIds {
+		refs = append(refs, fmt.Sprintf("rule(%q)", ruleId))
+	}
+	return &object.Expression{Operator: "expression", Value: strings.Join(refs, " || ")}
+}
+
+func TestCompoundRuleCycle(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	// a -> b -> a
+	a := addTestRule(t, "a", "Compound", newRuleRef("admin/b"))
+	addTestRule(t, "b", "Compound", newRuleRef("admin/a"))
+	// c -> c
+	c := addTestRule(t, "c", "Compound", newRuleRef("admin/c"))
+	// d -> e -> g, d -> f -> g
+	d := addTestRule(t, "d", "Compound", newRuleRef("admin/e", "admin/f"))
+	addTestRule(t, "e", "Compound", newRuleRef("admin/g"))
+	addTestRule(t, "f", "Compound", newRuleRef("admin/g"))
+	addTestRule(t, "g", "Method", &object.Expression{Operator: "is in", Value: "POST"})
+
+	tests := []struct {
+		rule      *object.Rule
+		wantCycle bool
+	}{
+		{rule: a, wantCycle: true},
+		{rule: c, wantCycle: true},
+		{rule: d, wantCycle: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule.Name, func(t *testing.T) {
+			err := CheckCompoundRule(tt.rule.GetId(), tt.rule.Expressions)
+			if (err != nil) != tt.wantCycle {
+				t.Errorf("CheckCompoundRule() error = %v, wantCycle %v", err, tt.wantCycle)
+			}
+			if err != nil && !strings.Contains(err.Error(), "cycle") {
+				t.Errorf("CheckCompoundRule() error = %v, want a cycle", err)
+			}
+
+			r := &CompoundRule{ruleName: tt.rule.GetId(), revision: tt.rule.GetRevision()}
+			result, err := r.checkRule(tt.rule.Expressions, newTestRequest())
+			if (err != nil) != tt.wantCycle {
+				t.Fatalf("checkRule() error = %v, wantCycle %v", err, tt.wantCycle)
+			}
+			if err != nil && !strings.Contains(err.Error(), "cycle") {
+				t.Errorf("checkRule() error = %v, want a cycle", err)
+			}
+			if !tt.wantCycle && result == nil {
+				t.Errorf("checkRule() does not hit the rule referenced twice")
+			}
+		})
+	}
+}
+
+func TestCompoundRuleEdited(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	addTestRule(t, "get", "Method", &object.Expression{Operator: "is in", Value: "GET"})
+	addTestRule(t, "post", "Method", &object.Expression{Operator: "is in", Value: "POST"})
+	rule := addTestRule(t, "edited", "Compound", newRuleRef("admin/get"))
+
+	result, err := CheckRules([]string{rule.GetId()}, newTestRequest())
+	if err != nil || result.Action == "Block" {
+		t.Fatalf("CheckRules() = %v, %v, want no hit", result, err)
+	}
+
+	// the rule edited within the same second is compiled again
+	rule.Expressions = []*object.Expression{newRuleRef("admin/post")}
+	_, err = object.UpdateRule(rule.GetId(), rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = CheckRules([]string{rule.GetId()}, newTestRequest())
+	if err != nil || result.Action != "Block" {
+		t.Fatalf("CheckRules() = %v, %v, want Block by the edited rule", result, err)
+	}
+
+	_, err = object.DeleteRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := compiledCompoundRules[rule.GetId()]; ok {
+		t.Errorf("the compiled expression of the deleted rule is not dropped")
+	}
+}
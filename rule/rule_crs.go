@@ -0,0 +1,432 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+	"github.com/corazawaf/coraza/v3/types/variables"
+)
+
+// The CRS detection rules are numbered from 911000 to 948999, rules out of the range initialize
+// the rule set or evaluate the anomaly score
+const (
+	crsDetectionRuleIdMin = 911000
+	crsDetectionRuleIdMax = 948999
+
+	// generated rules for the paranoia level and path exclusions, out of the ranges used by CRS
+	crsGeneratedRuleId = 100000
+)
+
+type CrsRule struct {
+	ruleName string
+	revision int64
+	isScore  bool
+	trace    *RuleTrace
+}
+
+type crsFile struct {
+	group   string
+	content string
+}
+
+type crsRuleSet struct {
+	rulesDir string
+	setup    string
+	files    []*crsFile
+}
+
+type crsExclusion struct {
+	ruleId int
+	path   string
+	target string
+}
+
+type crsConfig struct {
+	paranoiaLevel int
+	groups        []string
+	exclusions    []*crsExclusion
+}
+
+type compiledCrsRule struct {
+	revision int64
+	waf      coraza.WAF
+}
+
+var (
+	crsRules *crsRuleSet
+
+	compiledCrsRules     = map[string]*compiledCrsRule{}
+	compiledCrsRulesLock = &sync.RWMutex{}
+)
+
+func init() {
+	object.AddRuleRefreshHandler(refreshCompiledCrsRules)
+}
+
+// refreshCompiledCrsRules drops the WAFs of the edited and deleted rules
+func refreshCompiledCrsRules(rules map[string]*object.Rule) {
+	compiledCrsRulesLock.Lock()
+	defer compiledCrsRulesLock.Unlock()
+	for ruleName, compiled := range compiledCrsRules {
+		if rule, ok := rules[ruleName]; !ok || rule.GetRevision() != compiled.revision {
+			delete(compiledCrsRules, ruleName)
+		}
+	}
+}
+
+func InitCrs() {
+	crsPath := conf.GetConfigString("crsPath")
+	if crsPath == "" {
+		crsPath = "crs"
+	}
+	if !util.FileExist(crsPath) {
+		fmt.Printf("InitCrs(): OWASP Core Rule Set not found: %s, CRS rules are disabled\n", crsPath)
+		return
+	}
+
+	ruleSet, err := loadCrs(crsPath)
+	if err != nil {
+		panic(err)
+	}
+	crsRules = ruleSet
+}
+
+// loadCrs loads the CRS from the directory of a CRS release, which contains crs-setup.conf
+// (or crs-setup.conf.example) and the rules/ directory of *.conf rule files and *.data files
+func loadCrs(crsPath string) (*crsRuleSet, error) {
+	res := &crsRuleSet{
+		rulesDir: filepath.Join(crsPath, "rules"),
+		files:    []*crsFile{},
+	}
+
+	for _, name := range []string{"crs-setup.conf", "crs-setup.conf.example"} {
+		setupPath := filepath.Join(crsPath, name)
+		if util.FileExist(setupPath) {
+			content, err := os.ReadFile(setupPath)
+			if err != nil {
+				return nil, err
+			}
+			res.setup = string(content)
+			break
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(res.rulesDir, "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no CRS rule file found in: %s", res.rulesDir)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		res.files = append(res.files, &crsFile{
+			group:   strings.TrimSuffix(filepath.Base(path), ".conf"),
+			content: string(content),
+		})
+	}
+	return res, nil
+}
+
+// isCrsCoreGroup returns whether the rule file is needed by all the others and always loaded,
+// e.g., REQUEST-901-INITIALIZATION and REQUEST-949-BLOCKING-EVALUATION
+func isCrsCoreGroup(group string) bool {
+	return strings.Contains(group, "INITIALIZATION") || strings.Contains(group, "BLOCKING-EVALUATION") || strings.Contains(group, "CORRELATION")
+}
+
+// GetCrsGroups returns the rule groups of the loaded CRS that can be enabled by a CRS rule
+func GetCrsGroups() []string {
+	res := []string{}
+	if crsRules == nil {
+		return res
+	}
+
+	for _, file := range crsRules.files {
+		if !isCrsCoreGroup(file.group) {
+			res = append(res, file.group)
+		}
+	}
+	return res
+}
+
+// parseCrsConfig parses the expressions of a CRS rule:
+// "paranoia level" with a value from 1 to 4, "enable group" with the name of a rule group
+// (all groups are enabled if none is given), and "exclude rule" with a CRS rule ID followed by
+// an optional target like "942100 ARGS:q" as the value and an optional path prefix as the name
+func parseCrsConfig(expressions []*object.Expression) (*crsConfig, error) {
+	res := &crsConfig{
+		paranoiaLevel: 1,
+		groups:        []string{},
+		exclusions:    []*crsExclusion{},
+	}
+
+	for _, expression := range expressions {
+		switch expression.Operator {
+		case "paranoia level":
+			level, err := strconv.Atoi(expression.Value)
+			if err != nil || level < 1 || level > 4 {
+				return nil, fmt.Errorf("invalid paranoia level: %s, it should be from 1 to 4", expression.Value)
+			}
+			res.paranoiaLevel = level
+		case "enable group":
+			res.groups = append(res.groups, expression.Value)
+		case "exclude rule":
+			exclusion, err := parseCrsExclusion(expression)
+			if err != nil {
+				return nil, err
+			}
+			res.exclusions = append(res.exclusions, exclusion)
+		default:
+			return nil, fmt.Errorf("unknown operator: %s for CRS rule", expression.Operator)
+		}
+	}
+	return res, nil
+}
+
+func parseCrsExclusion(expression *object.Expression) (*crsExclusion, error) {
+	tokens := strings.Fields(expression.Value)
+	if len(tokens) == 0 || len(tokens) > 2 {
+		return nil, fmt.Errorf("invalid CRS exclusion: %s, it should be a rule ID with an optional target like \"942100 ARGS:q\"", expression.Value)
+	}
+
+	ruleId, err := strconv.Atoi(tokens[0])
+	if err != nil || ruleId <= 0 {
+		return nil, fmt.Errorf("invalid CRS rule ID: %s", tokens[0])
+	}
+
+	res := &crsExclusion{
+		ruleId: ruleId,
+		path:   expression.Name,
+	}
+	if len(tokens) == 2 {
+		res.target = tokens[1]
+	}
+	if strings.ContainsAny(res.path, "\"\\") || strings.ContainsAny(res.target, "\"\\;,") {
+		return nil, fmt.Errorf("invalid CRS exclusion: %s %s", expression.Name, expression.Value)
+	}
+	return res, nil
+}
+
+// NewCrsExclusion returns the "exclude rule" expression of a CRS rule, which excludes the target
+// (e.g., "ARGS:q") or the whole CRS rule ruleId if target is empty, for the path prefix or all paths
+func NewCrsExclusion(ruleId int, path string, target string) *object.Expression {
+	value := strconv.Itoa(ruleId)
+	if target != "" {
+		value = fmt.Sprintf("%d %s", ruleId, target)
+	}
+
+	return &object.Expression{
+		Name:     path,
+		Operator: "exclude rule",
+		Value:    value,
+	}
+}
+
+// getDirectives returns the SecLang directives of the rule set configured by config. Exclusions
+// for a path are runtime ctl actions that must precede the rules, the others are applied on the
+// loaded rules so they follow the rule files.
+func (ruleSet *crsRuleSet) getDirectives(config *crsConfig) string {
+	var sb strings.Builder
+	sb.WriteString(ruleSet.setup)
+	sb.WriteString("\n")
+
+	ruleId := crsGeneratedRuleId
+	sb.WriteString(fmt.Sprintf("SecAction \"id:%d,phase:1,pass,nolog,t:none,setvar:tx.paranoia_level=%d,setvar:tx.blocking_paranoia_level=%d\"\n",
+		ruleId, config.paranoiaLevel, config.paranoiaLevel))
+
+	for _, exclusion := range config.exclusions {
+		if exclusion.path == "" {
+			continue
+		}
+
+		ruleId++
+		ctl := fmt.Sprintf("ctl:ruleRemoveById=%d", exclusion.ruleId)
+		if exclusion.target != "" {
+			ctl = fmt.Sprintf("ctl:ruleRemoveTargetById=%d;%s", exclusion.ruleId, exclusion.target)
+		}
+		sb.WriteString(fmt.Sprintf("SecRule REQUEST_FILENAME \"@beginsWith %s\" \"id:%d,phase:1,pass,nolog,t:none,%s\"\n", exclusion.path, ruleId, ctl))
+	}
+
+	for _, file := range ruleSet.files {
+		if len(config.groups) != 0 && !isCrsCoreGroup(file.group) && !containsString(config.groups, file.group) {
+			continue
+		}
+		sb.WriteString(file.content)
+		sb.WriteString("\n")
+	}
+
+	for _, exclusion := range config.exclusions {
+		if exclusion.path != "" {
+			continue
+		}
+
+		if exclusion.target == "" {
+			sb.WriteString(fmt.Sprintf("SecRuleRemoveById %d\n", exclusion.ruleId))
+		} else {
+			sb.WriteString(fmt.Sprintf("SecRuleUpdateTargetById %d \"!%s\"\n", exclusion.ruleId, exclusion.target))
+		}
+	}
+	return sb.String()
+}
+
+// CheckCrsRule checks the expressions of a CRS rule, the enabled groups must exist in the loaded CRS
+func CheckCrsRule(expressions []*object.Expression) error {
+	config, err := parseCrsConfig(expressions)
+	if err != nil {
+		return err
+	}
+
+	if crsRules == nil {
+		return nil
+	}
+	groups := GetCrsGroups()
+	for _, group := range config.groups {
+		if !containsString(groups, group) {
+			return fmt.Errorf("unknown CRS rule group: %s", group)
+		}
+	}
+	return nil
+}
+
+// getCrsWaf returns the WAF of the CRS rule, it is cached by the rule revision
+// because loading the whole rule set for each request is too slow
+func getCrsWaf(ruleName string, revision int64, expressions []*object.Expression) (coraza.WAF, error) {
+	if crsRules == nil {
+		return nil, fmt.Errorf("the OWASP Core Rule Set is not loaded, please set crsPath in conf/app.conf")
+	}
+
+	compiledCrsRulesLock.RLock()
+	compiled, ok := compiledCrsRules[ruleName]
+	compiledCrsRulesLock.RUnlock()
+	if ok && compiled.revision == revision {
+		return compiled.waf, nil
+	}
+
+	config, err := parseCrsConfig(expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	waf, err := coraza.NewWAF(
+		coraza.NewWAFConfig().
+			WithErrorCallback(logError).
+			WithRootFS(os.DirFS(crsRules.rulesDir)).
+			WithDirectives(conf.WafConf).
+			WithDirectives(crsRules.getDirectives(config)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("CRS rule: %s, create WAF failed: %v", ruleName, err)
+	}
+
+	compiledCrsRulesLock.Lock()
+	compiledCrsRules[ruleName] = &compiledCrsRule{revision: revision, waf: waf}
+	compiledCrsRulesLock.Unlock()
+	return waf, nil
+}
+
+func (r *CrsRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
+	waf, err := getCrsWaf(r.ruleName, r.revision, expressions)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := waf.NewTransaction()
+	defer tx.Close()
+	processRequest(tx, req)
+	matchedRules := tx.MatchedRules()
+	traceWafMatchedRules(r.trace, matchedRules)
+
+	score := getWafAnomalyScore(tx)
+	if score == 0 {
+		return nil, nil
+	}
+
+	if r.isScore {
+		return &RuleResult{
+			Score:      score,
+			Reason:     fmt.Sprintf("CRS anomaly score: %d", score),
+			WafMatches: getCrsMatches(r.ruleName, matchedRules),
+		}, nil
+	}
+
+	threshold := getWafTxInt(tx, "inbound_anomaly_score_threshold")
+	if threshold == 0 {
+		threshold = 5
+	}
+	if score < threshold {
+		return nil, nil
+	}
+
+	return &RuleResult{
+		Reason:     fmt.Sprintf("CRS anomaly score %d reached the threshold %d", score, threshold),
+		WafMatches: getCrsMatches(r.ruleName, matchedRules),
+	}, nil
+}
+
+// getCrsMatches returns the matched CRS detection rules with the matched variables,
+// which are used to create exclusions for false positives
+func getCrsMatches(ruleName string, matchedRules []types.MatchedRule) []*object.WafMatch {
+	res := []*object.WafMatch{}
+	for _, matchedRule := range matchedRules {
+		id := matchedRule.Rule().ID()
+		if id < crsDetectionRuleIdMin || id > crsDetectionRuleIdMax {
+			continue
+		}
+
+		targets := map[string]bool{}
+		for _, matchData := range matchedRule.MatchedDatas() {
+			// TX variables and the variables matched by chained rules are conditions on the
+			// transaction rather than the inspected input
+			if matchData.ChainLevel() != 0 || matchData.Variable() == variables.TX {
+				continue
+			}
+
+			target := matchData.Variable().Name()
+			if matchData.Key() != "" {
+				target = fmt.Sprintf("%s:%s", target, matchData.Key())
+			}
+			if targets[target] {
+				continue
+			}
+			targets[target] = true
+
+			res = append(res, &object.WafMatch{
+				Rule:      ruleName,
+				WafRuleId: id,
+				Target:    target,
+				Message:   matchedRule.Message(),
+			})
+		}
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+// a minimal rule set laid out like a CRS release
+var testCrsFiles = map[string]string{
+	"REQUEST-901-INITIALIZATION.conf":    `SecAction "id:901100,phase:1,pass,nolog,setvar:'tx.inbound_anomaly_score_threshold=5'"`,
+	"REQUEST-913-SCANNER-DETECTION.conf": `SecRule REQUEST_HEADERS:User-Agent "@pmFromFile scanners-user-agents.data" "id:913100,phase:1,pass,msg:'Scanner',setvar:'tx.anomaly_score=+5'"`,
+	"REQUEST-942-APPLICATION-ATTACK-SQLI.conf": `SecRule ARGS "@contains select" "id:942100,phase:2,pass,msg:'SQL Injection',setvar:'tx.anomaly_score=+5'"
+SecRule TX:PARANOIA_LEVEL "@lt 2" "id:942014,phase:2,pass,nolog,skipAfter:END-REQUEST-942-PL2"
+SecRule ARGS "@contains union" "id:942200,phase:2,pass,msg:'SQL Injection',setvar:'tx.anomaly_score=+5'"
+SecMarker "END-REQUEST-942-PL2"`,
+	"scanners-user-agents.data": "sqlmap\n",
+}
+
+func initTestCrs(t *testing.T) {
+	crsPath := t.TempDir()
+	rulesDir := filepath.Join(crsPath, "rules")
+	err := os.Mkdir(rulesDir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testCrsFiles {
+		err = os.WriteFile(filepath.Join(rulesDir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	crsRules, err = loadCrs(crsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { crsRules = nil })
+}
+
+func TestCrsRule_checkRule(t *testing.T) {
+	initTestCrs(t)
+
+	tests := []struct {
+		name        string
+		expressions []*object.Expression
+		url         string
+		userAgent   string
+		wantHit     bool
+		wantMatches []string
+	}{
+		{
+			name:        "sqli",
+			url:         "http://example.com/api/search?q=select",
+			wantHit:     true,
+			wantMatches: []string{"ARGS:q"},
+		},
+		{
+			name:      "scanner with data file",
+			url:       "http://example.com/",
+			userAgent: "sqlmap/1.0",
+			wantHit:   true,
+		},
+		{
+			name:    "clean",
+			url:     "http://example.com/api/search?q=hello",
+			wantHit: false,
+		},
+		{
+			name:        "paranoia level 1 skips the rule of level 2",
+			url:         "http://example.com/api/search?q=union",
+			wantHit:     false,
+			expressions: []*object.Expression{{Operator: "paranoia level", Value: "1"}},
+		},
+		{
+			name:        "paranoia level 2",
+			url:         "http://example.com/api/search?q=union",
+			wantHit:     true,
+			wantMatches: []string{"ARGS:q"},
+			expressions: []*object.Expression{{Operator: "paranoia level", Value: "2"}},
+		},
+		{
+			name:    "group not enabled",
+			url:     "http://example.com/api/search?q=select",
+			wantHit: false,
+			expressions: []*object.Expression{
+				{Operator: "enable group", Value: "REQUEST-913-SCANNER-DETECTION"},
+			},
+		},
+		{
+			name:    "target excluded for path",
+			url:     "http://example.com/api/search?q=select",
+			wantHit: false,
+			expressions: []*object.Expression{
+				NewCrsExclusion(942100, "/api/search", "ARGS:q"),
+			},
+		},
+		{
+			name:        "target excluded for another path",
+			url:         "http://example.com/api/query?q=select",
+			wantHit:     true,
+			wantMatches: []string{"ARGS:q"},
+			expressions: []*object.Expression{
+				NewCrsExclusion(942100, "/api/search", "ARGS:q"),
+			},
+		},
+		{
+			name:    "rule excluded",
+			url:     "http://example.com/api/query?q=select",
+			wantHit: false,
+			expressions: []*object.Expression{
+				NewCrsExclusion(942100, "", ""),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &CrsRule{ruleName: "admin/" + tt.name}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.userAgent != "" {
+				req.Header.Set("User-Agent", tt.userAgent)
+			}
+
+			result, err := r.checkRule(tt.expressions, req)
+			if err != nil {
+				t.Fatalf("checkRule() error = %v", err)
+			}
+			if (result != nil) != tt.wantHit {
+				t.Fatalf("checkRule() hit = %v, want %v", result != nil, tt.wantHit)
+			}
+			if result == nil || tt.wantMatches == nil {
+				return
+			}
+
+			targets := []string{}
+			for _, wafMatch := range result.WafMatches {
+				targets = append(targets, wafMatch.Target)
+			}
+			if len(targets) != len(tt.wantMatches) || targets[0] != tt.wantMatches[0] {
+				t.Errorf("checkRule() matches = %v, want %v", targets, tt.wantMatches)
+			}
+		})
+	}
+}
+
+func TestCheckCrsRule(t *testing.T) {
+	initTestCrs(t)
+
+	tests := []struct {
+		expression *object.Expression
+		wantErr    bool
+	}{
+		{&object.Expression{Operator: "paranoia level", Value: "2"}, false},
+		{&object.Expression{Operator: "paranoia level", Value: "5"}, true},
+		{&object.Expression{Operator: "enable group", Value: "REQUEST-942-APPLICATION-ATTACK-SQLI"}, false},
+		{&object.Expression{Operator: "enable group", Value: "REQUEST-999-UNKNOWN"}, true},
+		{&object.Expression{Operator: "exclude rule", Value: "942100 ARGS:q"}, false},
+		{&object.Expression{Operator: "exclude rule", Value: "abc"}, true},
+		{&object.Expression{Operator: "exclude rule", Value: "942100 ARGS:q;ARGS:id"}, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckCrsRule([]*object.Expression{tt.expression})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckCrsRule(%s %s) error = %v, wantErr %v", tt.expression.Operator, tt.expression.Value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCrsRuleEdited(t *testing.T) {
+	initTestCrs(t)
+	object.InitMemoryAdapter()
+
+	rule := addTestRule(t, "crs-edited", "CRS", &object.Expression{Operator: "paranoia level", Value: "1"})
+	req := httptest.NewRequest("GET", "http://example.com/api/search?q=union", nil)
+	result, err := CheckRules([]string{rule.GetId()}, req)
+	if err != nil || result.Action == "Block" {
+		t.Fatalf("CheckRules() = %v, %v, want no hit at paranoia level 1", result, err)
+	}
+
+	// the WAF of the rule edited within the same second is created again
+	rule.Expressions = []*object.Expression{{Operator: "paranoia level", Value: "2"}}
+	_, err = object.UpdateRule(rule.GetId(), rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = CheckRules([]string{rule.GetId()}, req)
+	if err != nil || result.Action != "Block" {
+		t.Fatalf("CheckRules() = %v, %v, want Block at paranoia level 2", result, err)
+	}
+
+	_, err = object.DeleteRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := compiledCrsRules[rule.GetId()]; ok {
+		t.Errorf("the WAF of the deleted rule is not dropped")
+	}
+}
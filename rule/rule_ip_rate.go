@@ -26,6 +26,7 @@ import (
 
 type IpRateRule struct {
 	ruleName string
+	dryRun   bool
 }
 
 type IpRateLimiter struct {
@@ -84,6 +85,33 @@ func (r *IpRateRule) checkRule(expressions []*object.Expression, req *http.Reque
 	expression := expressions[0] // IpRate rule should have only one expression
 	clientIp := util.GetClientIp(req)
 
+	// In dry run mode, report whether the request would be blocked without changing the blacklist or
+	// consuming a token, as the state is shared with the live requests
+	if r.dryRun {
+		createAt, ok := blackList[r.ruleName][clientIp]
+		if ok && time.Now().Sub(createAt) < time.Duration(util.ParseInt(expression.Value))*time.Second {
+			return &RuleResult{
+				Action: "Block",
+				Reason: "Rate limit exceeded",
+			}, nil
+		}
+
+		ipRateLimiter := ipRateLimiters[r.ruleName]
+		if ipRateLimiter == nil {
+			return nil, nil
+		}
+		ipRateLimiter.mu.Lock()
+		limiter, exists := ipRateLimiter.ips[clientIp]
+		ipRateLimiter.mu.Unlock()
+		if exists && limiter.Tokens() < 1 {
+			return &RuleResult{
+				Action: "Block",
+				Reason: "Rate limit exceeded",
+			}, nil
+		}
+		return nil, nil
+	}
+
 	// If the client IP is in the blacklist, check the block time
 	createAt, ok := blackList[r.ruleName][clientIp]
 	if ok {
@@ -94,7 +122,7 @@ func (r *IpRateRule) checkRule(expressions []*object.Expression, req *http.Reque
 				Reason: "Rate limit exceeded",
 			}, nil
 		} else {
-			delete(blackList, clientIp)
+			delete(blackList[r.ruleName], clientIp)
 		}
 	}
 
@@ -17,6 +17,7 @@ package rule
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/casbin/caswaf/object"
 )
@@ -24,6 +25,7 @@ import (
 func TestIpRateRule_checkRule(t *testing.T) {
 	type fields struct {
 		ruleName string
+		dryRun   bool
 	}
 	type args struct {
 		args []struct {
@@ -125,6 +127,7 @@ func TestIpRateRule_checkRule(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			r := &IpRateRule{
 				ruleName: tt.fields.ruleName,
+				dryRun:   tt.fields.dryRun,
 			}
 			for i, arg := range tt.args.args {
 				result, err := r.checkRule(arg.expressions, arg.req)
@@ -152,3 +155,77 @@ func TestIpRateRule_checkRule(t *testing.T) {
 		})
 	}
 }
+
+func TestIpRateRuleDryRun(t *testing.T) {
+	expressions := []*object.Expression{{Operator: "2", Value: "1"}}
+	req := &http.Request{RemoteAddr: "127.0.0.1"}
+
+	// dry run before any real request has no limiter to check against
+	dryRunRule := &IpRateRule{ruleName: "rule-dry-run", dryRun: true}
+	result, err := dryRunRule.checkRule(expressions, req)
+	if err != nil || result != nil {
+		t.Fatalf("checkRule() = %v, %v, want nil", result, err)
+	}
+
+	// spend the burst with real requests
+	r := &IpRateRule{ruleName: "rule-dry-run"}
+	for i := 0; i < 2; i++ {
+		result, err = r.checkRule(expressions, req)
+		if err != nil || result != nil {
+			t.Fatalf("checkRule() #%d = %v, %v, want nil", i, result, err)
+		}
+	}
+
+	limiter := ipRateLimiters["rule-dry-run"].GetLimiter("127.0.0.1")
+	now := time.Now()
+	tokens := limiter.TokensAt(now)
+	if tokens >= 1 {
+		t.Fatalf("Tokens() = %f after spending the burst, want < 1", tokens)
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err = dryRunRule.checkRule(expressions, req)
+		if err != nil {
+			t.Fatalf("checkRule() error = %v", err)
+		}
+		if result == nil || result.Action != "Block" {
+			t.Fatalf("checkRule() = %v, want Block", result)
+		}
+	}
+
+	if got := limiter.TokensAt(now); got != tokens {
+		t.Errorf("Tokens() = %f after dry run, want %f", got, tokens)
+	}
+	if _, ok := blackList["rule-dry-run"]; ok {
+		t.Errorf("dry run should not blacklist the client IP")
+	}
+}
+
+func TestIpRateRuleDryRunBlackList(t *testing.T) {
+	expressions := []*object.Expression{{Operator: "2", Value: "1"}}
+	req := &http.Request{RemoteAddr: "127.0.0.2"}
+
+	// an expired entry of the blacklist is only removed by a live request
+	createAt := time.Now().Add(-time.Hour)
+	blackList["rule-dry-run-blacklist"] = map[string]time.Time{"127.0.0.2": createAt}
+	defer delete(blackList, "rule-dry-run-blacklist")
+
+	dryRunRule := &IpRateRule{ruleName: "rule-dry-run-blacklist", dryRun: true}
+	result, err := dryRunRule.checkRule(expressions, req)
+	if err != nil || result != nil {
+		t.Fatalf("checkRule() = %v, %v, want nil after the block time", result, err)
+	}
+	if got, ok := blackList["rule-dry-run-blacklist"]["127.0.0.2"]; !ok || !got.Equal(createAt) {
+		t.Errorf("dry run changes the blacklist: %v", blackList["rule-dry-run-blacklist"])
+	}
+	if _, ok := ipRateLimiters["rule-dry-run-blacklist"]; ok {
+		t.Errorf("dry run should not create the rate limiter")
+	}
+
+	// the client IP in the blacklist is blocked by a dry run
+	blackList["rule-dry-run-blacklist"]["127.0.0.2"] = time.Now()
+	result, err = dryRunRule.checkRule(expressions, req)
+	if err != nil || result == nil || result.Action != "Block" {
+		t.Errorf("checkRule() = %v, %v, want Block", result, err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+)
+
+// RequestRule matches a request attribute selected by ruleType against the expressions.
+// For "Header", "Cookie" and "Query" rules, expression.Name is the header, cookie or query parameter name.
+// For "Client Cert" rules, expression.Name is the attribute of the verified client certificate, e.g., "Subject".
+type RequestRule struct {
+	ruleType string
+}
+
+func (r *RequestRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
+	for _, expression := range expressions {
+		value, exists := getRequestAttribute(r.ruleType, expression.Name, req)
+		isHit, err := matchString(expression, value, exists)
+		if err != nil {
+			return nil, err
+		}
+		if isHit {
+			return &RuleResult{Reason: getRequestReason(r.ruleType, expression, value)}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ContentLengthRule compares the request's Content-Length numerically against the expressions.
+type ContentLengthRule struct{}
+
+func (r *ContentLengthRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
+	contentLength := req.ContentLength
+	for _, expression := range expressions {
+		isHit, err := matchNumber(expression.Operator, contentLength, contentLength >= 0, expression.Value)
+		if err != nil {
+			return nil, err
+		}
+		if isHit {
+			reason := fmt.Sprintf("expression matched: \"%d %s %s\"", contentLength, expression.Operator, expression.Value)
+			return &RuleResult{Reason: reason}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func getRequestAttribute(ruleType string, name string, req *http.Request) (string, bool) {
+	switch ruleType {
+	case "Header":
+		values := req.Header.Values(name)
+		return strings.Join(values, ", "), len(values) != 0
+	case "Cookie":
+		cookie, err := req.Cookie(name)
+		if err != nil {
+			return "", false
+		}
+		return cookie.Value, true
+	case "Query":
+		values, ok := req.URL.Query()[name]
+		return strings.Join(values, ","), ok
+	case "Method":
+		return req.Method, req.Method != ""
+	case "Path":
+		return req.URL.Path, true
+	case "Host":
+		return req.Host, req.Host != ""
+	case "Content-Type":
+		contentType := req.Header.Get("Content-Type")
+		return contentType, contentType != ""
+	case "Client Cert":
+		cert := util.GetClientCert(req)
+		if cert == nil {
+			return "", false
+		}
+		return util.GetClientCertAttribute(cert, name), true
+	default:
+		return "", false
+	}
+}
+
+func getRequestReason(ruleType string, expression *object.Expression, value string) string {
+	switch ruleType {
+	case "Header", "Cookie", "Query", "Client Cert":
+		return fmt.Sprintf("expression matched: \"%s %s[%s] %s %s\"", value, ruleType, expression.Name, expression.Operator, expression.Value)
+	default:
+		return fmt.Sprintf("expression matched: \"%s %s %s\"", value, expression.Operator, expression.Value)
+	}
+}
+
+func matchString(expression *object.Expression, target string, exists bool) (bool, error) {
+	value := expression.Value
+	switch expression.Operator {
+	case "exists":
+		return exists, nil
+	case "does not exist":
+		return !exists, nil
+	case "contains":
+		return strings.Contains(target, value), nil
+	case "does not contain":
+		return !strings.Contains(target, value), nil
+	case "equals":
+		return target == value, nil
+	case "does not equal":
+		return target != value, nil
+	case "starts with":
+		return strings.HasPrefix(target, value), nil
+	case "ends with":
+		return strings.HasSuffix(target, value), nil
+	case "is in":
+		return isInList(target, value), nil
+	case "is not in":
+		return !isInList(target, value), nil
+	case "match", "does not match":
+		// regex match, the regex is precompiled when the rule is loaded
+		re, err := expression.GetRegexp()
+		if err != nil {
+			return false, err
+		}
+		isHit := re.MatchString(target)
+		if expression.Operator == "does not match" {
+			return !isHit, nil
+		}
+		return isHit, nil
+	default:
+		return false, fmt.Errorf("unknown operator: %s", expression.Operator)
+	}
+}
+
+func matchNumber(operator string, target int64, exists bool, value string) (bool, error) {
+	switch operator {
+	case "exists":
+		return exists, nil
+	case "does not exist":
+		return !exists, nil
+	}
+
+	num, err := util.ParseIntWithError(value)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+
+	switch operator {
+	case "equals":
+		return target == int64(num), nil
+	case "does not equal":
+		return target != int64(num), nil
+	case "greater than":
+		return target > int64(num), nil
+	case "greater than or equal to":
+		return target >= int64(num), nil
+	case "less than":
+		return target < int64(num), nil
+	case "less than or equal to":
+		return target <= int64(num), nil
+	default:
+		return false, fmt.Errorf("unknown operator: %s", operator)
+	}
+}
+
+func isInList(target string, list string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func newTestRequest() *http.Request {
+	req := httptest.NewRequest("POST", "http://example.com/api/search?q=select&page=1", strings.NewReader("hello"))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Api-Version", "2")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc123"})
+	return req
+}
+
+func TestRequestRule_checkRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		ruleType   string
+		expression *object.Expression
+		want       bool
+		wantErr    bool
+	}{
+		{"header equals", "Header", &object.Expression{Name: "X-Api-Version", Operator: "equals", Value: "2"}, true, false},
+		{"header name is case-insensitive", "Header", &object.Expression{Name: "x-api-version", Operator: "exists"}, true, false},
+		{"header does not exist", "Header", &object.Expression{Name: "X-Missing", Operator: "does not exist"}, true, false},
+		{"header missing does not contain", "Header", &object.Expression{Name: "X-Missing", Operator: "contains", Value: "a"}, false, false},
+		{"cookie starts with", "Cookie", &object.Expression{Name: "session", Operator: "starts with", Value: "abc"}, true, false},
+		{"cookie missing", "Cookie", &object.Expression{Name: "token", Operator: "exists"}, false, false},
+		{"query regex match", "Query", &object.Expression{Name: "q", Operator: "match", Value: "(?i)^select"}, true, false},
+		{"query regex does not match", "Query", &object.Expression{Name: "q", Operator: "does not match", Value: "^union"}, true, false},
+		{"method is in", "Method", &object.Expression{Operator: "is in", Value: "GET, POST"}, true, false},
+		{"method is not in", "Method", &object.Expression{Operator: "is not in", Value: "GET,HEAD"}, true, false},
+		{"path starts with", "Path", &object.Expression{Operator: "starts with", Value: "/api/"}, true, false},
+		{"path ends with", "Path", &object.Expression{Operator: "ends with", Value: "/admin"}, false, false},
+		{"host does not equal", "Host", &object.Expression{Operator: "does not equal", Value: "example.com"}, false, false},
+		{"content type contains", "Content-Type", &object.Expression{Operator: "contains", Value: "json"}, true, false},
+		{"client cert does not exist", "Client Cert", &object.Expression{Name: "Subject", Operator: "does not exist"}, true, false},
+		{"client cert missing", "Client Cert", &object.Expression{Name: "Subject", Operator: "contains", Value: "CN="}, false, false},
+		{"invalid regex", "Path", &object.Expression{Operator: "match", Value: "("}, false, true},
+		{"unknown operator", "Path", &object.Expression{Operator: "is abroad", Value: ""}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RequestRule{ruleType: tt.ruleType}
+			result, err := r.checkRule([]*object.Expression{tt.expression}, newTestRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := result != nil; got != tt.want {
+				t.Errorf("checkRule() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentLengthRule_checkRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression *object.Expression
+		want       bool
+		wantErr    bool
+	}{
+		{"equals", &object.Expression{Operator: "equals", Value: "5"}, true, false},
+		{"greater than", &object.Expression{Operator: "greater than", Value: "5"}, false, false},
+		{"greater than or equal to", &object.Expression{Operator: "greater than or equal to", Value: "5"}, true, false},
+		{"less than", &object.Expression{Operator: "less than", Value: "1024"}, true, false},
+		{"exists", &object.Expression{Operator: "exists"}, true, false},
+		{"invalid number", &object.Expression{Operator: "less than", Value: "abc"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ContentLengthRule{}
+			result, err := r.checkRule([]*object.Expression{tt.expression}, newTestRequest())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := result != nil; got != tt.want {
+				t.Errorf("checkRule() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+)
+
+type ruleStats struct {
+	evalCount   int64
+	hitCount    int64
+	evalTime    time.Duration
+	lastHitTime string
+}
+
+var (
+	ruleStatsMap  = map[string]*ruleStats{}
+	ruleStatsLock = &sync.Mutex{}
+)
+
+func recordRuleStats(ruleId string, evalTime time.Duration, isHit bool) {
+	var lastHitTime string
+	var hitCount int64
+	if isHit {
+		hitCount = 1
+		lastHitTime = util.GetCurrentTime()
+	}
+
+	addRuleStats(ruleId, &ruleStats{evalCount: 1, hitCount: hitCount, evalTime: evalTime, lastHitTime: lastHitTime})
+}
+
+// addRuleStats adds the stats to the ones of the rule to flush
+func addRuleStats(ruleId string, s *ruleStats) {
+	ruleStatsLock.Lock()
+	defer ruleStatsLock.Unlock()
+
+	stats, ok := ruleStatsMap[ruleId]
+	if !ok {
+		stats = &ruleStats{}
+		ruleStatsMap[ruleId] = stats
+	}
+
+	stats.evalCount += s.evalCount
+	stats.hitCount += s.hitCount
+	stats.evalTime += s.evalTime
+	if s.lastHitTime > stats.lastHitTime {
+		stats.lastHitTime = s.lastHitTime
+	}
+}
+
+// flushRuleStats saves the stats of the rules, the stats failed to save are kept to the next flush
+func flushRuleStats() error {
+	ruleStatsLock.Lock()
+	statsMap := ruleStatsMap
+	ruleStatsMap = map[string]*ruleStats{}
+	ruleStatsLock.Unlock()
+
+	var res error
+	for ruleId, stats := range statsMap {
+		err := object.AddRuleStats(ruleId, stats.evalCount, stats.hitCount, stats.evalTime.Microseconds(), stats.lastHitTime)
+		if err != nil {
+			addRuleStats(ruleId, stats)
+			if res == nil {
+				res = fmt.Errorf("rule: %s, %v", ruleId, err)
+			}
+		}
+	}
+
+	return res
+}
+
+func StartFlushRuleStatsLoop() {
+	fmt.Printf("StartFlushRuleStatsLoop() Start!\n\n")
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("[%s] Recovered from StartFlushRuleStatsLoop() panic: %v\n", util.GetCurrentTime(), r)
+				StartFlushRuleStatsLoop()
+			}
+		}()
+
+		for {
+			time.Sleep(10 * time.Second)
+
+			err := flushRuleStats()
+			if err != nil {
+				fmt.Printf("flushRuleStats() error: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func resetRuleStats() {
+	ruleStatsLock.Lock()
+	ruleStatsMap = map[string]*ruleStats{}
+	ruleStatsLock.Unlock()
+}
+
+func TestAddRuleStats(t *testing.T) {
+	resetRuleStats()
+
+	recordRuleStats("admin/rule1", time.Millisecond, true)
+	recordRuleStats("admin/rule1", 2*time.Millisecond, false)
+	addRuleStats("admin/rule1", &ruleStats{evalCount: 3, hitCount: 1, evalTime: time.Millisecond, lastHitTime: "2000-01-01T00:00:00Z"})
+
+	stats := ruleStatsMap["admin/rule1"]
+	if stats.evalCount != 5 || stats.hitCount != 2 || stats.evalTime != 4*time.Millisecond {
+		t.Errorf("the stats = %+v, want 5 evaluations, 2 hits in 4ms", stats)
+	}
+	if stats.lastHitTime == "2000-01-01T00:00:00Z" {
+		t.Errorf("addRuleStats() overwrites the last hit time by an earlier one")
+	}
+}
+
+func TestRuleStats(t *testing.T) {
+	object.InitMemoryAdapter()
+	resetRuleStats()
+
+	addTestRule(t, "stats-post", "Method", &object.Expression{Operator: "is in", Value: "POST"})
+	addTestRule(t, "stats-get", "Method", &object.Expression{Operator: "is in", Value: "GET"})
+	addTestRule(t, "stats-compound", "Compound", &object.Expression{Operator: "expression", Value: `rule("admin/stats-post") && rule("admin/stats-get")`})
+
+	_, err := checkRules([]string{"admin/stats-compound"}, &object.Site{}, newTestRequest(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rules only referenced by the compound rule are counted too
+	for ruleId, wantHit := range map[string]int64{"admin/stats-compound": 0, "admin/stats-post": 1, "admin/stats-get": 0} {
+		stats, ok := ruleStatsMap[ruleId]
+		if !ok || stats.evalCount != 1 || stats.hitCount != wantHit {
+			t.Errorf("the stats of rule: %s = %+v, want 1 evaluation and %d hits", ruleId, stats, wantHit)
+		}
+	}
+
+	err = flushRuleStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ruleStatsMap) != 0 {
+		t.Errorf("flushRuleStats() keeps the saved stats")
+	}
+
+	rule, err := object.GetRule("admin/stats-post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.EvalCount != 1 || rule.HitCount != 1 || rule.LastHitTime == "" {
+		t.Errorf("the saved stats = %d evaluations, %d hits, last hit at: %s", rule.EvalCount, rule.HitCount, rule.LastHitTime)
+	}
+}
+
+func TestAddRuleWithStats(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	rule := &object.Rule{Owner: "admin", Name: "stats-forged", Type: "Method", Action: "Block", EvalCount: 100, HitCount: 100, LastHitTime: "2000-01-01T00:00:00Z"}
+	_, err := object.AddRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err = object.GetRule(rule.GetId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.EvalCount != 0 || rule.HitCount != 0 || rule.LastHitTime != "" {
+		t.Errorf("AddRule() keeps the stats sent by the client: %d, %d, %s", rule.EvalCount, rule.HitCount, rule.LastHitTime)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestIsTerminatingAction(t *testing.T) {
+	tests := map[string]bool{
+		"Allow":   true,
+		"Block":   true,
+		"Drop":    true,
+		"CAPTCHA": true,
+		"Log":     false,
+		"Tag":     false,
+		"Score":   false,
+	}
+
+	for action, want := range tests {
+		if got := isTerminatingAction(action); got != want {
+			t.Errorf("isTerminatingAction(%s) = %v, want %v", action, got, want)
+		}
+	}
+}
+
+func TestAddNonTerminatingResult(t *testing.T) {
+	res := &RuleResult{Tags: []string{}, HitRules: []string{}}
+	rules := []*object.Rule{
+		{Owner: "admin", Name: "log-rule", Action: "Log"},
+		{Owner: "admin", Name: "tag-rule", Action: "Tag", Reason: "scanner"},
+		{Owner: "admin", Name: "tag-rule-2", Action: "Tag"},
+		{Owner: "admin", Name: "score-rule", Action: "Score", Score: 3},
+		{Owner: "admin", Name: "score-rule-2", Action: "Score", Score: 4},
+	}
+	for _, rule := range rules {
+		addNonTerminatingResult(res, rule, &RuleResult{Action: rule.Action})
+	}
+
+	if res.Score != 7 {
+		t.Errorf("Score = %d, want 7", res.Score)
+	}
+	if want := []string{"scanner", "tag-rule-2"}; !reflect.DeepEqual(res.Tags, want) {
+		t.Errorf("Tags = %v, want %v", res.Tags, want)
+	}
+	if len(res.HitRules) != len(rules) {
+		t.Errorf("HitRules = %v, want %d rules", res.HitRules, len(rules))
+	}
+	if res.Action != "" {
+		t.Errorf("Action = %s, want empty", res.Action)
+	}
+}
+
+func TestApplyScoreThresholds(t *testing.T) {
+	site := &object.Site{CaptchaScore: 5, BlockScore: 10}
+	tests := []struct {
+		score      int
+		site       *object.Site
+		wantAction string
+	}{
+		{score: 0, site: site, wantAction: "Allow"},
+		{score: 4, site: site, wantAction: "Allow"},
+		{score: 5, site: site, wantAction: "CAPTCHA"},
+		{score: 9, site: site, wantAction: "CAPTCHA"},
+		{score: 10, site: site, wantAction: "Block"},
+		{score: 10, site: &object.Site{CaptchaScore: 5}, wantAction: "CAPTCHA"},
+		{score: 10, site: nil, wantAction: "Allow"},
+	}
+
+	for _, tt := range tests {
+		res := &RuleResult{Action: "Allow", StatusCode: 200, Score: tt.score}
+		applyScoreThresholds(res, tt.site)
+		if res.Action != tt.wantAction {
+			t.Errorf("applyScoreThresholds() score = %d, action = %s, want %s", tt.score, res.Action, tt.wantAction)
+		}
+	}
+}
+
+func TestCheckRulesWithInvalidExpressions(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	// the legacy rules saved before the operators and regexes are checked
+	addTestRule(t, "legacy-ua", "User-Agent", &object.Expression{Operator: "is abroad"}, &object.Expression{Operator: "contains", Value: "curl"})
+	addTestRule(t, "legacy-path", "Path", &object.Expression{Operator: "match", Value: "("})
+	addTestRule(t, "legacy-compound", "Compound", &object.Expression{Operator: "begin", Value: "admin/legacy-path"}, &object.Expression{Operator: "xor", Value: "admin/legacy-ua"})
+
+	tests := []struct {
+		ruleId string
+		want   bool
+	}{
+		{ruleId: "admin/legacy-ua", want: true},
+		{ruleId: "admin/legacy-path", want: false},
+		{ruleId: "admin/legacy-compound", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ruleId, func(t *testing.T) {
+			req := newTestRequest()
+			req.Header.Set("User-Agent", "curl/8.0")
+			result, err := checkRules([]string{tt.ruleId}, &object.Site{}, req, nil)
+			if err != nil {
+				t.Fatalf("checkRules() error = %v, want the invalid expressions skipped", err)
+			}
+			if got := len(result.HitRules) != 0; got != tt.want {
+				t.Errorf("checkRules() hit = %v, want %v", got, tt.want)
+			}
+
+			trace := TraceRules([]string{tt.ruleId}, &object.Site{}, req)
+			if trace.Error != "" {
+				t.Errorf("TraceRules() error = %s", trace.Error)
+			}
+		})
+	}
+}
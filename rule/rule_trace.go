@@ -0,0 +1,140 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"net/http"
+
+	"github.com/casbin/caswaf/object"
+)
+
+type ExpressionTrace struct {
+	Name     string `json:"name"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	IsHit    bool   `json:"isHit"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error"`
+}
+
+type WafMatchedRule struct {
+	Id       int    `json:"id"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Data     string `json:"data"`
+}
+
+type RuleTrace struct {
+	RuleId          string             `json:"ruleId"`
+	Type            string             `json:"type"`
+	Action          string             `json:"action"`
+	IsHit           bool               `json:"isHit"`
+	Expressions     []*ExpressionTrace `json:"expressions"`
+	WafMatchedRules []*WafMatchedRule  `json:"wafMatchedRules"`
+	NestedRules     []*RuleTrace       `json:"nestedRules"`
+	Result          *RuleResult        `json:"result"`
+	Error           string             `json:"error"`
+}
+
+type RulesTrace struct {
+	Rules  []*RuleTrace `json:"rules"`
+	Result *RuleResult  `json:"result"`
+	Error  string       `json:"error"`
+}
+
+func newRuleTrace(rule *object.Rule) *RuleTrace {
+	return &RuleTrace{
+		RuleId:          rule.GetId(),
+		Type:            rule.Type,
+		Action:          rule.Action,
+		Expressions:     []*ExpressionTrace{},
+		WafMatchedRules: []*WafMatchedRule{},
+		NestedRules:     []*RuleTrace{},
+	}
+}
+
+// TraceRules evaluates the rules against the request like CheckRules() in trace mode: the outcome of
+// every evaluated rule and expression is recorded, and no side effect is made on rate limiting or rule
+// statistics. An evaluation error is reported in the returned trace instead of being returned.
+// The score thresholds of site are applied if site is not nil.
+func TraceRules(ruleIds []string, site *object.Site, r *http.Request) *RulesTrace {
+	trace := &RulesTrace{Rules: []*RuleTrace{}}
+	result, err := checkRules(ruleIds, site, r, trace)
+	if err != nil {
+		trace.Error = err.Error()
+		return trace
+	}
+
+	trace.Result = result
+	return trace
+}
+
+// traceRule evaluates the expressions of a rule one by one to record the outcome of each expression,
+// rule types whose expressions are not independent of each other are evaluated as a whole
+func traceRule(ruleObj Rule, rule *object.Rule, r *http.Request, ruleTrace *RuleTrace) (*RuleResult, error) {
+	if object.IsWholeRuleType(rule.Type) {
+		var result *RuleResult
+		var err error
+		_, ok := getRuleExpressions(rule)
+		if ok {
+			result, err = ruleObj.checkRule(rule.Expressions, r)
+		}
+		for _, expression := range rule.Expressions {
+			expressionTrace := newExpressionTrace(expression)
+			expressionTrace.IsHit = result != nil
+			if err != nil {
+				expressionTrace.Error = err.Error()
+			}
+			ruleTrace.Expressions = append(ruleTrace.Expressions, expressionTrace)
+		}
+		return result, err
+	}
+
+	var res *RuleResult
+	for _, expression := range rule.Expressions {
+		expressionTrace := newExpressionTrace(expression)
+		ruleTrace.Expressions = append(ruleTrace.Expressions, expressionTrace)
+		if expression.GetError() != nil {
+			// skipped as the requests do, the error is set by newExpressionTrace()
+			continue
+		}
+
+		result, err := ruleObj.checkRule([]*object.Expression{expression}, r)
+		if err != nil {
+			expressionTrace.Error = err.Error()
+			return nil, err
+		}
+		if result != nil {
+			expressionTrace.IsHit = true
+			expressionTrace.Reason = result.Reason
+			if res == nil {
+				res = result
+			}
+		}
+	}
+	return res, nil
+}
+
+func newExpressionTrace(expression *object.Expression) *ExpressionTrace {
+	res := &ExpressionTrace{
+		Name:     expression.Name,
+		Operator: expression.Operator,
+		Value:    expression.Value,
+	}
+	if err := expression.GetError(); err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
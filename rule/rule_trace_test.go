@@ -0,0 +1,79 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestTraceRules(t *testing.T) {
+	object.InitMemoryAdapter()
+	resetRuleStats()
+
+	addTestRule(t, "trace-get", "Method", &object.Expression{Operator: "is in", Value: "GET"})
+	addTestRule(t, "trace-ua", "User-Agent", &object.Expression{Operator: "contains", Value: "curl"}, &object.Expression{Operator: "contains", Value: "wget"})
+	addTestRule(t, "trace-compound", "Compound", newRuleRef("admin/trace-get", "admin/trace-ua"))
+
+	req := newTestRequest()
+	req.Header.Set("User-Agent", "curl/8.0")
+	trace := TraceRules([]string{"admin/trace-get", "admin/trace-compound"}, &object.Site{}, req)
+	if trace.Error != "" {
+		t.Fatalf("TraceRules() error = %s", trace.Error)
+	}
+	if trace.Result == nil || trace.Result.Action != "Block" || len(trace.Result.HitRules) != 1 || trace.Result.HitRules[0] != "admin/trace-compound" {
+		t.Fatalf("TraceRules() result = %+v, want blocked by admin/trace-compound", trace.Result)
+	}
+	if len(trace.Rules) != 2 {
+		t.Fatalf("TraceRules() traces %d rules, want 2", len(trace.Rules))
+	}
+
+	// the missed rule
+	miss := trace.Rules[0]
+	if miss.RuleId != "admin/trace-get" || miss.IsHit || miss.Result != nil {
+		t.Errorf("the trace of admin/trace-get = %+v, want a miss", miss)
+	}
+	if len(miss.Expressions) != 1 || miss.Expressions[0].IsHit {
+		t.Errorf("the expressions of admin/trace-get = %v, want 1 missed expression", miss.Expressions)
+	}
+
+	// the compound rule with the steps of the referenced rules
+	compound := trace.Rules[1]
+	if compound.RuleId != "admin/trace-compound" || !compound.IsHit || compound.Result == nil {
+		t.Fatalf("the trace of admin/trace-compound = %+v, want a hit", compound)
+	}
+	if len(compound.Expressions) != 1 || !compound.Expressions[0].IsHit {
+		t.Errorf("the expressions of admin/trace-compound = %v, want 1 hit expression", compound.Expressions)
+	}
+	if len(compound.NestedRules) != 2 {
+		t.Fatalf("admin/trace-compound has %d nested rules, want 2", len(compound.NestedRules))
+	}
+	nestedMiss, nestedHit := compound.NestedRules[0], compound.NestedRules[1]
+	if nestedMiss.RuleId != "admin/trace-get" || nestedMiss.IsHit {
+		t.Errorf("the nested trace of admin/trace-get = %+v, want a miss", nestedMiss)
+	}
+	if nestedHit.RuleId != "admin/trace-ua" || !nestedHit.IsHit {
+		t.Errorf("the nested trace of admin/trace-ua = %+v, want a hit", nestedHit)
+	}
+	if len(nestedHit.Expressions) != 2 || !nestedHit.Expressions[0].IsHit || nestedHit.Expressions[1].IsHit {
+		t.Errorf("the expressions of admin/trace-ua = %v, want a hit and a miss", nestedHit.Expressions)
+	}
+
+	// tracing makes no side effect on the rule statistics
+	if len(ruleStatsMap) != 0 {
+		t.Errorf("TraceRules() records the stats of %d rules, want none", len(ruleStatsMap))
+	}
+}
@@ -17,8 +17,6 @@ package rule
 import (
 	"fmt"
 	"net/http"
-	"regexp"
-	"strings"
 
 	"github.com/casbin/caswaf/object"
 )
@@ -28,34 +26,13 @@ type UaRule struct{}
 func (r *UaRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
 	userAgent := req.UserAgent()
 	for _, expression := range expressions {
-		ua := expression.Value
-		reason := fmt.Sprintf("expression matched: \"%s %s %s\"", userAgent, expression.Operator, expression.Value)
-		switch expression.Operator {
-		case "contains":
-			if strings.Contains(userAgent, ua) {
-				return &RuleResult{Reason: reason}, nil
-			}
-		case "does not contain":
-			if !strings.Contains(userAgent, ua) {
-				return &RuleResult{Reason: reason}, nil
-			}
-		case "equals":
-			if userAgent == ua {
-				return &RuleResult{Reason: reason}, nil
-			}
-		case "does not equal":
-			if strings.Compare(userAgent, ua) != 0 {
-				return &RuleResult{Reason: reason}, nil
-			}
-		case "match":
-			// regex match
-			isHit, err := regexp.MatchString(ua, userAgent)
-			if err != nil {
-				return nil, err
-			}
-			if isHit {
-				return &RuleResult{Reason: reason}, nil
-			}
+		isHit, err := matchString(expression, userAgent, userAgent != "")
+		if err != nil {
+			return nil, err
+		}
+		if isHit {
+			reason := fmt.Sprintf("expression matched: \"%s %s %s\"", userAgent, expression.Operator, expression.Value)
+			return &RuleResult{Reason: reason}, nil
 		}
 	}
 
@@ -17,15 +17,23 @@ package rule
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/casbin/caswaf/conf"
 	"github.com/casbin/caswaf/object"
 	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/experimental/plugins/plugintypes"
 	"github.com/corazawaf/coraza/v3/types"
 	"github.com/hsluoyz/modsecurity-go/seclang/parser"
 )
 
-type WafRule struct{}
+type WafRule struct {
+	isScore bool
+	trace   *RuleTrace
+}
+
+// wafAnomalyScoreVars are the TX variables where the CRS accumulates the inbound anomaly score
+var wafAnomalyScoreVars = []string{"blocking_inbound_anomaly_score", "inbound_anomaly_score", "anomaly_score"}
 
 func (r *WafRule) checkRule(expressions []*object.Expression, req *http.Request) (*RuleResult, error) {
 	var ruleStr string
@@ -44,34 +52,105 @@ func (r *WafRule) checkRule(expressions []*object.Expression, req *http.Request)
 	tx := waf.NewTransaction()
 	processRequest(tx, req)
 	matchedRules := tx.MatchedRules()
+
+	if r.isScore {
+		score := getWafAnomalyScore(tx)
+		if score > 0 {
+			traceWafMatchedRules(r.trace, matchedRules)
+			return &RuleResult{
+				Score:  score,
+				Reason: fmt.Sprintf("WAF anomaly score: %d", score),
+			}, nil
+		}
+	}
+
+	traceWafMatchedRules(r.trace, matchedRules)
 	for _, matchedRule := range matchedRules {
-		rule := matchedRule.Rule()
-		directive, err := parser.NewSecLangScannerFromString(rule.Raw()).AllDirective()
+		res, err := getWafRuleResult(matchedRule.Rule())
 		if err != nil {
 			return nil, err
 		}
-		for _, d := range directive {
-			ruleDirective := d.(*parser.RuleDirective)
-			for _, action := range ruleDirective.Actions.Action {
-				switch action.Tk {
-				case parser.TkActionBlock, parser.TkActionDeny:
-					return &RuleResult{
-						Action: "Block",
-						Reason: fmt.Sprintf("blocked by WAF rule: %d", rule.ID()),
-					}, nil
-				case parser.TkActionAllow:
-					return &RuleResult{
-						Action: "Allow",
-					}, nil
-				case parser.TkActionDrop:
-					return &RuleResult{
-						Action: "Drop",
-						Reason: fmt.Sprintf("dropped by WAF rule: %d", rule.ID()),
-					}, nil
-				default:
-					// skip other actions
-					continue
-				}
+		if res != nil {
+			return res, nil
+		}
+	}
+	return nil, nil
+}
+
+func traceWafMatchedRules(trace *RuleTrace, matchedRules []types.MatchedRule) {
+	if trace == nil {
+		return
+	}
+
+	for _, matchedRule := range matchedRules {
+		rule := matchedRule.Rule()
+		trace.WafMatchedRules = append(trace.WafMatchedRules, &WafMatchedRule{
+			Id:       rule.ID(),
+			Severity: rule.Severity().String(),
+			Message:  matchedRule.Message(),
+			Data:     matchedRule.Data(),
+		})
+	}
+}
+
+// getWafAnomalyScore returns the anomaly score set by the CRS scoring rules in the transaction,
+// or 0 if the loaded rules do not use anomaly scoring
+func getWafAnomalyScore(tx types.Transaction) int {
+	for _, name := range wafAnomalyScoreVars {
+		score := getWafTxInt(tx, name)
+		if score > 0 {
+			return score
+		}
+	}
+	return 0
+}
+
+// getWafTxInt returns the integer value of the TX variable of the transaction, or 0 if it is not set
+func getWafTxInt(tx types.Transaction, name string) int {
+	txWithVars, ok := tx.(interface {
+		Variables() plugintypes.TransactionVariables
+	})
+	if !ok {
+		return 0
+	}
+
+	values := txWithVars.Variables().TX().Get(name)
+	if len(values) == 0 {
+		return 0
+	}
+	res, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0
+	}
+	return res
+}
+
+func getWafRuleResult(rule types.RuleMetadata) (*RuleResult, error) {
+	directive, err := parser.NewSecLangScannerFromString(rule.Raw()).AllDirective()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range directive {
+		ruleDirective := d.(*parser.RuleDirective)
+		for _, action := range ruleDirective.Actions.Action {
+			switch action.Tk {
+			case parser.TkActionBlock, parser.TkActionDeny:
+				return &RuleResult{
+					Action: "Block",
+					Reason: fmt.Sprintf("blocked by WAF rule: %d", rule.ID()),
+				}, nil
+			case parser.TkActionAllow:
+				return &RuleResult{
+					Action: "Allow",
+				}, nil
+			case parser.TkActionDrop:
+				return &RuleResult{
+					Action: "Drop",
+					Reason: fmt.Sprintf("dropped by WAF rule: %d", rule.ID()),
+				}, nil
+			default:
+				// skip other actions
+				continue
 			}
 		}
 	}
@@ -0,0 +1,58 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestWafRule_anomalyScore(t *testing.T) {
+	expressions := []*object.Expression{
+		{
+			Value: `SecRule REQUEST_URI "@contains attack" "id:1001,phase:1,pass,setvar:'tx.anomaly_score=+3'"
+SecRule REQUEST_URI "@contains evil" "id:1002,phase:1,pass,setvar:'tx.anomaly_score=+5'"`,
+		},
+	}
+
+	tests := []struct {
+		url       string
+		isScore   bool
+		wantHit   bool
+		wantScore int
+	}{
+		{url: "http://example.com/attack/evil", isScore: true, wantHit: true, wantScore: 8},
+		{url: "http://example.com/attack", isScore: true, wantHit: true, wantScore: 3},
+		{url: "http://example.com/index", isScore: true, wantHit: false},
+		{url: "http://example.com/attack/evil", isScore: false, wantHit: false},
+	}
+
+	for _, tt := range tests {
+		r := &WafRule{isScore: tt.isScore}
+		result, err := r.checkRule(expressions, httptest.NewRequest("GET", tt.url, nil))
+		if err != nil {
+			t.Fatalf("checkRule() error = %v", err)
+		}
+		if (result != nil) != tt.wantHit {
+			t.Errorf("checkRule(%s) hit = %v, want %v", tt.url, result != nil, tt.wantHit)
+			continue
+		}
+		if result != nil && result.Score != tt.wantScore {
+			t.Errorf("checkRule(%s) score = %d, want %d", tt.url, result.Score, tt.wantScore)
+		}
+	}
+}
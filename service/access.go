@@ -0,0 +1,78 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+type accessEnforcer struct {
+	policy   string
+	enforcer *casbin.SyncedEnforcer
+}
+
+// accessEnforcers caches the enforcer of each site by site ID until its access policy is changed
+var accessEnforcers sync.Map
+
+func getAccessEnforcer(site *object.Site) (*casbin.SyncedEnforcer, error) {
+	if v, ok := accessEnforcers.Load(site.GetId()); ok {
+		if e := v.(*accessEnforcer); e.policy == site.AccessPolicy {
+			return e.enforcer, nil
+		}
+	}
+
+	enforcer, err := object.NewAccessEnforcer(site.AccessPolicy)
+	if err != nil {
+		return nil, err
+	}
+	accessEnforcers.Store(site.GetId(), &accessEnforcer{policy: site.AccessPolicy, enforcer: enforcer})
+	return enforcer, nil
+}
+
+func getAccessSubjects(claims *casdoorsdk.Claims) []string {
+	if claims == nil {
+		return []string{"anonymous"}
+	}
+
+	res := []string{"authenticated", fmt.Sprintf("user:%s/%s", claims.Owner, claims.Name), fmt.Sprintf("org:%s", claims.Owner)}
+	for _, group := range claims.Groups {
+		res = append(res, fmt.Sprintf("group:%s", group))
+	}
+	for _, role := range getRoleNames(claims) {
+		res = append(res, fmt.Sprintf("role:%s", role))
+	}
+	return res
+}
+
+// isAccessAllowed checks the request against the access policy of the site, claims is nil for an
+// anonymous request. Without an access policy, only the authenticated requests are allowed.
+func isAccessAllowed(site *object.Site, claims *casdoorsdk.Claims, r *http.Request) (bool, error) {
+	if strings.TrimSpace(site.AccessPolicy) == "" {
+		return claims != nil, nil
+	}
+
+	enforcer, err := getAccessEnforcer(site)
+	if err != nil {
+		return false, err
+	}
+	return enforcer.Enforce(getAccessSubjects(claims), r.URL.Path, r.Method)
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+func TestIsAccessAllowed(t *testing.T) {
+	site := &object.Site{
+		Owner: "admin",
+		Name:  "site",
+		AccessPolicy: `p, *, /public/*, *, allow
+p, role:admin, /admin/*, *, allow
+p, *, /admin/*, *, deny
+p, group:built-in/ops, /ops/*, GET, allow
+p, user:built-in/bob, /ops/*, *, allow
+p, authenticated, /api/*, GET|POST, allow`,
+	}
+
+	admin := newTestClaims()
+	bob := &casdoorsdk.Claims{}
+	bob.Owner = "built-in"
+	bob.Name = "bob"
+
+	tests := []struct {
+		method string
+		path   string
+		claims *casdoorsdk.Claims
+		want   bool
+	}{
+		{"GET", "/public/index.html", nil, true},
+		{"GET", "/api/items", nil, false},
+		{"GET", "/api/items", bob, true},
+		{"DELETE", "/api/items", bob, false},
+		{"GET", "/admin/users", admin, true},
+		{"GET", "/admin/users", bob, false},
+		{"POST", "/ops/deploy", admin, false},
+		{"GET", "/ops/deploy", admin, true},
+		{"POST", "/ops/deploy", bob, true},
+		{"GET", "/other", admin, false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		got, err := isAccessAllowed(site, tt.claims, r)
+		if err != nil {
+			t.Fatalf("isAccessAllowed() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("isAccessAllowed(%s %s, %v) = %v, want %v", tt.method, tt.path, tt.claims != nil, got, tt.want)
+		}
+	}
+
+	site.AccessPolicy = ""
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	if got, _ := isAccessAllowed(site, nil, r); got {
+		t.Errorf("isAccessAllowed() allows an anonymous request without access policy")
+	}
+	if got, _ := isAccessAllowed(site, bob, r); !got {
+		t.Errorf("isAccessAllowed() denies an authenticated request without access policy")
+	}
+}
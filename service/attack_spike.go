@@ -0,0 +1,100 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/notifier"
+	"github.com/casbin/caswaf/object"
+)
+
+const (
+	attackSpikeWindow           = time.Minute
+	defaultAttackSpikeThreshold = 100
+)
+
+type attackCounter struct {
+	startTime time.Time
+	count     int
+}
+
+var (
+	attackCounterMap  = map[string]*attackCounter{}
+	attackCounterLock = &sync.Mutex{}
+)
+
+// getAttackSpikeThreshold returns the blocked requests of a site per minute to alert, 0 disables the alert
+func getAttackSpikeThreshold() int {
+	threshold, err := strconv.Atoi(conf.GetConfigString("attackSpikeThreshold"))
+	if err != nil || threshold < 0 {
+		return defaultAttackSpikeThreshold
+	}
+	return threshold
+}
+
+// countBlockedRequest counts a blocked request of the site in the current window, it returns true only
+// for the request reaching the threshold, so a window is alerted once
+func countBlockedRequest(siteId string, threshold int, now time.Time) (int, bool) {
+	attackCounterLock.Lock()
+	defer attackCounterLock.Unlock()
+
+	counter, ok := attackCounterMap[siteId]
+	if !ok || now.Sub(counter.startTime) >= attackSpikeWindow {
+		counter = &attackCounter{startTime: now}
+		attackCounterMap[siteId] = counter
+	}
+
+	counter.count++
+	return counter.count, counter.count == threshold
+}
+
+// recordBlockedRequest alerts the site when its blocked or dropped requests reach attackSpikeThreshold
+// in a minute, the repeated alerts of a lasting attack are deduplicated by the notifier
+func recordBlockedRequest(site *object.Site, reason string) {
+	if !site.EnableAlert || len(site.AlertProviders) == 0 {
+		return
+	}
+
+	threshold := getAttackSpikeThreshold()
+	if threshold == 0 {
+		return
+	}
+
+	count, ok := countBlockedRequest(site.GetId(), threshold, time.Now())
+	if !ok {
+		return
+	}
+
+	go func() {
+		err := object.SendSiteAlert(site, &notifier.Event{
+			Type:    notifier.EventAttackSpike,
+			Subject: site.Domain,
+			Message: fmt.Sprintf("%d requests to site: %s have been blocked in %s, the last reason: %s", count, site.GetId(), attackSpikeWindow, reason),
+			Data: map[string]string{
+				"count":  strconv.Itoa(count),
+				"window": attackSpikeWindow.String(),
+				"reason": reason,
+			},
+		})
+		if err != nil {
+			fmt.Printf("recordBlockedRequest() error: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casbin/caswaf/object"
+)
+
+func TestCountBlockedRequest(t *testing.T) {
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		count, ok := countBlockedRequest("admin/spike", 3, now.Add(time.Duration(i)*time.Second))
+		if count != i || ok != (i == 3) {
+			t.Errorf("countBlockedRequest() #%d = %d, %v", i, count, ok)
+		}
+	}
+
+	count, ok := countBlockedRequest("admin/spike", 3, now.Add(attackSpikeWindow+time.Second))
+	if count != 1 || ok {
+		t.Errorf("countBlockedRequest() = %d, %v, want a new window", count, ok)
+	}
+
+	if count, _ = countBlockedRequest("admin/other", 3, now); count != 1 {
+		t.Errorf("countBlockedRequest() counts another site: %d", count)
+	}
+
+	t.Setenv("attackSpikeThreshold", "x")
+	if got := getAttackSpikeThreshold(); got != defaultAttackSpikeThreshold {
+		t.Errorf("getAttackSpikeThreshold() = %d, want %d", got, defaultAttackSpikeThreshold)
+	}
+	t.Setenv("attackSpikeThreshold", "0")
+	if got := getAttackSpikeThreshold(); got != 0 {
+		t.Errorf("getAttackSpikeThreshold() = %d, want 0", got)
+	}
+}
+
+func TestRecordBlockedRequest(t *testing.T) {
+	// the blocked requests of a site with the alerts disabled are not counted
+	site := &object.Site{Owner: "admin", Name: "spike-disabled", AlertProviders: []string{"HTTP/http://127.0.0.1:1"}}
+	recordBlockedRequest(site, "WAF")
+
+	attackCounterLock.Lock()
+	_, ok := attackCounterMap[site.GetId()]
+	attackCounterLock.Unlock()
+	if ok {
+		t.Errorf("recordBlockedRequest() counts a site with the alerts disabled")
+	}
+}
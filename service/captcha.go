@@ -21,11 +21,15 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
 	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
-	"github.com/google/uuid"
 )
 
 type verifyResponse struct {
@@ -37,9 +41,30 @@ type verifyResponse struct {
 	Data2  interface{} `json:"data2"`
 }
 
-var verifiedSession = make(map[string]time.Time)
+const (
+	captchaCookieName        = "casdoor_captcha_token"
+	captchaPendingCookieName = "caswaf_captcha_pending"
+)
+
+// redirectToCaptcha redirects the client to the Casdoor CAPTCHA page, the clearance to grant and
+// the path to return to after the CAPTCHA is verified are kept in a short-lived signed cookie
+func redirectToCaptcha(w http.ResponseWriter, r *http.Request, c *clearance, redirect string) {
+	values := url.Values{}
+	values.Set("binding", c.binding)
+	values.Set("ttl", strconv.Itoa(int(c.ttl.Seconds())))
+	values.Set("redirect", getSafeRedirect(redirect))
+	values.Set("expireTime", strconv.FormatInt(time.Now().Add(challengeTtl).Unix(), 10))
+	values.Set("signature", util.GetHmacSha256(getClearanceSecret(), getCaptchaPendingMessage(c.site, values)))
+	http.SetCookie(w, &http.Cookie{
+		Name:     captchaPendingCookieName,
+		Value:    values.Encode(),
+		Path:     "/caswaf-captcha-verify",
+		MaxAge:   int(challengeTtl.Seconds()),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
 
-func redirectToCaptcha(w http.ResponseWriter, r *http.Request) {
 	scheme := getScheme(r)
 	callbackUrl := fmt.Sprintf("%s://%s/caswaf-captcha-verify", scheme, r.Host)
 	captchaUri := fmt.Sprintf(
@@ -52,15 +77,39 @@ func redirectToCaptcha(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, captchaUri, http.StatusFound)
 }
 
+func getCaptchaPendingMessage(site string, values url.Values) string {
+	return strings.Join([]string{"captcha", site, values.Get("binding"), values.Get("ttl"), values.Get("redirect"), values.Get("expireTime")}, "|")
+}
+
+// getCaptchaPending returns the clearance and the redirect path kept by redirectToCaptcha(),
+// a default clearance is returned if the cookie is missing or invalid
+func getCaptchaPending(r *http.Request, site string) (*clearance, string) {
+	cookie, err := r.Cookie(captchaPendingCookieName)
+	if err == nil {
+		values, err := url.ParseQuery(cookie.Value)
+		if err == nil && isUnexpired(values.Get("expireTime")) &&
+			util.IsHmacSha256Valid(getClearanceSecret(), getCaptchaPendingMessage(site, values), values.Get("signature")) {
+			return newClearance("captcha", site, values.Get("binding"), util.ParseInt(values.Get("ttl"))), getSafeRedirect(values.Get("redirect"))
+		}
+	}
+	return newClearance("captcha", site, "", 0), "/"
+}
+
 func handleCaptchaCallback(w http.ResponseWriter, r *http.Request) {
-	host := r.Host
+	site := getSiteByDomainWithWww(r.Host)
+	if site == nil {
+		responseError(w, "CasWAF error: site not found for host: %s", r.Host)
+		return
+	}
+	c, redirect := getCaptchaPending(r, site.GetId())
 
 	code := r.URL.Query().Get("code")
 	typeStr := r.URL.Query().Get("type")
 	secret := r.URL.Query().Get("secret")
 	applicationId := r.URL.Query().Get("applicationId")
 	if code == "" || typeStr == "" || secret == "" || applicationId == "" {
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
 
 	var b bytes.Buffer
@@ -73,67 +122,41 @@ func handleCaptchaCallback(w http.ResponseWriter, r *http.Request) {
 	verifyURL := casdoorsdk.GetUrl("verify-captcha", nil)
 	req, err := http.NewRequest("POST", verifyURL, &b)
 	if err != nil {
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
+	defer resp.Body.Close()
 	// read response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
 	// parse response
 	var vr verifyResponse
 	err = json.Unmarshal(body, &vr)
 	if err != nil {
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
 	if vr.Status != "ok" || !vr.Data {
-		redirectToCaptcha(w, r)
-	}
-	// set verified session
-	uuidStr := uuid.NewString()
-	verifiedSession[uuidStr] = time.Now()
-	cookie := &http.Cookie{
-		Name:       "casdoor_captcha_token",
-		Value:      uuidStr,
-		Path:       "/",
-		Domain:     host,
-		Expires:    time.Now().Add(30 * time.Minute),
-		RawExpires: "",
-		MaxAge:     0,
-		Secure:     false,
-		HttpOnly:   false,
-		SameSite:   0,
-		Raw:        "",
-		Unparsed:   nil,
+		redirectToCaptcha(w, r, c, redirect)
+		return
 	}
 
-	scheme := getScheme(r)
-	http.SetCookie(w, cookie)
-	http.Redirect(w, r, scheme+"://"+host, http.StatusFound)
-	return
+	// set the signed clearance token
+	c.setCookie(w, r, captchaCookieName)
+	http.SetCookie(w, &http.Cookie{Name: captchaPendingCookieName, Path: "/caswaf-captcha-verify", MaxAge: -1})
+	http.Redirect(w, r, redirect, http.StatusFound)
 }
 
-func isVerifiedSession(r *http.Request) bool {
-	cookie, err := r.Cookie("casdoor_captcha_token")
-	if err != nil {
-		return false
-	}
-	token := cookie.Value
-	if token == "" {
-		return false
-	}
-	t, ok := verifiedSession[token]
-	if ok {
-		if time.Now().Sub(t) < 30*time.Minute {
-			return true
-		}
-		delete(verifiedSession, token)
-	}
-	return false
+func isVerifiedSession(r *http.Request, site *object.Site) bool {
+	return hasClearance(r, captchaCookieName, "captcha", site.GetId())
 }
@@ -0,0 +1,85 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/object"
+)
+
+var (
+	selfSignedCert     *tls.Certificate
+	selfSignedCertErr  error
+	selfSignedCertOnce sync.Once
+)
+
+// getDefaultX509Cert returns the certificate for the SNI without a cert, which is the cert configured
+// by defaultCert, or a self-signed certificate so the handshake does not fail
+func getDefaultX509Cert() (*tls.Certificate, error) {
+	name := conf.GetConfigString("defaultCert")
+	if name != "" {
+		cert := object.GetCertByName(name)
+		if cert != nil && cert.Type != "Client CA" {
+			return object.GetTlsCert(cert)
+		}
+	}
+
+	selfSignedCertOnce.Do(func() {
+		selfSignedCert, selfSignedCertErr = newSelfSignedCert("CasWAF Default Certificate")
+	})
+	return selfSignedCert, selfSignedCertErr
+}
+
+func newSelfSignedCert(commonName string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
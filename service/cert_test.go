@@ -0,0 +1,37 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestGetX509CertByDomainFallback(t *testing.T) {
+	for _, domain := range []string{"unknown.example.com", ""} {
+		cert, err := getX509CertByDomain(domain, nil)
+		if err != nil {
+			t.Fatalf("getX509CertByDomain(%q) error = %v", domain, err)
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaf.Subject.CommonName != "CasWAF Default Certificate" {
+			t.Errorf("getX509CertByDomain(%q) = %s, want the default certificate", domain, leaf.Subject.CommonName)
+		}
+	}
+}
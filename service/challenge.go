@@ -0,0 +1,164 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+)
+
+const (
+	clearanceCookieName = "caswaf_clearance"
+	challengeTtl        = 5 * time.Minute
+
+	defaultChallengeDifficulty = 16
+)
+
+//go:embed challenge.html
+var challengeHtml string
+
+var challengeTemplate = template.Must(template.New("challenge").Parse(challengeHtml))
+
+func getChallengeDifficulty() int {
+	difficulty, err := strconv.Atoi(conf.GetConfigString("challengeDifficulty"))
+	if err != nil || difficulty <= 0 || difficulty > 32 {
+		return defaultChallengeDifficulty
+	}
+	return difficulty
+}
+
+// newChallenge returns a challenge of the format: "expireTime.salt.binding.ttl.signature", which is
+// bound to the client IP and carries the clearance to grant after the challenge is solved
+func newChallenge(c *clearance, clientIp string) string {
+	expireTime := strconv.FormatInt(time.Now().Add(challengeTtl).Unix(), 10)
+	salt := util.GetRandomHexString(8)
+	ttl := strconv.Itoa(int(c.ttl.Seconds()))
+	signature := util.GetHmacSha256(getClearanceSecret(), strings.Join([]string{"challenge", c.site, clientIp, expireTime, salt, c.binding, ttl}, "|"))
+	return strings.Join([]string{expireTime, salt, c.binding, ttl, signature}, ".")
+}
+
+// parseChallenge returns the clearance carried by the challenge, or nil if the challenge is invalid
+func parseChallenge(challenge string, site string, clientIp string) *clearance {
+	tokens := strings.Split(challenge, ".")
+	if len(tokens) != 5 || !isUnexpired(tokens[0]) {
+		return nil
+	}
+
+	message := strings.Join([]string{"challenge", site, clientIp, tokens[0], tokens[1], tokens[2], tokens[3]}, "|")
+	if !util.IsHmacSha256Valid(getClearanceSecret(), message, tokens[4]) {
+		return nil
+	}
+	return newClearance("challenge", site, tokens[2], util.ParseInt(tokens[3]))
+}
+
+// isProofOfWorkValid checks that the SHA-256 hash of "challenge:nonce" has difficulty leading zero bits
+func isProofOfWorkValid(challenge string, nonce string, difficulty int) bool {
+	if _, err := strconv.ParseUint(nonce, 10, 64); err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(challenge + ":" + nonce))
+	zeroBits := 0
+	for _, b := range hash {
+		zeroBits += bits.LeadingZeros8(b)
+		if b != 0 || zeroBits >= difficulty {
+			break
+		}
+	}
+	return zeroBits >= difficulty
+}
+
+func isClearedByChallenge(r *http.Request, site *object.Site) bool {
+	return hasClearance(r, clearanceCookieName, "challenge", site.GetId())
+}
+
+// getSafeRedirect only allows redirecting to a path on the same host after the challenge, the control
+// characters and backslashes are rejected as browsers strip or normalize them, e.g., "/\t/evil.com" is
+// followed as "//evil.com"
+func getSafeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		return "/"
+	}
+
+	for _, c := range redirect {
+		if c < 0x20 || c == 0x7f || c == '\\' {
+			return "/"
+		}
+	}
+
+	u, err := url.Parse(redirect)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+	return redirect
+}
+
+func serveJsChallenge(w http.ResponseWriter, r *http.Request, c *clearance, statusCode int, redirect string) {
+	data := struct {
+		Challenge  string
+		Difficulty int
+		Redirect   string
+	}{
+		Challenge:  newChallenge(c, util.GetClientIp(r)),
+		Difficulty: getChallengeDifficulty(),
+		Redirect:   getSafeRedirect(redirect),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	err := challengeTemplate.Execute(w, data)
+	if err != nil {
+		fmt.Printf("serveJsChallenge() error: %v\n", err)
+	}
+}
+
+func handleChallengeCallback(w http.ResponseWriter, r *http.Request) {
+	site := getSiteByDomainWithWww(r.Host)
+	if site == nil {
+		responseError(w, "CasWAF error: site not found for host: %s", r.Host)
+		return
+	}
+
+	challenge := r.URL.Query().Get("challenge")
+	nonce := r.URL.Query().Get("nonce")
+	redirect := getSafeRedirect(r.URL.Query().Get("redirect"))
+
+	c := parseChallenge(challenge, site.GetId(), util.GetClientIp(r))
+	if c == nil {
+		serveJsChallenge(w, r, newClearance("challenge", site.GetId(), "", 0), http.StatusForbidden, redirect)
+		return
+	}
+	// the challenge served again keeps the clearance binding and TTL of the rule signed in it
+	if !isProofOfWorkValid(challenge, nonce, getChallengeDifficulty()) {
+		serveJsChallenge(w, r, c, http.StatusForbidden, redirect)
+		return
+	}
+
+	c.setCookie(w, r, clearanceCookieName)
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
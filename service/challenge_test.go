@@ -0,0 +1,112 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+func TestIsProofOfWorkValid(t *testing.T) {
+	// the nonce found by the script of challenge.html for difficulty 12
+	if !isProofOfWorkValid("123.abc.def", "5615", 12) {
+		t.Errorf("isProofOfWorkValid() = false, want true")
+	}
+	if isProofOfWorkValid("123.abc.def", "5616", 12) {
+		t.Errorf("isProofOfWorkValid() = true, want false")
+	}
+	if isProofOfWorkValid("123.abc.def", "-1", 0) {
+		t.Errorf("isProofOfWorkValid() accepts an invalid nonce")
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	c := newClearance("challenge", "admin/site", "", 600)
+	challenge := newChallenge(c, "1.2.3.4")
+
+	got := parseChallenge(challenge, "admin/site", "1.2.3.4")
+	if got == nil {
+		t.Fatalf("parseChallenge() = nil, want the clearance")
+	}
+	if got.binding != "IP" || got.ttl != c.ttl {
+		t.Errorf("parseChallenge() binding = %s, ttl = %v, want IP, %v", got.binding, got.ttl, c.ttl)
+	}
+	if parseChallenge(challenge, "admin/site", "1.2.3.5") != nil {
+		t.Errorf("parseChallenge() accepts another client IP")
+	}
+	if parseChallenge(challenge, "admin/site2", "1.2.3.4") != nil {
+		t.Errorf("parseChallenge() accepts another site")
+	}
+	if parseChallenge(strings.Replace(challenge, ".600.", ".86400.", 1), "admin/site", "1.2.3.4") != nil {
+		t.Errorf("parseChallenge() accepts a tampered TTL")
+	}
+}
+
+func TestChallengeCallbackFailure(t *testing.T) {
+	object.InitMemoryAdapter()
+	casdoorsdk.InitConfig("http://127.0.0.1:1", "", "", "", "", "")
+
+	site := &object.Site{Owner: "admin", Name: "challenge", Domain: "challenge.example.com"}
+	_, err := object.AddSite(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed solution is served a challenge with the binding and TTL of the rule
+	challenge := newChallenge(newClearance("challenge", site.GetId(), "User-Agent", 3600), "1.2.3.4")
+	query := url.Values{"challenge": {challenge}, "nonce": {"-1"}, "redirect": {"/"}}
+	r := httptest.NewRequest("GET", "http://challenge.example.com/?"+query.Encode(), nil)
+	r.RemoteAddr = "1.2.3.4:1234"
+	w := httptest.NewRecorder()
+	handleChallengeCallback(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("handleChallengeCallback() status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	match := regexp.MustCompile(`var challenge = "([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatalf("handleChallengeCallback() does not serve a challenge: %s", w.Body.String())
+	}
+	got := parseChallenge(match[1], site.GetId(), "1.2.3.4")
+	if got == nil || got.binding != "User-Agent" || got.ttl.Seconds() != 3600 {
+		t.Errorf("handleChallengeCallback() serves the clearance %+v, want User-Agent, 3600s", got)
+	}
+}
+
+func TestGetSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/api/search?q=1":     "/api/search?q=1",
+		"":                    "/",
+		"https://example.org": "/",
+		"//example.org":       "/",
+		"/\\example.org":      "/",
+		"/\t/evil.com":        "/",
+		"/\r\n/evil.com":      "/",
+		"/a\\b":               "/",
+	}
+
+	for redirect, want := range tests {
+		if got := getSafeRedirect(redirect); got != want {
+			t.Errorf("getSafeRedirect(%s) = %s, want %s", redirect, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/conf"
+	"github.com/casbin/caswaf/util"
+)
+
+const defaultClearanceTtl = 30 * time.Minute
+
+var (
+	clearanceSecret     []byte
+	clearanceSecretOnce sync.Once
+)
+
+// clearance is the permission granted to a client after passing a challenge, which is kept by
+// the client as a signed token, so it is valid on every node that shares the same secret
+type clearance struct {
+	kind    string
+	site    string
+	binding string
+	ttl     time.Duration
+}
+
+// getClearanceSecret returns the key to sign challenges and clearance tokens. It should be
+// configured by challengeSecret to be shared by all nodes, otherwise a random key is generated
+// and the clearance tokens are only valid on this node until restart.
+func getClearanceSecret() []byte {
+	clearanceSecretOnce.Do(func() {
+		secret := conf.GetConfigString("challengeSecret")
+		if secret == "" {
+			fmt.Printf("getClearanceSecret(): challengeSecret is not configured, a random secret is used\n")
+			secret = util.GetRandomHexString(32)
+		}
+		clearanceSecret = []byte(secret)
+	})
+	return clearanceSecret
+}
+
+func newClearance(kind string, site string, binding string, ttlSeconds int) *clearance {
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultClearanceTtl
+	}
+	if binding != "User-Agent" {
+		binding = "IP"
+	}
+
+	return &clearance{
+		kind:    kind,
+		site:    site,
+		binding: binding,
+		ttl:     ttl,
+	}
+}
+
+// getBindValue returns the client IP, or the hash of the user agent with the "User-Agent" binding
+// for clients whose IP changes frequently, e.g., mobile networks
+func (c *clearance) getBindValue(r *http.Request) string {
+	if c.binding == "User-Agent" {
+		hash := sha256.Sum256([]byte(r.UserAgent()))
+		return hex.EncodeToString(hash[:])
+	}
+	return util.GetClientIp(r)
+}
+
+func (c *clearance) getMessage(r *http.Request, expireTime string) string {
+	return strings.Join([]string{"clearance", c.kind, c.site, c.binding, c.getBindValue(r), expireTime}, "|")
+}
+
+// newToken returns a token of the format: "binding.expireTime.signature"
+func (c *clearance) newToken(r *http.Request) string {
+	expireTime := strconv.FormatInt(time.Now().Add(c.ttl).Unix(), 10)
+	signature := util.GetHmacSha256(getClearanceSecret(), c.getMessage(r, expireTime))
+	return fmt.Sprintf("%s.%s.%s", c.binding, expireTime, signature)
+}
+
+func isClearanceTokenValid(token string, kind string, site string, r *http.Request) bool {
+	tokens := strings.Split(token, ".")
+	if len(tokens) != 3 || !isUnexpired(tokens[1]) {
+		return false
+	}
+
+	c := newClearance(kind, site, tokens[0], 0)
+	if c.binding != tokens[0] {
+		return false
+	}
+	return util.IsHmacSha256Valid(getClearanceSecret(), c.getMessage(r, tokens[1]), tokens[2])
+}
+
+func hasClearance(r *http.Request, cookieName string, kind string, site string) bool {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return isClearanceTokenValid(cookie.Value, kind, site, r)
+}
+
+func (c *clearance) setCookie(w http.ResponseWriter, r *http.Request, cookieName string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    c.newToken(r),
+		Path:     "/",
+		MaxAge:   int(c.ttl.Seconds()),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func isUnexpired(expireTime string) bool {
+	t, err := strconv.ParseInt(expireTime, 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Now().Unix() < t
+}
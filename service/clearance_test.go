@@ -0,0 +1,68 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClearanceToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = "1.2.3.4:1234"
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+
+	otherIp := httptest.NewRequest("GET", "http://example.com/", nil)
+	otherIp.RemoteAddr = "1.2.3.5:1234"
+	otherIp.Header.Set("User-Agent", "Mozilla/5.0")
+
+	otherUa := httptest.NewRequest("GET", "http://example.com/", nil)
+	otherUa.RemoteAddr = "1.2.3.4:1234"
+	otherUa.Header.Set("User-Agent", "curl/8.0")
+
+	ipToken := newClearance("captcha", "admin/site", "IP", 60).newToken(r)
+	uaToken := newClearance("captcha", "admin/site", "User-Agent", 60).newToken(r)
+
+	if !isClearanceTokenValid(ipToken, "captcha", "admin/site", r) {
+		t.Errorf("the IP bound token should be valid")
+	}
+	if isClearanceTokenValid(ipToken, "captcha", "admin/site", otherIp) {
+		t.Errorf("the IP bound token should be invalid for another IP")
+	}
+	if !isClearanceTokenValid(ipToken, "captcha", "admin/site", otherUa) {
+		t.Errorf("the IP bound token should be valid for another user agent")
+	}
+	if isClearanceTokenValid(ipToken, "challenge", "admin/site", r) {
+		t.Errorf("the token should be invalid for another kind")
+	}
+	if isClearanceTokenValid(ipToken, "captcha", "admin/site2", r) {
+		t.Errorf("the token should be invalid for another site")
+	}
+
+	if !isClearanceTokenValid(uaToken, "captcha", "admin/site", otherIp) {
+		t.Errorf("the user agent bound token should be valid for another IP")
+	}
+	if isClearanceTokenValid(uaToken, "captcha", "admin/site", otherUa) {
+		t.Errorf("the user agent bound token should be invalid for another user agent")
+	}
+
+	defaultTtlToken := newClearance("captcha", "admin/site", "IP", -60).newToken(r)
+	if !isClearanceTokenValid(defaultTtlToken, "captcha", "admin/site", r) {
+		t.Errorf("a non-positive TTL should fall back to the default TTL")
+	}
+	if isClearanceTokenValid("IP.1.abc", "captcha", "admin/site", r) {
+		t.Errorf("an expired token should be invalid")
+	}
+}
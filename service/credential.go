@@ -0,0 +1,252 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultApiKeyHeader = "X-Api-Key"
+
+	// basicAuthCacheTtl is how long a verified password skips the bcrypt comparison, which is
+	// too slow to be done for every request
+	basicAuthCacheTtl = 5 * time.Minute
+)
+
+type credentialLimiter struct {
+	rateLimit int
+	limiter   *rate.Limiter
+}
+
+var (
+	basicAuthCache     sync.Map
+	credentialLimiters sync.Map
+
+	credentialUsageMap  = map[string]int64{}
+	credentialUsageLock = &sync.Mutex{}
+
+	// dummyPasswordHash is compared for an unknown username, so that it costs the same bcrypt
+	// comparison as a known one and the usernames of a site can't be found by timing
+	dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("caswaf"), bcrypt.DefaultCost)
+)
+
+func isPasswordValid(credential *object.Credential, password string) bool {
+	key := util.GetSha256Hash(credential.GetId() + "|" + credential.Secret + "|" + password)
+	if v, ok := basicAuthCache.Load(key); ok && time.Now().Before(v.(time.Time)) {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(credential.Secret), []byte(password)) != nil {
+		return false
+	}
+	basicAuthCache.Store(key, time.Now().Add(basicAuthCacheTtl))
+	return true
+}
+
+type credentialContextKey struct{}
+
+func getApiKeyHeader(site *object.Site) string {
+	if site.ApiKeyHeader == "" {
+		return defaultApiKeyHeader
+	}
+	return site.ApiKeyHeader
+}
+
+func getApiKey(site *object.Site, r *http.Request) string {
+	if key := r.Header.Get(getApiKeyHeader(site)); key != "" {
+		return key
+	}
+
+	if site.ApiKeyParam != "" {
+		return r.URL.Query().Get(site.ApiKeyParam)
+	}
+	return ""
+}
+
+// authenticateCredential returns the credential of the site matching the request, or nil if the
+// request has no valid credential
+func authenticateCredential(site *object.Site, r *http.Request) *object.Credential {
+	switch site.AuthMode {
+	case "Basic Auth":
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return nil
+		}
+		found := false
+		for _, credential := range object.GetSiteCredentials(site) {
+			if credential.Type != "Basic Auth" || credential.Username != username {
+				continue
+			}
+			found = true
+			if isPasswordValid(credential, password) {
+				return credential
+			}
+		}
+		if !found {
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		}
+	case "API Key":
+		key := getApiKey(site, r)
+		if key == "" {
+			return nil
+		}
+		hash := util.GetSha256Hash(key)
+		for _, credential := range object.GetSiteCredentials(site) {
+			if credential.Type == "API Key" && subtle.ConstantTimeCompare([]byte(credential.Secret), []byte(hash)) == 1 {
+				return credential
+			}
+		}
+	}
+	return nil
+}
+
+// isCredentialRateLimited checks the rate limit of the credential in requests per second
+func isCredentialRateLimited(credential *object.Credential) bool {
+	if credential.RateLimit <= 0 {
+		return false
+	}
+
+	v, ok := credentialLimiters.Load(credential.GetId())
+	if !ok || v.(*credentialLimiter).rateLimit != credential.RateLimit {
+		v = &credentialLimiter{
+			rateLimit: credential.RateLimit,
+			limiter:   rate.NewLimiter(rate.Limit(credential.RateLimit), credential.RateLimit),
+		}
+		credentialLimiters.Store(credential.GetId(), v)
+	}
+	return !v.(*credentialLimiter).limiter.Allow()
+}
+
+func responseUnauthorized(w http.ResponseWriter, site *object.Site) {
+	if site.AuthMode == "Basic Auth" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", site.Domain))
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	responseErrorWithoutCode(w, "CasWAF error: the credential is missing or invalid")
+}
+
+// checkCredential authenticates the request of a site protected by Basic Auth or API keys, it
+// writes the response and returns false if the request is rejected, the credential is recorded to
+// the record before it is added, and stripped by the Director before the request is forwarded
+func checkCredential(w http.ResponseWriter, r *http.Request, site *object.Site, record *object.Record) (*http.Request, bool) {
+	credential := authenticateCredential(site, r)
+	if credential == nil {
+		responseUnauthorized(w, site)
+		return r, false
+	}
+
+	recordCredentialUsage(credential.GetId())
+	if record != nil {
+		record.Credential = credential.GetId()
+	}
+
+	if isCredentialRateLimited(credential) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		responseErrorWithoutCode(w, "CasWAF error: the rate limit of credential: %s is exceeded", credential.Name)
+		return r, false
+	}
+	return r.WithContext(context.WithValue(r.Context(), credentialContextKey{}, site)), true
+}
+
+// stripCredential strips the password or API key of the request authenticated by checkCredential(),
+// which is managed by CasWAF and must not be seen by the upstream
+func stripCredential(r *http.Request) {
+	site, ok := r.Context().Value(credentialContextKey{}).(*object.Site)
+	if !ok {
+		return
+	}
+
+	switch site.AuthMode {
+	case "Basic Auth":
+		r.Header.Del("Authorization")
+	case "API Key":
+		r.Header.Del(getApiKeyHeader(site))
+		if site.ApiKeyParam == "" {
+			return
+		}
+
+		query := r.URL.Query()
+		if _, ok = query[site.ApiKeyParam]; ok {
+			query.Del(site.ApiKeyParam)
+			u := *r.URL
+			u.RawQuery = query.Encode()
+			r.URL = &u
+		}
+	}
+}
+
+func recordCredentialUsage(credentialId string) {
+	credentialUsageLock.Lock()
+	defer credentialUsageLock.Unlock()
+
+	credentialUsageMap[credentialId]++
+}
+
+func flushCredentialUsage() error {
+	credentialUsageLock.Lock()
+	usageMap := credentialUsageMap
+	credentialUsageMap = map[string]int64{}
+	credentialUsageLock.Unlock()
+
+	var res error
+	lastUsedTime := util.GetCurrentTime()
+	for credentialId, usageCount := range usageMap {
+		err := object.AddCredentialUsage(credentialId, usageCount, lastUsedTime)
+		if err != nil {
+			// the usage failed to save is flushed again with the next one
+			credentialUsageLock.Lock()
+			credentialUsageMap[credentialId] += usageCount
+			credentialUsageLock.Unlock()
+
+			if res == nil {
+				res = fmt.Errorf("credential: %s, %v", credentialId, err)
+			}
+		}
+	}
+
+	return res
+}
+
+func StartFlushCredentialUsageLoop() {
+	fmt.Printf("StartFlushCredentialUsageLoop() Start!\n\n")
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("[%s] Recovered from StartFlushCredentialUsageLoop() panic: %v\n", util.GetCurrentTime(), r)
+				StartFlushCredentialUsageLoop()
+			}
+		}()
+
+		for {
+			time.Sleep(10 * time.Second)
+
+			err := flushCredentialUsage()
+			if err != nil {
+				fmt.Printf("flushCredentialUsage() error: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestIsPasswordValid(t *testing.T) {
+	hash, err := util.GetPasswordHash("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := &object.Credential{Owner: "admin", Name: "alice", Type: "Basic Auth", Username: "alice", Secret: hash}
+	for i := 0; i < 2; i++ {
+		if !isPasswordValid(credential, "123456") {
+			t.Errorf("isPasswordValid() rejects the password")
+		}
+		if isPasswordValid(credential, "1234567") {
+			t.Errorf("isPasswordValid() accepts a wrong password")
+		}
+	}
+}
+
+func TestAuthenticateBasicAuth(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	hash, err := util.GetPasswordHash("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the unknown usernames are compared with a hash as costly as the one of a credential
+	if cost, err := bcrypt.Cost(dummyPasswordHash); err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("bcrypt.Cost() = %d, %v, want %d", cost, err, bcrypt.DefaultCost)
+	}
+
+	site := &object.Site{Owner: "admin", Name: "basic-auth", AuthMode: "Basic Auth"}
+	credential := &object.Credential{Owner: "admin", Name: "bob", Site: site.Name, Type: "Basic Auth", Username: "bob", Secret: hash, IsEnabled: true}
+	_, err = object.AddCredential(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username string
+		password string
+		want     bool
+	}{
+		{"bob", "123456", true},
+		{"bob", "1234567", false},
+		{"carol", "123456", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://example.com/", nil)
+		r.SetBasicAuth(tt.username, tt.password)
+		if got := authenticateCredential(site, r); (got != nil) != tt.want {
+			t.Errorf("authenticateCredential(%s, %s) = %v, want %v", tt.username, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestGetApiKey(t *testing.T) {
+	site := &object.Site{Owner: "admin", Name: "site"}
+
+	r := httptest.NewRequest("GET", "http://example.com/?api_key=key2", nil)
+	r.Header.Set("X-Api-Key", "key1")
+	if got := getApiKey(site, r); got != "key1" {
+		t.Errorf("getApiKey() = %s, want key1", got)
+	}
+
+	r = httptest.NewRequest("GET", "http://example.com/?api_key=key2", nil)
+	if got := getApiKey(site, r); got != "" {
+		t.Errorf("getApiKey() = %s, want it empty without the query param configured", got)
+	}
+	site.ApiKeyParam = "api_key"
+	if got := getApiKey(site, r); got != "key2" {
+		t.Errorf("getApiKey() = %s, want key2", got)
+	}
+}
+
+func TestIsCredentialRateLimited(t *testing.T) {
+	credential := &object.Credential{Owner: "admin", Name: "key", RateLimit: 2}
+	for i := 0; i < 2; i++ {
+		if isCredentialRateLimited(credential) {
+			t.Fatalf("isCredentialRateLimited() limits the request: %d", i)
+		}
+	}
+	if !isCredentialRateLimited(credential) {
+		t.Errorf("isCredentialRateLimited() does not limit the request beyond the burst")
+	}
+
+	credential.RateLimit = 0
+	if isCredentialRateLimited(credential) {
+		t.Errorf("isCredentialRateLimited() limits the request without rate limit")
+	}
+}
+
+func TestStripCredential(t *testing.T) {
+	site := &object.Site{Owner: "admin", Name: "site", AuthMode: "API Key", ApiKeyParam: "api_key"}
+
+	r := httptest.NewRequest("GET", "http://example.com/?api_key=key&q=1", nil)
+	r.Header.Set("X-Api-Key", "key")
+	r.Header.Set("Authorization", "Bearer upstream")
+	r, ok := checkCredential(httptest.NewRecorder(), r, site, nil)
+	if ok {
+		t.Fatalf("checkCredential() accepts an unknown key")
+	}
+	stripCredential(r)
+	if r.Header.Get("X-Api-Key") != "key" {
+		t.Errorf("stripCredential() strips the request which is not authenticated")
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), credentialContextKey{}, site))
+	stripCredential(r)
+	if r.Header.Get("X-Api-Key") != "" || r.URL.RawQuery != "q=1" {
+		t.Errorf("stripCredential() keeps the API key: %s, %s", r.Header.Get("X-Api-Key"), r.URL.RawQuery)
+	}
+	if r.Header.Get("Authorization") == "" {
+		t.Errorf("stripCredential() strips the Authorization header of a site with API keys")
+	}
+
+	site.AuthMode = "Basic Auth"
+	r = httptest.NewRequest("GET", "http://example.com/", nil)
+	r.SetBasicAuth("alice", "123456")
+	r = r.WithContext(context.WithValue(r.Context(), credentialContextKey{}, site))
+	stripCredential(r)
+	if r.Header.Get("Authorization") != "" {
+		t.Errorf("stripCredential() keeps the Basic Auth password")
+	}
+}
+
+func TestFlushCredentialUsage(t *testing.T) {
+	object.InitMemoryAdapter()
+
+	credential := &object.Credential{Owner: "admin", Name: "usage", Type: "API Key", UsageCount: 100, LastUsedTime: "2000-01-01T00:00:00Z"}
+	_, err := object.AddCredential(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		recordCredentialUsage(credential.GetId())
+	}
+	err = flushCredentialUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err = object.GetCredential(credential.GetId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the usage sent by the client on add is not kept
+	if credential.UsageCount != 3 || credential.LastUsedTime == "2000-01-01T00:00:00Z" {
+		t.Errorf("the usage = %d, %s, want 3 since the add", credential.UsageCount, credential.LastUsedTime)
+	}
+}
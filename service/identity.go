@@ -0,0 +1,143 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// identityHeaderGetters are the values of object.IdentityHeaders except the JWT one
+var identityHeaderGetters = map[string]func(claims *casdoorsdk.Claims) string{
+	"X-Auth-Id": func(claims *casdoorsdk.Claims) string {
+		return claims.Id
+	},
+	"X-Auth-User": func(claims *casdoorsdk.Claims) string {
+		return claims.Name
+	},
+	"X-Auth-Organization": func(claims *casdoorsdk.Claims) string {
+		return claims.Owner
+	},
+	"X-Auth-Display-Name": func(claims *casdoorsdk.Claims) string {
+		return claims.DisplayName
+	},
+	"X-Auth-Email": func(claims *casdoorsdk.Claims) string {
+		return claims.Email
+	},
+	"X-Auth-Groups": func(claims *casdoorsdk.Claims) string {
+		return strings.Join(claims.Groups, ",")
+	},
+	"X-Auth-Roles": func(claims *casdoorsdk.Claims) string {
+		return strings.Join(getRoleNames(claims), ",")
+	},
+}
+
+type identityContextKey struct{}
+
+type identity struct {
+	site   *object.Site
+	claims *casdoorsdk.Claims
+}
+
+type identityJwtClaims struct {
+	Name         string   `json:"name"`
+	Organization string   `json:"organization"`
+	Email        string   `json:"email"`
+	Groups       []string `json:"groups"`
+	Roles        []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+func getRoleNames(claims *casdoorsdk.Claims) []string {
+	res := []string{}
+	for _, role := range claims.Roles {
+		res = append(res, role.Name)
+	}
+	return res
+}
+
+// withIdentity attaches the signed-in user to the request, whose identity headers are set by the Director
+func withIdentity(r *http.Request, site *object.Site, claims *casdoorsdk.Claims) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityContextKey{}, &identity{site: site, claims: claims}))
+}
+
+// setIdentityHeaders strips the identity headers sent by the client, which must not be trusted by the
+// upstream, and sets the identity headers configured by the site for the signed-in user
+func setIdentityHeaders(r *http.Request) {
+	for _, name := range object.IdentityHeaders {
+		r.Header.Del(name)
+	}
+
+	id, ok := r.Context().Value(identityContextKey{}).(*identity)
+	if !ok || id.claims == nil {
+		return
+	}
+
+	for _, name := range id.site.IdentityHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if name == object.IdentityJwtHeader {
+			token, err := newIdentityJwt(id.site, id.claims)
+			if err != nil {
+				fmt.Printf("setIdentityHeaders() error: %v\n", err)
+				continue
+			}
+			r.Header.Set(name, token)
+			continue
+		}
+
+		getter, ok := identityHeaderGetters[name]
+		if !ok {
+			continue
+		}
+		value := getter(id.claims)
+		if value != "" {
+			r.Header.Set(name, value)
+		}
+	}
+}
+
+// newIdentityJwt returns a short-lived JWT of the user signed with the identity JWT secret of the site
+// by HS256, so the upstream can verify the identity without trusting the network between them
+func newIdentityJwt(site *object.Site, claims *casdoorsdk.Claims) (string, error) {
+	if site.IdentityJwtSecret == "" {
+		return "", fmt.Errorf("the identity JWT secret of site: %s should not be empty", site.GetId())
+	}
+
+	now := time.Now()
+	jwtClaims := identityJwtClaims{
+		Name:         claims.Name,
+		Organization: claims.Owner,
+		Email:        claims.Email,
+		Groups:       claims.Groups,
+		Roles:        getRoleNames(claims),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "caswaf",
+			Subject:   claims.Id,
+			Audience:  []string{site.Domain},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims)
+	return token.SignedString([]byte(site.IdentityJwtSecret))
+}
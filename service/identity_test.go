@@ -0,0 +1,109 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func newTestClaims() *casdoorsdk.Claims {
+	claims := &casdoorsdk.Claims{}
+	claims.Owner = "built-in"
+	claims.Name = "alice"
+	claims.Email = "alice@example.com"
+	claims.Groups = []string{"built-in/dev", "built-in/ops"}
+	claims.Roles = []*casdoorsdk.Role{{Name: "admin"}, {Name: "user"}}
+	return claims
+}
+
+func TestSetIdentityHeaders(t *testing.T) {
+	site := &object.Site{
+		Owner:           "admin",
+		Name:            "site",
+		IdentityHeaders: []string{"X-Auth-User", "x-auth-groups", "X-Auth-Roles"},
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.Header.Set("X-Auth-User", "mallory")
+	r.Header.Set("X-Auth-Email", "mallory@example.com")
+	r = withIdentity(r, site, newTestClaims())
+	setIdentityHeaders(r)
+
+	if got := r.Header.Get("X-Auth-User"); got != "alice" {
+		t.Errorf("X-Auth-User = %s, want alice", got)
+	}
+	if got := r.Header.Get("X-Auth-Groups"); got != "built-in/dev,built-in/ops" {
+		t.Errorf("X-Auth-Groups = %s, want built-in/dev,built-in/ops", got)
+	}
+	if got := r.Header.Get("X-Auth-Roles"); got != "admin,user" {
+		t.Errorf("X-Auth-Roles = %s, want admin,user", got)
+	}
+	if got := r.Header.Get("X-Auth-Email"); got != "" {
+		t.Errorf("X-Auth-Email = %s, want it stripped", got)
+	}
+
+	r = httptest.NewRequest("GET", "http://example.com/", nil)
+	r.Header.Set("X-Auth-User", "mallory")
+	r.Header.Set("X-Auth-Jwt", "forged")
+	setIdentityHeaders(r)
+	if r.Header.Get("X-Auth-User") != "" || r.Header.Get("X-Auth-Jwt") != "" {
+		t.Errorf("setIdentityHeaders() keeps the identity headers of an anonymous request")
+	}
+
+	// each identity header accepted for a site has its value
+	for _, name := range object.IdentityHeaders {
+		if _, ok := identityHeaderGetters[name]; !ok && name != object.IdentityJwtHeader {
+			t.Errorf("the identity header: %s has no getter", name)
+		}
+	}
+	if !object.IsIdentityHeader("x-auth-email") || object.IsIdentityHeader("X-Forwarded-For") {
+		t.Errorf("IsIdentityHeader() should only accept the identity headers case-insensitively")
+	}
+}
+
+func TestNewIdentityJwt(t *testing.T) {
+	site := &object.Site{
+		Owner:             "admin",
+		Name:              "site",
+		Domain:            "example.com",
+		IdentityHeaders:   []string{"X-Auth-Jwt"},
+		IdentityJwtSecret: "secret",
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r = withIdentity(r, site, newTestClaims())
+	setIdentityHeaders(r)
+
+	claims := &identityJwtClaims{}
+	_, err := jwt.ParseWithClaims(r.Header.Get("X-Auth-Jwt"), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		t.Fatalf("jwt.ParseWithClaims() error = %v", err)
+	}
+	if claims.Name != "alice" || claims.Organization != "built-in" || len(claims.Roles) != 2 || !claims.VerifyAudience("example.com", true) {
+		t.Errorf("newIdentityJwt() claims = %+v", claims)
+	}
+
+	site.IdentityJwtSecret = ""
+	if _, err = newIdentityJwt(site, newTestClaims()); err == nil {
+		t.Errorf("newIdentityJwt() accepts an empty secret")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
+)
+
+// clientCertHeaders maps the headers forwarded to upstreams to the attributes of the verified client certificate
+var clientCertHeaders = map[string]string{
+	"X-Client-Cert-Subject":     "Subject",
+	"X-Client-Cert-Issuer":      "Issuer",
+	"X-Client-Cert-San":         "SAN",
+	"X-Client-Cert-Fingerprint": "Fingerprint",
+}
+
+type clientCaPool struct {
+	hash string
+	pool *x509.CertPool
+}
+
+// clientCaPools caches the parsed CA bundles by cert name until the bundle is changed
+var clientCaPools sync.Map
+
+func isClientAuthEnabled(site *object.Site) bool {
+	return site.ClientAuthMode == "Required" || site.ClientAuthMode == "Optional"
+}
+
+func getClientCaPool(site *object.Site) (*x509.CertPool, error) {
+	cert := object.GetClientCaCert(site.ClientCaCert)
+	if cert == nil {
+		return nil, fmt.Errorf("the client CA cert: %s of site: %s is not found", site.ClientCaCert, site.GetId())
+	}
+
+	hash := sha256.Sum256([]byte(cert.Certificate))
+	hashStr := hex.EncodeToString(hash[:])
+	if v, ok := clientCaPools.Load(cert.Name); ok && v.(*clientCaPool).hash == hashStr {
+		return v.(*clientCaPool).pool, nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(cert.Certificate)) {
+		return nil, fmt.Errorf("the client CA cert: %s has no valid PEM certificate", cert.Name)
+	}
+	clientCaPools.Store(cert.Name, &clientCaPool{hash: hashStr, pool: pool})
+	return pool, nil
+}
+
+// getConfigForClient returns the TLS config requesting client certificates for the SNI of an mTLS site,
+// other sites use the default config which does not request client certificates. The TLS-ALPN-01
+// validation uses the config negotiating the acme-tls/1 protocol.
+func getConfigForClient(config *tls.Config) func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		if isTlsAlpnChallenge(info) {
+			return getTlsAlpnChallengeConfig(config), nil
+		}
+
+		site := getSiteByDomainWithWww(info.ServerName)
+		if site == nil || !isClientAuthEnabled(site) {
+			return nil, nil
+		}
+
+		pool, err := getClientCaPool(site)
+		if err != nil {
+			return nil, err
+		}
+
+		res := config.Clone()
+		res.GetConfigForClient = nil
+		res.ClientCAs = pool
+		res.ClientAuth = tls.VerifyClientCertIfGiven
+		if site.ClientAuthMode == "Required" {
+			res.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return res, nil
+	}
+}
+
+// verifyClientCert checks the client certificate against the CA pool of the site requested by the Host
+// header, which may differ from the SNI the certificate was verified for in the handshake. The returned
+// request only keeps the client certificate verified by the pool, a nil pool verifies no certificate.
+func verifyClientCert(r *http.Request, pool *x509.CertPool) *http.Request {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return r
+	}
+
+	state := *r.TLS
+	state.VerifiedChains = nil
+	if pool != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range state.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		chains, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         pool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err == nil {
+			state.VerifiedChains = chains
+		}
+	}
+
+	res := r.Clone(r.Context())
+	res.TLS = &state
+	return res
+}
+
+// checkClientCert authenticates the request of an mTLS site, it writes the response and returns
+// false if the request is rejected
+func checkClientCert(w http.ResponseWriter, r *http.Request, site *object.Site) (*http.Request, bool) {
+	var pool *x509.CertPool
+	if isClientAuthEnabled(site) {
+		var err error
+		pool, err = getClientCaPool(site)
+		if err != nil {
+			responseError(w, "CasWAF error: getClientCaPool() error: %s", err.Error())
+			return nil, false
+		}
+	}
+
+	r = verifyClientCert(r, pool)
+	if site.ClientAuthMode == "Required" && util.GetClientCert(r) == nil {
+		w.WriteHeader(http.StatusForbidden)
+		responseErrorWithoutCode(w, "CasWAF error: a valid client certificate is required")
+		return nil, false
+	}
+	return r, true
+}
+
+// setClientCertHeaders strips the client certificate headers sent by the client and sets them by
+// the verified client certificate
+func setClientCertHeaders(r *http.Request) {
+	for header := range clientCertHeaders {
+		r.Header.Del(header)
+	}
+
+	cert := util.GetClientCert(r)
+	if cert == nil {
+		return
+	}
+
+	for header, attribute := range clientCertHeaders {
+		value := util.GetClientCertAttribute(cert, attribute)
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, commonName string, isCa bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Casbin"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCa,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		DNSNames:              []string{commonName},
+	}
+	if isCa {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestVerifyClientCert(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", true, nil, nil)
+	otherCa, otherCaKey := newTestCert(t, "other-ca", true, nil, nil)
+	client, _ := newTestCert(t, "client", false, ca, caKey)
+	otherClient, _ := newTestCert(t, "other-client", false, otherCa, otherCaKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	r := httptest.NewRequest("GET", "https://example.com/", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client}}
+	r.Header.Set("X-Client-Cert-Subject", "CN=forged")
+	r = verifyClientCert(r, pool)
+	setClientCertHeaders(r)
+	if got := r.Header.Get("X-Client-Cert-Subject"); got != "CN=client,O=Casbin" {
+		t.Errorf("X-Client-Cert-Subject = %s, want CN=client,O=Casbin", got)
+	}
+	if got := r.Header.Get("X-Client-Cert-San"); got != "client" {
+		t.Errorf("X-Client-Cert-San = %s, want client", got)
+	}
+	if got := r.Header.Get("X-Client-Cert-Fingerprint"); len(got) != 64 {
+		t.Errorf("X-Client-Cert-Fingerprint = %s, want a SHA-256 hash", got)
+	}
+
+	// verified in the handshake by the CA of another site
+	r = httptest.NewRequest("GET", "https://example.com/", nil)
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{otherClient},
+		VerifiedChains:   [][]*x509.Certificate{{otherClient, otherCa}},
+	}
+	r.Header.Set("X-Client-Cert-Subject", "CN=forged")
+	r = verifyClientCert(r, pool)
+	setClientCertHeaders(r)
+	if got := r.Header.Get("X-Client-Cert-Subject"); got != "" {
+		t.Errorf("X-Client-Cert-Subject = %s, want it stripped", got)
+	}
+
+	r = httptest.NewRequest("GET", "https://example.com/", nil)
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{client},
+		VerifiedChains:   [][]*x509.Certificate{{client, ca}},
+	}
+	r = verifyClientCert(r, nil)
+	if r.TLS.VerifiedChains != nil {
+		t.Errorf("verifyClientCert() keeps the client certificate of a site without mTLS")
+	}
+}
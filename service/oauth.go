@@ -15,28 +15,171 @@
 package service
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/caswaf/util"
 	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+	"golang.org/x/oauth2"
 )
 
-func getSigninUrl(casdoorClient *casdoorsdk.Client, callbackUrl string, originalPath string) string {
+const (
+	accessTokenCookieName  = "casdoor_access_token"
+	refreshTokenCookieName = "casdoor_refresh_token"
+	oauthNonceCookieName   = "caswaf_oauth_nonce"
+
+	oauthStateTtl      = 10 * time.Minute
+	refreshTokenMaxAge = 30 * 24 * time.Hour
+)
+
+func getSigninUrl(casdoorClient *casdoorsdk.Client, callbackUrl string, state string) string {
 	scope := "read"
 	return fmt.Sprintf("%s/login/oauth/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=%s&state=%s",
-		casdoorClient.Endpoint, casdoorClient.ClientId, url.QueryEscape(callbackUrl), scope, url.QueryEscape(originalPath))
+		casdoorClient.Endpoint, casdoorClient.ClientId, url.QueryEscape(callbackUrl), scope, url.QueryEscape(state))
 }
 
-func redirectToCasdoor(casdoorClient *casdoorsdk.Client, w http.ResponseWriter, r *http.Request) {
-	scheme := getScheme(r)
+// newOAuthState returns a state of the format: "path.nonce.expireTime.signature", where path is the
+// base64 encoded path to return to after signing in. The nonce is also kept in a cookie of the client
+// to make sure that the callback comes from the sign-in started by the same client.
+func newOAuthState(site string, nonce string, path string) string {
+	encodedPath := base64.RawURLEncoding.EncodeToString([]byte(getSafeRedirect(path)))
+	expireTime := strconv.FormatInt(time.Now().Add(oauthStateTtl).Unix(), 10)
+	signature := util.GetHmacSha256(getClearanceSecret(), strings.Join([]string{"oauth", site, encodedPath, nonce, expireTime}, "|"))
+	return strings.Join([]string{encodedPath, nonce, expireTime, signature}, ".")
+}
+
+// parseOAuthState returns the path to return to, or false if the state is invalid
+func parseOAuthState(state string, site string, nonce string) (string, bool) {
+	tokens := strings.Split(state, ".")
+	if len(tokens) != 4 || nonce == "" || tokens[1] != nonce || !isUnexpired(tokens[2]) {
+		return "", false
+	}
+
+	message := strings.Join([]string{"oauth", site, tokens[0], tokens[1], tokens[2]}, "|")
+	if !util.IsHmacSha256Valid(getClearanceSecret(), message, tokens[3]) {
+		return "", false
+	}
+
+	path, err := base64.RawURLEncoding.DecodeString(tokens[0])
+	if err != nil {
+		return "", false
+	}
+	return getSafeRedirect(string(path)), true
+}
+
+func redirectToCasdoor(casdoorClient *casdoorsdk.Client, w http.ResponseWriter, r *http.Request, site *object.Site) {
+	nonce := util.GetRandomHexString(16)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthNonceCookieName,
+		Value:    nonce,
+		Path:     "/caswaf-handler",
+		MaxAge:   int(oauthStateTtl.Seconds()),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
 
+	scheme := getScheme(r)
 	callbackUrl := fmt.Sprintf("%s://%s/caswaf-handler", scheme, r.Host)
-	originalPath := r.RequestURI
-	signinUrl := getSigninUrl(casdoorClient, callbackUrl, originalPath)
+	state := newOAuthState(site.GetId(), nonce, r.RequestURI)
+	signinUrl := getSigninUrl(casdoorClient, callbackUrl, state)
 	http.Redirect(w, r, signinUrl, http.StatusFound)
 }
 
+func setSessionCookies(w http.ResponseWriter, r *http.Request, token *oauth2.Token) {
+	maxAge := 0
+	if !token.Expiry.IsZero() {
+		maxAge = int(time.Until(token.Expiry).Seconds())
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookieName,
+		Value:    token.AccessToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if token.RefreshToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     refreshTokenCookieName,
+			Value:    token.RefreshToken,
+			Path:     "/",
+			MaxAge:   int(refreshTokenMaxAge.Seconds()),
+			Secure:   r.TLS != nil,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{accessTokenCookieName, refreshTokenCookieName} {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+	}
+}
+
+// stripSessionCookies removes the Casdoor tokens of the session from the request to the upstream, which
+// should never receive the credentials of the signed-in user
+func stripSessionCookies(r *http.Request) {
+	cookies := r.Cookies()
+	isStripped := false
+	res := []*http.Cookie{}
+	for _, cookie := range cookies {
+		if cookie.Name == accessTokenCookieName || cookie.Name == refreshTokenCookieName {
+			isStripped = true
+			continue
+		}
+		res = append(res, cookie)
+	}
+	if !isStripped {
+		return
+	}
+
+	r.Header.Del("Cookie")
+	for _, cookie := range res {
+		r.AddCookie(cookie)
+	}
+}
+
+// getSessionClaims returns the claims of the signed-in user, the access token is refreshed
+// with the refresh token if it has expired. nil is returned if the user needs to sign in again.
+func getSessionClaims(casdoorClient *casdoorsdk.Client, w http.ResponseWriter, r *http.Request) *casdoorsdk.Claims {
+	cookie, err := r.Cookie(accessTokenCookieName)
+	if err == nil && cookie.Value != "" {
+		claims, err := casdoorClient.ParseJwtToken(cookie.Value)
+		if err == nil {
+			return claims
+		}
+	}
+
+	refreshCookie, err := r.Cookie(refreshTokenCookieName)
+	if err != nil || refreshCookie.Value == "" {
+		return nil
+	}
+
+	token, err := casdoorClient.RefreshOAuthToken(refreshCookie.Value)
+	if err != nil {
+		fmt.Printf("getSessionClaims() error: casdoorClient.RefreshOAuthToken() error: %v\n", err)
+		return nil
+	}
+	claims, err := casdoorClient.ParseJwtToken(token.AccessToken)
+	if err != nil {
+		fmt.Printf("getSessionClaims() error: casdoorClient.ParseJwtToken() error: %v\n", err)
+		return nil
+	}
+
+	setSessionCookies(w, r, token)
+	return claims
+}
+
 func handleAuthCallback(w http.ResponseWriter, r *http.Request) {
 	site := getSiteByDomainWithWww(r.Host)
 	if site == nil {
@@ -54,6 +197,19 @@ func handleAuthCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	nonce := ""
+	nonceCookie, err := r.Cookie(oauthNonceCookieName)
+	if err == nil {
+		nonce = nonceCookie.Value
+	}
+	originalPath, ok := parseOAuthState(state, site.GetId(), nonce)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		responseErrorWithoutCode(w, "CasWAF error: the state is invalid or expired, please sign in again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthNonceCookieName, Path: "/caswaf-handler", MaxAge: -1})
+
 	casdoorClient, err := getCasdoorClientFromSite(site)
 	if err != nil {
 		responseError(w, "CasWAF error: getCasdoorClientFromSite() error: %s", err.Error())
@@ -66,13 +222,6 @@ func handleAuthCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie := &http.Cookie{
-		Name:  "casdoor_access_token",
-		Value: token.AccessToken,
-		Path:  "/",
-	}
-	http.SetCookie(w, cookie)
-
-	originalPath := state
+	setSessionCookies(w, r, token)
 	http.Redirect(w, r, originalPath, http.StatusFound)
 }
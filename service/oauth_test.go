@@ -0,0 +1,44 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import "testing"
+
+func TestOAuthState(t *testing.T) {
+	state := newOAuthState("admin/site", "nonce1", "/api/items?page=2")
+
+	path, ok := parseOAuthState(state, "admin/site", "nonce1")
+	if !ok || path != "/api/items?page=2" {
+		t.Errorf("parseOAuthState() = %s, %v, want /api/items?page=2, true", path, ok)
+	}
+	if _, ok = parseOAuthState(state, "admin/site", "nonce2"); ok {
+		t.Errorf("parseOAuthState() accepts another nonce")
+	}
+	if _, ok = parseOAuthState(state, "admin/site", ""); ok {
+		t.Errorf("parseOAuthState() accepts an empty nonce")
+	}
+	if _, ok = parseOAuthState(state, "admin/site2", "nonce1"); ok {
+		t.Errorf("parseOAuthState() accepts another site")
+	}
+	if _, ok = parseOAuthState("/api/items", "admin/site", "nonce1"); ok {
+		t.Errorf("parseOAuthState() accepts a raw path")
+	}
+
+	state = newOAuthState("admin/site", "nonce1", "https://evil.com/")
+	path, ok = parseOAuthState(state, "admin/site", "nonce1")
+	if !ok || path != "/" {
+		t.Errorf("parseOAuthState() = %s, %v, want /, true", path, ok)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspRetryInterval is how long to wait before fetching again after a failed fetch or
+	// for a cert without OCSP server
+	ocspRetryInterval = time.Hour
+	ocspMaxRespSize   = 1024 * 1024
+	// ocspIdleTimeout is how long the OCSP response of a cert not served is kept, e.g., a renewed cert
+	ocspIdleTimeout   = 24 * time.Hour
+	ocspPruneInterval = time.Hour
+)
+
+type ocspStaple struct {
+	// usedTime is the Unix time when the cert is last served, which is accessed atomically
+	// and kept as the first field to be 64-bit aligned
+	usedTime   int64
+	staple     []byte
+	nextUpdate time.Time
+	refreshAt  time.Time
+}
+
+var (
+	// ocspStaples caches the OCSP responses by the hash of the leaf certificate
+	ocspStaples  sync.Map
+	ocspFetching sync.Map
+	// ocspPruneTime is the Unix time of the last pruning, which is accessed atomically
+	ocspPruneTime int64
+
+	ocspHttpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// getStapledCert returns the cert with the cached OCSP response stapled. The OCSP response is fetched
+// in the background when it is missing or half of its validity has passed, so the handshake is not
+// blocked by the OCSP server.
+func getStapledCert(tlsCert *tls.Certificate) *tls.Certificate {
+	if len(tlsCert.Certificate) < 2 {
+		// a self-signed cert or a cert without the issuer in the chain cannot be checked
+		return tlsCert
+	}
+
+	key := sha256.Sum256(tlsCert.Certificate[0])
+	now := time.Now()
+	pruneOcspStaples(now)
+
+	var staple []byte
+	if v, ok := ocspStaples.Load(key); ok {
+		s := v.(*ocspStaple)
+		atomic.StoreInt64(&s.usedTime, now.Unix())
+		if now.Before(s.nextUpdate) {
+			staple = s.staple
+		}
+		if now.Before(s.refreshAt) {
+			return withOcspStaple(tlsCert, staple)
+		}
+	}
+
+	if _, fetching := ocspFetching.LoadOrStore(key, true); !fetching {
+		go func() {
+			defer ocspFetching.Delete(key)
+
+			s, err := fetchOcspStaple(tlsCert)
+			if err != nil {
+				fmt.Printf("fetchOcspStaple() error: %v\n", err)
+				s = &ocspStaple{refreshAt: time.Now().Add(ocspRetryInterval)}
+				if v, ok := ocspStaples.Load(key); ok {
+					// keep the previous response until it expires
+					s.staple = v.(*ocspStaple).staple
+					s.nextUpdate = v.(*ocspStaple).nextUpdate
+				}
+			}
+			s.usedTime = time.Now().Unix()
+			ocspStaples.Store(key, s)
+		}()
+	}
+
+	return withOcspStaple(tlsCert, staple)
+}
+
+// pruneOcspStaples evicts the OCSP responses of the certs not served within ocspIdleTimeout, i.e., the
+// certs renewed or deleted, it runs at most once per ocspPruneInterval
+func pruneOcspStaples(now time.Time) {
+	pruneTime := atomic.LoadInt64(&ocspPruneTime)
+	if now.Unix()-pruneTime < int64(ocspPruneInterval/time.Second) || !atomic.CompareAndSwapInt64(&ocspPruneTime, pruneTime, now.Unix()) {
+		return
+	}
+
+	ocspStaples.Range(func(key, value interface{}) bool {
+		if now.Unix()-atomic.LoadInt64(&value.(*ocspStaple).usedTime) > int64(ocspIdleTimeout/time.Second) {
+			ocspStaples.Delete(key)
+		}
+		return true
+	})
+}
+
+func withOcspStaple(tlsCert *tls.Certificate, staple []byte) *tls.Certificate {
+	if staple == nil {
+		return tlsCert
+	}
+
+	// the cached cert is shared by handshakes, so the staple is set on a copy
+	res := *tlsCert
+	res.OCSPStaple = staple
+	return &res
+}
+
+func fetchOcspStaple(tlsCert *tls.Certificate) (*ocspStaple, error) {
+	leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := x509.ParseCertificate(tlsCert.Certificate[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return &ocspStaple{refreshAt: leaf.NotAfter}, nil
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ocspHttpClient.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the OCSP server: %s returns status: %s for cert: %s", leaf.OCSPServer[0], resp.Status, leaf.Subject)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ocspMaxRespSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOcspStaple(body, leaf, issuer)
+}
+
+func parseOcspStaple(body []byte, leaf *x509.Certificate, issuer *x509.Certificate) (*ocspStaple, error) {
+	ocspResp, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if ocspResp.Status != ocsp.Good {
+		return nil, fmt.Errorf("the OCSP status of cert: %s is not good: %d", leaf.Subject, ocspResp.Status)
+	}
+
+	nextUpdate := ocspResp.NextUpdate
+	if nextUpdate.IsZero() {
+		nextUpdate = ocspResp.ThisUpdate.Add(24 * time.Hour)
+	}
+	return &ocspStaple{
+		staple:     body,
+		nextUpdate: nextUpdate,
+		refreshAt:  ocspResp.ThisUpdate.Add(nextUpdate.Sub(ocspResp.ThisUpdate) / 2),
+	}, nil
+}
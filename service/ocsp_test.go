@@ -0,0 +1,129 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestGetStapledCert(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", true, nil, nil)
+
+	var leaf *x509.Certificate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil || req.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, caKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(resp)
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(12345),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"example.com"},
+		OCSPServer:   []string{server.URL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsCert := &tls.Certificate{Certificate: [][]byte{der, ca.Raw}, PrivateKey: key}
+	if getStapledCert(tlsCert).OCSPStaple != nil {
+		t.Fatalf("getStapledCert() staples before the OCSP response is fetched")
+	}
+
+	for i := 0; i < 100; i++ {
+		cert := getStapledCert(tlsCert)
+		if cert.OCSPStaple != nil {
+			if tlsCert.OCSPStaple != nil {
+				t.Errorf("getStapledCert() modifies the cached cert")
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("getStapledCert() does not staple the OCSP response")
+}
+
+func TestPruneOcspStaples(t *testing.T) {
+	now := time.Now()
+	served := sha256.Sum256([]byte("served"))
+	renewed := sha256.Sum256([]byte("renewed"))
+	ocspStaples.Store(served, &ocspStaple{usedTime: now.Add(-time.Hour).Unix()})
+	ocspStaples.Store(renewed, &ocspStaple{usedTime: now.Add(-ocspIdleTimeout - time.Hour).Unix()})
+	defer ocspStaples.Delete(served)
+
+	atomic.StoreInt64(&ocspPruneTime, 0)
+	pruneOcspStaples(now)
+	if _, ok := ocspStaples.Load(served); !ok {
+		t.Errorf("the OCSP response of a served cert is evicted")
+	}
+	if _, ok := ocspStaples.Load(renewed); ok {
+		t.Errorf("the OCSP response of a cert not served is kept")
+	}
+
+	// the responses are not pruned again within the interval
+	ocspStaples.Store(renewed, &ocspStaple{usedTime: now.Add(-ocspIdleTimeout - time.Hour).Unix()})
+	defer ocspStaples.Delete(renewed)
+	pruneOcspStaples(now.Add(time.Minute))
+	if _, ok := ocspStaples.Load(renewed); !ok {
+		t.Errorf("the OCSP responses are pruned again within %v", ocspPruneInterval)
+	}
+	pruneOcspStaples(now.Add(ocspPruneInterval))
+	if _, ok := ocspStaples.Load(renewed); ok {
+		t.Errorf("the OCSP responses are not pruned after %v", ocspPruneInterval)
+	}
+}
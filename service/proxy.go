@@ -41,6 +41,10 @@ func forwardHandler(targetUrl string, writer http.ResponseWriter, request *http.
 	proxy := httputil.NewSingleHostReverseProxy(target)
 	proxy.Director = func(r *http.Request) {
 		r.URL = target
+		setIdentityHeaders(r)
+		setClientCertHeaders(r)
+		stripCredential(r)
+		stripSessionCookies(r)
 
 		if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
 			if xff := r.Header.Get("X-Forwarded-For"); xff != "" && xff != clientIP {
@@ -118,7 +122,9 @@ func getHostNonWww(host string) string {
 	return res
 }
 
-func logRequest(clientIp string, r *http.Request) {
+// logRequest returns the record of the request, which is added by addRecord() after the credential
+// of the request is authenticated
+func logRequest(clientIp string, r *http.Request) *object.Record {
 	if !strings.Contains(r.UserAgent(), "Uptime-Kuma") {
 		fmt.Printf("handleRequest: %s\t%s\t%s\t%s\t%s\t%s\n", clientIp, r.Method, r.Host, r.RequestURI, r.UserAgent(), r.RemoteAddr)
 		record := object.Record{
@@ -130,7 +136,34 @@ func logRequest(clientIp string, r *http.Request) {
 			ClientIp:    clientIp,
 			UserAgent:   r.UserAgent(),
 		}
-		object.AddRecord(&record)
+		return &record
+	}
+	return nil
+}
+
+func addRecord(record *object.Record) {
+	if record == nil || record.Id != 0 {
+		return
+	}
+
+	_, err := object.AddRecord(record)
+	if err != nil {
+		fmt.Printf("addRecord() error: %v\n", err)
+	}
+}
+
+func logRuleResult(record *object.Record, result *rule.RuleResult) {
+	if record == nil || len(result.HitRules) == 0 {
+		return
+	}
+
+	record.Action = result.Action
+	record.Score = result.Score
+	record.RuleScores = result.RuleScores
+	record.WafMatches = result.WafMatches
+	err := object.UpdateRecordRuleResult(record)
+	if err != nil {
+		fmt.Printf("logRuleResult() error: %v\n", err)
 	}
 }
 
@@ -151,7 +184,9 @@ func redirectToHost(w http.ResponseWriter, r *http.Request, host string) {
 
 func handleRequest(w http.ResponseWriter, r *http.Request) {
 	clientIp := util.GetClientIp(r)
-	logRequest(clientIp, r)
+	record := logRequest(clientIp, r)
+	// the record of a request rejected before the rules is added when it returns
+	defer addRecord(record)
 
 	site := getSiteByDomainWithWww(r.Host)
 	if site == nil {
@@ -222,30 +257,55 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// oAuth proxy
-	if site.CasdoorApplication != "" {
-		// handle oAuth proxy
-		cookie, err := r.Cookie("casdoor_access_token")
-		if err != nil && err.Error() != "http: named cookie not present" {
-			panic(err)
+	var ok bool
+	r, ok = checkClientCert(w, r, site)
+	if !ok {
+		return
+	}
+
+	if site.AuthMode == "Basic Auth" || site.AuthMode == "API Key" {
+		r, ok = checkCredential(w, r, site, record)
+		if !ok {
+			return
 		}
+	}
+	addRecord(record)
 
-		casdoorClient, err := getCasdoorClientFromSite(site)
+	// oAuth proxy
+	if site.CasdoorApplication != "" {
+		// the paths allowed for anonymous requests by the access policy skip the authentication
+		allowed, err := isAccessAllowed(site, nil, r)
 		if err != nil {
-			responseError(w, "CasWAF error: getCasdoorClientFromSite() error: %s", err.Error())
+			responseError(w, "CasWAF error: isAccessAllowed() error: %s", err.Error())
 			return
 		}
 
-		if cookie == nil {
-			// not logged in
-			redirectToCasdoor(casdoorClient, w, r)
-			return
-		} else {
-			_, err = casdoorClient.ParseJwtToken(cookie.Value)
+		if !allowed {
+			casdoorClient, err := getCasdoorClientFromSite(site)
+			if err != nil {
+				responseError(w, "CasWAF error: getCasdoorClientFromSite() error: %s", err.Error())
+				return
+			}
+
+			claims := getSessionClaims(casdoorClient, w, r)
+			if claims == nil {
+				// not logged in, or the session has expired and cannot be refreshed
+				clearSessionCookies(w)
+				redirectToCasdoor(casdoorClient, w, r, site)
+				return
+			}
+
+			allowed, err = isAccessAllowed(site, claims, r)
 			if err != nil {
-				responseError(w, "CasWAF error: casdoorClient.ParseJwtToken() error: %s", err.Error())
+				responseError(w, "CasWAF error: isAccessAllowed() error: %s", err.Error())
 				return
 			}
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				responseErrorWithoutCode(w, "CasWAF error: user: %s/%s is not allowed to access: %s", claims.Owner, claims.Name, r.URL.Path)
+				return
+			}
+			r = withIdentity(r, site, claims)
 		}
 	}
 
@@ -255,16 +315,12 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(site.Rules) == 0 {
-		nextHandle(w, r)
-		return
-	}
-
-	result, err := rule.CheckRules(site.Rules, r)
+	result, err := rule.CheckSiteRules(site, r)
 	if err != nil {
 		responseError(w, "Internal Server Error: %v", err)
 		return
 	}
+	logRuleResult(record, result)
 
 	reason := result.Reason
 	if reason != "" && site.DisableVerbose {
@@ -275,22 +331,32 @@ func handleRequest(w http.ResponseWriter, r *http.Request) {
 	case "", "Allow":
 		// Do not write header for Allow action, let the proxy handle it
 	case "Block":
+		recordBlockedRequest(site, result.Reason)
 		w.WriteHeader(result.StatusCode)
 		responseErrorWithoutCode(w, "Blocked by CasWAF: %s", reason)
 		return
 	case "Drop":
+		recordBlockedRequest(site, result.Reason)
 		w.WriteHeader(result.StatusCode)
 		responseErrorWithoutCode(w, "Dropped by CasWAF: %s", reason)
 		return
 	case "CAPTCHA":
-		ok := isVerifiedSession(r)
+		ok := isVerifiedSession(r, site)
 		if ok {
 			w.WriteHeader(http.StatusOK)
 			nextHandle(w, r)
 			return
 		}
 		w.Header().Set("Set-Cookie", "casdoor_captcha_token=; Path=/; Max-Age=-1")
-		redirectToCaptcha(w, r)
+		redirectToCaptcha(w, r, newClearance("captcha", site.GetId(), result.ClearanceBinding, result.ClearanceTtl), r.RequestURI)
+		return
+	case "JS Challenge":
+		if isClearedByChallenge(r, site) {
+			nextHandle(w, r)
+			return
+		}
+		c := newClearance("challenge", site.GetId(), result.ClearanceBinding, result.ClearanceTtl)
+		serveJsChallenge(w, r, c, result.StatusCode, r.RequestURI)
 		return
 	default:
 		responseError(w, "Error in CasWAF: %s", reason)
@@ -326,6 +392,7 @@ func Start() {
 	serverMux.HandleFunc("/", handleRequest)
 	serverMux.HandleFunc("/caswaf-handler", handleAuthCallback)
 	serverMux.HandleFunc("/caswaf-captcha-verify", handleCaptchaCallback)
+	serverMux.HandleFunc("/caswaf-challenge-verify", handleChallengeCallback)
 
 	gatewayEnabled, err := beego.AppConfig.Bool("gatewayEnabled")
 	if err != nil {
@@ -385,14 +452,19 @@ func Start() {
 
 		// start https server and set how to get certificate
 		server.TLSConfig.GetCertificate = func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if isTlsAlpnChallenge(info) {
+				return getTlsAlpnChallengeCert(info)
+			}
+
 			domain := info.ServerName
-			cert, err := getX509CertByDomain(domain)
+			cert, err := getX509CertByDomain(domain, info)
 			if err != nil {
 				return nil, err
 			}
 
 			return cert, nil
 		}
+		server.TLSConfig.GetConfigForClient = getConfigForClient(server.TLSConfig)
 
 		err := server.ListenAndServeTLS("", "")
 		if err != nil {
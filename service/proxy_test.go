@@ -0,0 +1,47 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForwardHandlerStripsSessionCookies(t *testing.T) {
+	var upstreamCookie string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCookie = r.Header.Get("Cookie")
+	}))
+	defer upstream.Close()
+
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.AddCookie(&http.Cookie{Name: accessTokenCookieName, Value: "access-token"})
+	r.AddCookie(&http.Cookie{Name: "app_session", Value: "app"})
+	r.AddCookie(&http.Cookie{Name: refreshTokenCookieName, Value: "refresh-token"})
+	w := httptest.NewRecorder()
+	forwardHandler(upstream.URL, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("forwardHandler() status = %d", w.Code)
+	}
+	if strings.Contains(upstreamCookie, "access-token") || strings.Contains(upstreamCookie, "refresh-token") {
+		t.Errorf("the upstream receives the session cookies: %s", upstreamCookie)
+	}
+	if upstreamCookie != "app_session=app" {
+		t.Errorf("the upstream Cookie = %s, want the other cookies kept", upstreamCookie)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casbin/lego/v4/challenge/tlsalpn01"
+)
+
+func isTlsAlpnChallenge(info *tls.ClientHelloInfo) bool {
+	for _, proto := range info.SupportedProtos {
+		if proto == tlsalpn01.ACMETLS1Protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// getTlsAlpnChallengeConfig returns the TLS config negotiating the acme-tls/1 protocol for the
+// TLS-ALPN-01 validation, which does not request client certificates even for an mTLS site
+func getTlsAlpnChallengeConfig(config *tls.Config) *tls.Config {
+	res := config.Clone()
+	res.GetConfigForClient = nil
+	res.NextProtos = []string{tlsalpn01.ACMETLS1Protocol}
+	return res
+}
+
+// getTlsAlpnChallengeCert returns the challenge cert of the TLS-ALPN-01 validation of the SNI
+func getTlsAlpnChallengeCert(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := object.GetTlsAlpnChallengeCert(info.ServerName)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("no pending TLS-ALPN-01 challenge for domain: %s", info.ServerName)
+	}
+	return cert, nil
+}
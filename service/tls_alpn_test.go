@@ -0,0 +1,106 @@
+// Copyright 2024 The casbin Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"crypto/tls"
+	"encoding/asn1"
+	"net"
+	"testing"
+
+	"github.com/casbin/caswaf/object"
+	"github.com/casdoor/casdoor-go-sdk/casdoorsdk"
+)
+
+var acmeIdentifierOid = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+func handshakeTlsAlpn(serverName string) (*tls.ConnectionState, error) {
+	config := &tls.Config{
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if isTlsAlpnChallenge(info) {
+				return getTlsAlpnChallengeCert(info)
+			}
+			return getDefaultX509Cert()
+		},
+	}
+	config.GetConfigForClient = getConfigForClient(config)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		defer serverConn.Close()
+		_ = tls.Server(serverConn, config).Handshake()
+	}()
+
+	client := tls.Client(clientConn, &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         []string{"acme-tls/1"},
+		InsecureSkipVerify: true,
+	})
+	err := client.Handshake()
+	if err != nil {
+		return nil, err
+	}
+	state := client.ConnectionState()
+	return &state, nil
+}
+
+func TestTlsAlpnChallenge(t *testing.T) {
+	object.InitMemoryAdapter()
+	// the sites are refreshed without the Casdoor applications
+	casdoorsdk.InitConfig("http://127.0.0.1:1", "", "", "", "", "")
+
+	// the challenge presented by another node is answered once the site map is refreshed
+	site := &object.Site{Owner: "admin", Name: "tls-alpn", Domain: "Example.com", PublicIp: "127.0.0.1", CertChallenge: "TLS-ALPN-01", Challenges: []string{"example.com:keyAuth"}}
+	_, err := object.AddSite(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := handshakeTlsAlpn("example.com")
+	if err != nil {
+		t.Fatalf("handshake error = %v", err)
+	}
+	if state.NegotiatedProtocol != "acme-tls/1" {
+		t.Errorf("NegotiatedProtocol = %s, want acme-tls/1", state.NegotiatedProtocol)
+	}
+
+	found := false
+	for _, extension := range state.PeerCertificates[0].Extensions {
+		if extension.Id.Equal(acmeIdentifierOid) && extension.Critical {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("the challenge cert should have the critical acmeIdentifier extension")
+	}
+
+	_, err = handshakeTlsAlpn("www.example.com")
+	if err == nil {
+		t.Errorf("handshake should fail for a domain without a pending challenge")
+	}
+
+	site.Challenges = []string{}
+	_, err = object.UpdateSite(site.GetId(), site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = handshakeTlsAlpn("example.com")
+	if err == nil {
+		t.Errorf("handshake should fail without a pending challenge")
+	}
+}
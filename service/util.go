@@ -98,18 +98,33 @@ func getSiteByDomainWithWww(domain string) *object.Site {
 	return site
 }
 
-func getX509CertByDomain(domain string) (*tls.Certificate, error) {
+// getX509CertByDomain returns the cert of the SNI, the ECDSA certificate of a dual cert is returned
+// if the client supports it, info is nil to always return the RSA certificate
+func getX509CertByDomain(domain string, info *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	cert, err := object.GetCertByDomain(domain)
 	if err != nil {
-		return nil, fmt.Errorf("getX509CertByDomain() error: %v, domain: [%s]", err, domain)
+		fmt.Printf("getX509CertByDomain() error: %v, domain: [%s]\n", err, domain)
 	}
 	if cert == nil {
-		return nil, fmt.Errorf("getX509CertByDomain() error: cert not found for domain: [%s]", domain)
+		// unknown SNI or no SNI, e.g., accessed by IP
+		return getDefaultX509Cert()
 	}
 
-	tlsCert, certErr := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.PrivateKey))
+	if info != nil {
+		eccTlsCert, err := object.GetEccTlsCert(cert)
+		if err != nil {
+			fmt.Printf("getX509CertByDomain() error: %v, domain: [%s]\n", err, domain)
+		} else if eccTlsCert != nil && info.SupportsCertificate(eccTlsCert) == nil {
+			return getStapledCert(eccTlsCert), nil
+		}
+	}
+
+	tlsCert, err := object.GetTlsCert(cert)
+	if err != nil {
+		return nil, err
+	}
 
-	return &tlsCert, certErr
+	return getStapledCert(tlsCert), nil
 }
 
 func getCasdoorClientFromSite(site *object.Site) (*casdoorsdk.Client, error) {
@@ -15,6 +15,9 @@
 package util
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"net"
 	"net/http"
 	"strings"
@@ -38,3 +41,42 @@ func GetClientIp(r *http.Request) string {
 	}
 	return ip
 }
+
+// GetClientCert returns the verified client certificate of an mTLS request, or nil if there is none
+func GetClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// GetClientCertAttribute returns the attribute of the client certificate by name: "Subject", "Common Name",
+// "Issuer", "SAN", "Serial" or "Fingerprint" (the SHA-256 hash of the certificate in hex)
+func GetClientCertAttribute(cert *x509.Certificate, name string) string {
+	switch name {
+	case "Subject":
+		return cert.Subject.String()
+	case "Common Name":
+		return cert.Subject.CommonName
+	case "Issuer":
+		return cert.Issuer.String()
+	case "SAN":
+		sans := []string{}
+		sans = append(sans, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+		return strings.Join(sans, ",")
+	case "Serial":
+		return cert.SerialNumber.String()
+	case "Fingerprint":
+		hash := sha256.Sum256(cert.Raw)
+		return hex.EncodeToString(hash[:])
+	default:
+		return ""
+	}
+}